@@ -0,0 +1,309 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+// grantHeaders are the headers granting permissions to lists of grantees.
+var grantHeaders = []struct {
+	name       string
+	permission string
+}{
+	{"x-amz-grant-read", common.PermissionRead},
+	{"x-amz-grant-write", common.PermissionWrite},
+	{"x-amz-grant-read-acp", common.PermissionReadACP},
+	{"x-amz-grant-write-acp", common.PermissionWriteACP},
+	{"x-amz-grant-full-control", common.PermissionFullControl},
+}
+
+// bucketPermissions are the permissions on the bucket required by the
+// operations. All other operations on a bucket are reserved to its owner.
+var bucketPermissions = map[S3METHOD]string{
+	HEADBUCKET:                common.PermissionRead,
+	GETBUCKET:                 common.PermissionRead,
+	GETBUCKET_V2:              common.PermissionRead,
+	GETBUCKET_OBJECTVERSION:   common.PermissionRead,
+	GETBUCKET_UPLOADS:         common.PermissionRead,
+	GETOBJECT_PARTS:           common.PermissionRead,
+	GETBUCKET_ACL:             common.PermissionReadACP,
+	PUTBUCKET_ACL:             common.PermissionWriteACP,
+	PUTOBJECT:                 common.PermissionWrite,
+	PUTOBJECT_COPY:            common.PermissionWrite,
+	POSTOBJECT:                common.PermissionWrite,
+	DELETEOBJECT:              common.PermissionWrite,
+	POSTOBJECT_UPLOADS:        common.PermissionWrite,
+	PUTOBJECT_PART:            common.PermissionWrite,
+	PUTOBJECT_PART_COPY:       common.PermissionWrite,
+	POSTOBJECT_COMPLETEUPLOAD: common.PermissionWrite,
+	DELETEOBJECT_UPLOAD:       common.PermissionWrite,
+}
+
+// objectPermissions are the permissions on the object required by the
+// operations. Tags are read by readers of the object and written by writers of
+// its ACL, which includes the owner.
+var objectPermissions = map[S3METHOD]string{
+	GETOBJECT:            common.PermissionRead,
+	HEADOBJECT:           common.PermissionRead,
+	GETOBJECT_TORRENT:    common.PermissionRead,
+	GETOBJECT_ACL:        common.PermissionReadACP,
+	PUTOBJECT_ACL:        common.PermissionWriteACP,
+	GETOBJECT_TAGGING:    common.PermissionRead,
+	PUTOBJECT_TAGGING:    common.PermissionWriteACP,
+	DELETEOBJECT_TAGGING: common.PermissionWriteACP,
+}
+
+// authorize checks the bucket policy and the ACLs of the addressed bucket and
+// object for the principal of the request. An explicit deny of the policy
+// overrides everything, an allow overrides the ACLs. The owner of a bucket
+// may always manage its policy, so it can't lock itself out. The ACL of the
+// bucket is kept in rd, and for multi-object deletes also its policy, which
+// the handler checks every key against.
+func authorize(r *http.Request, rd *S3Request) *common.Error {
+	switch rd.s3method {
+	case GETSERVICE, PUTBUCKET:
+		if rd.principal.IsAnonymous() {
+			return &common.ErrAccessDenied
+		}
+
+		return nil
+	case OPTIONSOBJECT:
+		// Preflight requests are not signed, they are checked against the
+		// CORS configuration.
+		return nil
+	}
+
+	acl, awserr := backend.GetBucketACL(rd.bucket, rd.principal)
+
+	if awserr != nil {
+		return awserr
+	}
+
+	rd.bucketACL = acl
+
+	switch rd.s3method {
+	case GETBUCKET_POLICY, PUTBUCKET_POLICY, DELETEBUCKET_POLICY:
+		if rd.principal.Owns(acl.Owner) {
+			return nil
+		}
+	}
+
+	policy, awserr := bucketPolicy(rd)
+
+	if awserr != nil {
+		return awserr
+	}
+
+	if rd.s3method == POSTBUCKET_DELETE {
+		rd.bucketPolicy = policy
+		return nil
+	}
+
+	return checkAccess(r, rd, policy)
+}
+
+// checkAccess evaluates the bucket policy and the ACLs for the principal of
+// rd, whose bucket ACL has already been read.
+func checkAccess(r *http.Request, rd *S3Request, policy *common.Policy) *common.Error {
+	decision := policy.Evaluate(policyRequest(r, rd))
+
+	if decision == common.Denied {
+		return &common.ErrAccessDenied
+	}
+
+	if permission, ok := objectPermissions[rd.s3method]; ok {
+		oacl, awserr := backend.GetObjectACL(rd.bucket, rd.object, rd.Param("versionId"), rd.principal)
+
+		if awserr == nil && (decision == common.Allowed || oacl.Allows(rd.principal, permission)) {
+			return nil
+		} else if awserr == nil {
+			return &common.ErrAccessDenied
+		}
+
+		// Missing objects are only reported to principals which may list
+		// the bucket.
+		if mayList(r, rd, policy) {
+			return nil
+		}
+
+		return &common.ErrAccessDenied
+	}
+
+	if decision == common.Allowed {
+		return nil
+	}
+
+	if permission, ok := bucketPermissions[rd.s3method]; ok && rd.bucketACL.Allows(rd.principal, permission) {
+		return nil
+	} else if !ok && rd.principal.Owns(rd.bucketACL.Owner) {
+		return nil
+	}
+
+	return &common.ErrAccessDenied
+}
+
+// parseGrantees parses the value of a x-amz-grant-* header, a
+// comma-separated list of id="...", uri="..." or emailAddress="...".
+func parseGrantees(s string) ([]common.Grantee, *common.Error) {
+	grantees := make([]common.Grantee, 0)
+
+	for _, g := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(g), "=", 2)
+
+		if len(kv) != 2 {
+			return nil, &common.ErrInvalidArgument
+		}
+
+		v := strings.Trim(kv[1], `"`)
+
+		switch kv[0] {
+		case "id":
+			grantees = append(grantees, common.Grantee{Type: common.GranteeCanonicalUser, ID: v})
+		case "uri":
+			grantees = append(grantees, common.Group(v))
+		case "emailAddress":
+			grantees = append(grantees, common.Grantee{Type: common.GranteeEmail, EmailAddress: v})
+		default:
+			return nil, &common.ErrInvalidArgument
+		}
+	}
+
+	return grantees, nil
+}
+
+// requestGrants returns the grants set by the x-amz-acl or x-amz-grant-*
+// headers for a resource owned by owner, or nil if there are none.
+func requestGrants(r *http.Request, owner common.Owner, bucketOwner common.Owner) ([]common.Grant, *common.Error) {
+	var grants []common.Grant
+
+	for _, h := range grantHeaders {
+		for _, v := range r.Header[http.CanonicalHeaderKey(h.name)] {
+			grantees, awserr := parseGrantees(v)
+
+			if awserr != nil {
+				return nil, awserr
+			}
+
+			for _, g := range grantees {
+				grants = append(grants, common.Grant{Grantee: g, Permission: h.permission})
+			}
+		}
+	}
+
+	canned := r.Header.Get("x-amz-acl")
+
+	switch {
+	case canned != "" && grants != nil:
+		return nil, &common.ErrCannedACLWithGrants
+	case canned != "":
+		return common.ACL(canned).Grants(owner, bucketOwner)
+	}
+
+	if awserr := common.ValidateGrants(grants); awserr != nil {
+		return nil, awserr
+	}
+
+	return grants, nil
+}
+
+// objectGrants returns the grants requested for an object created by rd.
+func objectGrants(r *http.Request, rd *S3Request) ([]common.Grant, *common.Error) {
+	return requestGrants(r, rd.principal.Owner(), rd.bucketACL.Owner)
+}
+
+// aclBody returns the grants of PUT ?acl, set either by headers or by an
+// AccessControlPolicy document, for a resource owned by owner.
+func aclBody(r *http.Request, owner common.Owner, bucketOwner common.Owner) ([]common.Grant, *common.Error) {
+	grants, awserr := requestGrants(r, owner, bucketOwner)
+
+	if awserr != nil || grants != nil {
+		return grants, awserr
+	}
+
+	acp := common.AccessControlPolicy{}
+
+	if awserr := readXML(r, &acp, &common.ErrMalformedACLError); awserr != nil {
+		return nil, awserr
+	}
+
+	if owner.ID != "" && acp.Owner.ID != owner.ID {
+		return nil, &common.ErrAccessDenied
+	}
+
+	if awserr := common.ValidateGrants(acp.Grants); awserr != nil {
+		return nil, awserr
+	}
+
+	if acp.Grants == nil {
+		return []common.Grant{}, nil
+	}
+
+	return acp.Grants, nil
+}
+
+func getBucketACLHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("getBucketACLHandler", rd)
+	acl, awserr := backend.GetBucketACL(rd.bucket, rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	writeXML(w, acl)
+}
+
+func putBucketACLHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("putBucketACLHandler", rd)
+	grants, awserr := aclBody(r, rd.bucketACL.Owner, rd.bucketACL.Owner)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	if awserr := backend.PutBucketACL(rd.bucket, grants, rd.principal); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func getObjectACLHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("getObjectACLHandler", rd)
+	acl, awserr := backend.GetObjectACL(rd.bucket, rd.object, rd.Param("versionId"), rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	writeXML(w, acl)
+}
+
+func putObjectACLHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("putObjectACLHandler", rd)
+	acl, awserr := backend.GetObjectACL(rd.bucket, rd.object, rd.Param("versionId"), rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	grants, awserr := aclBody(r, acl.Owner, rd.bucketACL.Owner)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	if awserr := backend.PutObjectACL(rd.bucket, rd.object, rd.Param("versionId"), grants, rd.principal); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,163 @@
+// Package auth authenticates S3 requests signed with AWS Signature Version 2
+// or 4.
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+// MaxSkew is the maximum difference between the time of a request and the
+// time of the server.
+const MaxSkew = 15 * time.Minute
+
+// Signature versions accepted by a Verifier.
+const (
+	SignatureV2 = 1 << iota
+	SignatureV4
+)
+
+// ParseSignatureVersions parses the accepted signature versions "v2", "v4" or
+// "both".
+func ParseSignatureVersions(s string) (int, error) {
+	switch s {
+	case "v2":
+		return SignatureV2, nil
+	case "v4":
+		return SignatureV4, nil
+	case "both":
+		return SignatureV2 | SignatureV4, nil
+	}
+
+	return 0, fmt.Errorf("Unknown signature version %q, expected v2, v4 or both", s)
+}
+
+// Verifier checks the signatures of requests against a credential store.
+type Verifier struct {
+	Credentials Credentials
+	// Accept is a combination of SignatureV2 and SignatureV4. Both versions
+	// are accepted if it is zero.
+	Accept int
+	// Region is the region requests have to be signed for. Requests signed
+	// for any region are accepted if it is empty.
+	Region string
+	// Now returns the time of the server. It defaults to time.Now.
+	Now func() time.Time
+}
+
+func (v *Verifier) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+
+	return time.Now()
+}
+
+func (v *Verifier) accepts(version int) bool {
+	return v.Accept == 0 || v.Accept&version != 0
+}
+
+// Verify authenticates r and returns the account of the access key it was
+// signed with. Requests are signed either by the Authorization header or, for
+// presigned URLs, by query parameters. hostBucket is the bucket addressed by
+// the Host header of a virtual-hosted-style request, which is part of the
+// resource signed with Signature Version 2. For Signature Version 4 the body
+// of r is replaced by a reader which fails with ErrXAmzContentSHA256Mismatch
+// if the payload does not match its signed hash. Anonymous requests, which are
+// signed neither way, return a nil account.
+func (v *Verifier) Verify(r *http.Request, hostBucket string) (*Account, *common.Error) {
+	h := r.Header.Get("Authorization")
+	query := r.URL.Query()
+	presigned := false
+	version := 0
+
+	switch {
+	case h == "" && query.Get("X-Amz-Algorithm") != "":
+		presigned, version = true, SignatureV4
+	case h == "" && query.Get("AWSAccessKeyId") != "":
+		presigned, version = true, SignatureV2
+	case h == "":
+		return nil, nil
+	case strings.HasPrefix(h, sigV4Algorithm+" "):
+		version = SignatureV4
+	case strings.HasPrefix(h, sigV2Scheme+" "):
+		version = SignatureV2
+	default:
+		return nil, &common.ErrInvalidAuthorizationType
+	}
+
+	if !v.accepts(version) {
+		return nil, &common.ErrAuthorizationMechanismNotSupported
+	}
+
+	switch {
+	case presigned && version == SignatureV2:
+		return v.verifyV2Query(r, query, hostBucket)
+	case presigned:
+		return v.verifyV4Query(r, query)
+	case version == SignatureV2:
+		return v.verifyV2(r, h, hostBucket)
+	}
+
+	return v.verifyV4(r, h)
+}
+
+// queryParams are the query parameters which authenticate presigned requests.
+var queryParams = []string{
+	"AWSAccessKeyId",
+	"Expires",
+	"Signature",
+	"X-Amz-Algorithm",
+	"X-Amz-Credential",
+	"X-Amz-Date",
+	"X-Amz-Expires",
+	"X-Amz-Security-Token",
+	"X-Amz-Signature",
+	"X-Amz-SignedHeaders",
+}
+
+// RemoveQueryParams removes the authentication parameters of presigned
+// requests from params, leaving the parameters which address the resource.
+func RemoveQueryParams(params url.Values) {
+	for _, name := range queryParams {
+		params.Del(name)
+	}
+}
+
+// checkSkew checks that the time of a request is close to the server time.
+func (v *Verifier) checkSkew(t time.Time) *common.Error {
+	d := v.now().Sub(t)
+
+	if d > MaxSkew || d < -MaxSkew {
+		return &common.ErrRequestTimeTooSkewed
+	}
+
+	return nil
+}
+
+// uriEncode encodes s as required by the signature algorithms. All bytes but
+// the unreserved characters are escaped, slashes only if encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	const hex = "0123456789ABCDEF"
+	b := make([]byte, 0, len(s))
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b = append(b, c)
+		case c == '/' && !encodeSlash:
+			b = append(b, c)
+		default:
+			b = append(b, '%', hex[c>>4], hex[c&15])
+		}
+	}
+
+	return string(b)
+}
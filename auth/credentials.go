@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+// Account is an access key with its secret and the account it belongs to.
+// Several access keys may belong to the same account.
+type Account struct {
+	AccessKey   string `json:"accessKey"`
+	SecretKey   string `json:"secretKey"`
+	ID          string `json:"id"` // canonical user ID
+	DisplayName string `json:"displayName"`
+}
+
+// Principal returns the account requests signed with the access key are made
+// by.
+func (a *Account) Principal() *common.Principal {
+	return &common.Principal{ID: a.ID, DisplayName: a.DisplayName}
+}
+
+// CanonicalID derives a canonical user ID from a name, for accounts which are
+// configured without one.
+func CanonicalID(name string) string {
+	sum := sha256.Sum256([]byte(name))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Credentials maps access key IDs to accounts.
+type Credentials interface {
+	Account(accessKey string) (*Account, bool)
+}
+
+// MemoryCredentials is a credential store held in memory. It is safe for
+// concurrent use.
+type MemoryCredentials struct {
+	sync.RWMutex
+	accounts map[string]*Account
+}
+
+// NewMemoryCredentials returns a store holding the given accounts.
+func NewMemoryCredentials(accounts ...*Account) *MemoryCredentials {
+	c := &MemoryCredentials{accounts: make(map[string]*Account)}
+
+	for _, a := range accounts {
+		c.Add(a)
+	}
+
+	return c
+}
+
+// Add adds an account, replacing any account with the same access key. A
+// missing canonical ID is derived from the access key, a missing display name
+// is the access key.
+func (c *MemoryCredentials) Add(a *Account) {
+	if a.ID == "" {
+		a.ID = CanonicalID(a.AccessKey)
+	}
+
+	if a.DisplayName == "" {
+		a.DisplayName = a.AccessKey
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.accounts[a.AccessKey] = a
+}
+
+// Remove removes the account of the access key.
+func (c *MemoryCredentials) Remove(accessKey string) {
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.accounts, accessKey)
+}
+
+func (c *MemoryCredentials) Account(accessKey string) (*Account, bool) {
+	c.RLock()
+	defer c.RUnlock()
+
+	a, ok := c.accounts[accessKey]
+
+	return a, ok
+}
+
+// ParseCredentials parses a comma-separated list of accessKey:secretKey
+// pairs.
+func ParseCredentials(s string) (*MemoryCredentials, error) {
+	c := NewMemoryCredentials()
+
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.Index(pair, ":")
+
+		if i <= 0 || i == len(pair)-1 {
+			return nil, fmt.Errorf("Invalid credentials %q, expected accessKey:secretKey", pair)
+		}
+
+		c.Add(&Account{AccessKey: pair[:i], SecretKey: pair[i+1:]})
+	}
+
+	return c, nil
+}
+
+// FileCredentials is a credential store backed by a JSON file holding an
+// array of accounts:
+//
+//	[{"accessKey": "...", "secretKey": "...", "id": "...", "displayName": "..."}]
+//
+// The file is read again when it has been modified, so accounts can be added
+// and removed while the server is running.
+type FileCredentials struct {
+	path string
+
+	sync.Mutex
+	modTime  time.Time
+	accounts *MemoryCredentials
+}
+
+// NewFileCredentials reads the accounts from the file at path.
+func NewFileCredentials(path string) (*FileCredentials, error) {
+	fi, err := os.Stat(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c := &FileCredentials{path: path, modTime: fi.ModTime()}
+
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// load has to be called with the lock held.
+func (c *FileCredentials) load() error {
+	f, err := os.Open(c.path)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	var accounts []*Account
+
+	if err := json.NewDecoder(f).Decode(&accounts); err != nil {
+		return fmt.Errorf("Invalid credentials file %s: %v", c.path, err)
+	}
+
+	for _, a := range accounts {
+		if a.AccessKey == "" || a.SecretKey == "" {
+			return fmt.Errorf("Invalid credentials file %s: accessKey and secretKey are required", c.path)
+		}
+	}
+
+	c.accounts = NewMemoryCredentials(accounts...)
+
+	return nil
+}
+
+// Account looks up the access key. If a modified file can't be read, the
+// accounts read before stay in use until it is modified again.
+func (c *FileCredentials) Account(accessKey string) (*Account, bool) {
+	c.Lock()
+
+	if fi, err := os.Stat(c.path); err != nil {
+		log.Printf("[auth] %v", err)
+	} else if !fi.ModTime().Equal(c.modTime) {
+		c.modTime = fi.ModTime()
+
+		if err := c.load(); err != nil {
+			log.Printf("[auth] %v", err)
+		}
+	}
+
+	accounts := c.accounts
+	c.Unlock()
+
+	return accounts.Account(accessKey)
+}
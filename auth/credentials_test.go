@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseCredentials(t *testing.T) {
+	c, err := ParseCredentials("key:secret,other:s:e")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, ok := c.Account("other")
+
+	if !ok || a.SecretKey != "s:e" || a.ID != CanonicalID("other") || a.DisplayName != "other" {
+		t.Errorf("Unexpected account %+v", a)
+	}
+
+	for _, s := range []string{"", "key", ":secret", "key:"} {
+		if _, err := ParseCredentials(s); err == nil {
+			t.Errorf("Expected an error for %q", s)
+		}
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "credentials.json")
+	write := func(s string, modTime time.Time) {
+		if err := ioutil.WriteFile(path, []byte(s), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		os.Chtimes(path, modTime, modTime)
+	}
+
+	write(`[{"accessKey": "key", "secretKey": "secret", "id": "id", "displayName": "alice"}]`, time.Unix(1, 0))
+	c, err := NewFileCredentials(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a, ok := c.Account("key"); !ok || a.SecretKey != "secret" || a.ID != "id" || a.DisplayName != "alice" {
+		t.Errorf("Unexpected account %+v", a)
+	}
+
+	write(`[{"accessKey": "new", "secretKey": "secret"}]`, time.Unix(2, 0))
+
+	if _, ok := c.Account("key"); ok {
+		t.Error("Expected the removed key to be unknown")
+	}
+
+	if a, ok := c.Account("new"); !ok || a.ID != CanonicalID("new") {
+		t.Errorf("Unexpected account %+v", a)
+	}
+
+	write(`[{"accessKey": "broken"`, time.Unix(3, 0))
+
+	if _, ok := c.Account("new"); !ok {
+		t.Error("Expected the accounts to stay in use if the file is invalid")
+	}
+
+	if _, err := NewFileCredentials(path); err == nil {
+		t.Error("Expected an error for an invalid file")
+	}
+}
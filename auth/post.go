@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+// VerifyPost authenticates the form of a browser-based POST upload, given by
+// its fields keyed by lowercase name. The form signs its base64 encoded policy
+// document rather than the request: with Signature Version 2 the signature
+// field is the base64 encoded HMAC-SHA1 of the policy, with Version 4 the
+// x-amz-signature field is the hex encoded HMAC-SHA256 of the policy under
+// the signing key of x-amz-credential. The expiration of the policy takes
+// the place of the request time, so it is checked by the caller. Forms which
+// are signed neither way return a nil account.
+func (v *Verifier) VerifyPost(fields map[string]string) (*Account, *common.Error) {
+	version := 0
+
+	switch {
+	case fields["x-amz-algorithm"] != "" || fields["x-amz-credential"] != "" || fields["x-amz-signature"] != "":
+		version = SignatureV4
+	case fields["awsaccesskeyid"] != "" || fields["signature"] != "":
+		version = SignatureV2
+	default:
+		return nil, nil
+	}
+
+	if !v.accepts(version) {
+		return nil, &common.ErrAuthorizationMechanismNotSupported
+	}
+
+	policy := fields["policy"]
+
+	if policy == "" {
+		return nil, &common.ErrInvalidArgument
+	}
+
+	if version == SignatureV2 {
+		return v.verifyPostV2(policy, fields["awsaccesskeyid"], fields["signature"])
+	}
+
+	if fields["x-amz-algorithm"] != sigV4Algorithm {
+		return nil, &common.ErrInvalidArgument
+	}
+
+	a := &v4Authorization{signature: fields["x-amz-signature"]}
+
+	if !a.parseCredential(fields["x-amz-credential"]) || a.signature == "" {
+		return nil, &common.ErrInvalidArgument
+	}
+
+	t, err := time.Parse(amzDateFormat, fields["x-amz-date"])
+
+	if err != nil {
+		return nil, &common.ErrInvalidArgument
+	}
+
+	if awserr := v.checkScope(a, t, &common.ErrInvalidArgument); awserr != nil {
+		return nil, awserr
+	}
+
+	account, ok := v.Credentials.Account(a.accessKey)
+
+	if !ok {
+		return nil, &common.ErrInvalidAccessKeyId
+	}
+
+	if !hmac.Equal([]byte(a.signature), []byte(hex.EncodeToString(hmacSHA256(signingKey(account.SecretKey, a), policy)))) {
+		return nil, &common.ErrSignatureDoesNotMatch
+	}
+
+	return account, nil
+}
+
+func (v *Verifier) verifyPostV2(policy string, accessKey string, signature string) (*Account, *common.Error) {
+	if accessKey == "" || signature == "" {
+		return nil, &common.ErrInvalidArgument
+	}
+
+	account, ok := v.Credentials.Account(accessKey)
+
+	if !ok {
+		return nil, &common.ErrInvalidAccessKeyId
+	}
+
+	mac := hmac.New(sha1.New, []byte(account.SecretKey))
+	mac.Write([]byte(policy))
+
+	if !hmac.Equal([]byte(signature), []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil)))) {
+		return nil, &common.ErrSignatureDoesNotMatch
+	}
+
+	return account, nil
+}
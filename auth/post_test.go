@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"testing"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+const postPolicy = "eyJleHBpcmF0aW9uIjogIjIwMTMtMDUtMjVUMDA6MDA6MDAuMDAwWiIsICJjb25kaXRpb25zIjogW119"
+
+func hmacOf(h func() hash.Hash, key []byte, data string) []byte {
+	mac := hmac.New(h, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
+
+// examplePostForm returns the fields of a form signed with Signature Version 4
+// on the day of the verifier of the examples.
+func examplePostForm() map[string]string {
+	key := hmacOf(sha256.New, []byte("AWS4"+exampleSecretKey), "20130524")
+	key = hmacOf(sha256.New, key, "us-east-1")
+	key = hmacOf(sha256.New, key, "s3")
+	key = hmacOf(sha256.New, key, "aws4_request")
+
+	return map[string]string{
+		"key":              "test.txt",
+		"policy":           postPolicy,
+		"x-amz-algorithm":  "AWS4-HMAC-SHA256",
+		"x-amz-credential": exampleAccessKey + "/20130524/us-east-1/s3/aws4_request",
+		"x-amz-date":       "20130524T000000Z",
+		"x-amz-signature":  hex.EncodeToString(hmacOf(sha256.New, key, postPolicy)),
+	}
+}
+
+func examplePostFormV2() map[string]string {
+	return map[string]string{
+		"key":            "test.txt",
+		"policy":         postPolicy,
+		"awsaccesskeyid": exampleAccessKey,
+		"signature":      base64.StdEncoding.EncodeToString(hmacOf(sha1.New, []byte(exampleSecretKey), postPolicy)),
+	}
+}
+
+func TestVerifyPost(t *testing.T) {
+	for _, fields := range []map[string]string{examplePostForm(), examplePostFormV2()} {
+		if a, awserr := exampleVerifier().VerifyPost(fields); awserr != nil || a.AccessKey != exampleAccessKey {
+			t.Errorf("%v: expected %s, got %v, %v", fields, exampleAccessKey, a, awserr)
+		}
+	}
+
+	if a, awserr := exampleVerifier().VerifyPost(map[string]string{"key": "test.txt"}); a != nil || awserr != nil {
+		t.Errorf("Expected an anonymous form, got %v, %v", a, awserr)
+	}
+}
+
+func TestVerifyPostErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]string
+		modify func(fields map[string]string, v *Verifier)
+		err    *common.Error
+	}{
+		{"modified policy", examplePostForm(), func(f map[string]string, v *Verifier) { f["policy"] = "e30=" }, &common.ErrSignatureDoesNotMatch},
+		{"missing policy", examplePostForm(), func(f map[string]string, v *Verifier) { delete(f, "policy") }, &common.ErrInvalidArgument},
+		{"missing signature", examplePostForm(), func(f map[string]string, v *Verifier) { delete(f, "x-amz-signature") }, &common.ErrInvalidArgument},
+		{"wrong algorithm", examplePostForm(), func(f map[string]string, v *Verifier) { f["x-amz-algorithm"] = "AWS4-HMAC-SHA1" }, &common.ErrInvalidArgument},
+		{"wrong date", examplePostForm(), func(f map[string]string, v *Verifier) { f["x-amz-date"] = "20130525T000000Z" }, &common.ErrInvalidArgument},
+		{"wrong region", examplePostForm(), func(f map[string]string, v *Verifier) { v.Region = "eu-west-1" }, &common.ErrInvalidArgument},
+		{"unknown key", examplePostForm(), func(f map[string]string, v *Verifier) { v.Credentials = NewMemoryCredentials() }, &common.ErrInvalidAccessKeyId},
+		{"v4 not accepted", examplePostForm(), func(f map[string]string, v *Verifier) { v.Accept = SignatureV2 }, &common.ErrAuthorizationMechanismNotSupported},
+		{"v2 modified policy", examplePostFormV2(), func(f map[string]string, v *Verifier) { f["policy"] = "e30=" }, &common.ErrSignatureDoesNotMatch},
+		{"v2 missing signature", examplePostFormV2(), func(f map[string]string, v *Verifier) { delete(f, "signature") }, &common.ErrInvalidArgument},
+		{"v2 wrong secret", examplePostFormV2(), func(f map[string]string, v *Verifier) {
+			v.Credentials = NewMemoryCredentials(&Account{AccessKey: exampleAccessKey, SecretKey: "secret"})
+		}, &common.ErrSignatureDoesNotMatch},
+		{"v2 not accepted", examplePostFormV2(), func(f map[string]string, v *Verifier) { v.Accept = SignatureV4 }, &common.ErrAuthorizationMechanismNotSupported},
+	}
+
+	for _, test := range tests {
+		v := exampleVerifier()
+		test.modify(test.fields, v)
+
+		if _, awserr := v.VerifyPost(test.fields); awserr != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, awserr)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+const sigV2Scheme = "AWS"
+
+// subresources are the query parameters which are part of the canonicalized
+// resource of Signature Version 2.
+var subresources = map[string]bool{
+	"acl":                          true,
+	"cors":                         true,
+	"delete":                       true,
+	"lifecycle":                    true,
+	"location":                     true,
+	"logging":                      true,
+	"notification":                 true,
+	"partNumber":                   true,
+	"policy":                       true,
+	"replication":                  true,
+	"requestPayment":               true,
+	"response-cache-control":       true,
+	"response-content-disposition": true,
+	"response-content-encoding":    true,
+	"response-content-language":    true,
+	"response-content-type":        true,
+	"response-expires":             true,
+	"restore":                      true,
+	"tagging":                      true,
+	"torrent":                      true,
+	"uploadId":                     true,
+	"uploads":                      true,
+	"versionId":                    true,
+	"versioning":                   true,
+	"versions":                     true,
+	"website":                      true,
+}
+
+// verifyV2 verifies the Authorization header AWS <key>:<signature>.
+func (v *Verifier) verifyV2(r *http.Request, h string, hostBucket string) (*Account, *common.Error) {
+	cred := strings.TrimPrefix(h, sigV2Scheme+" ")
+	i := strings.LastIndex(cred, ":")
+
+	if i <= 0 {
+		return nil, &common.ErrAuthorizationHeaderMalformed
+	}
+
+	accessKey, signature := cred[:i], cred[i+1:]
+	account, ok := v.Credentials.Account(accessKey)
+
+	if !ok {
+		return nil, &common.ErrInvalidAccessKeyId
+	}
+
+	date := r.Header.Get("X-Amz-Date")
+
+	if date == "" {
+		date = r.Header.Get("Date")
+	}
+
+	t, ok := parseDate(date)
+
+	if !ok {
+		return nil, &common.ErrAccessDenied
+	}
+
+	if awserr := v.checkSkew(t); awserr != nil {
+		return nil, awserr
+	}
+
+	// The Date header is replaced by X-Amz-Date, which is signed along
+	// with the other x-amz-* headers.
+	if r.Header.Get("X-Amz-Date") != "" {
+		date = ""
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(signV2(account.SecretKey, r, date, hostBucket))) {
+		return nil, &common.ErrSignatureDoesNotMatch
+	}
+
+	return account, nil
+}
+
+// verifyV2Query verifies a presigned request with the query parameters
+// AWSAccessKeyId, Expires and Signature. Expires, the expiry time in seconds
+// since the epoch, takes the place of the Date line in the string to sign.
+func (v *Verifier) verifyV2Query(r *http.Request, query url.Values, hostBucket string) (*Account, *common.Error) {
+	accessKey, expires, signature := query.Get("AWSAccessKeyId"), query.Get("Expires"), query.Get("Signature")
+
+	if expires == "" || signature == "" {
+		return nil, &common.ErrAccessDenied
+	}
+
+	account, ok := v.Credentials.Account(accessKey)
+
+	if !ok {
+		return nil, &common.ErrInvalidAccessKeyId
+	}
+
+	e, err := strconv.ParseInt(expires, 10, 64)
+
+	if err != nil {
+		return nil, &common.ErrAccessDenied
+	}
+
+	if v.now().Unix() > e {
+		return nil, &common.ErrRequestExpired
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(signV2(account.SecretKey, r, expires, hostBucket))) {
+		return nil, &common.ErrSignatureDoesNotMatch
+	}
+
+	return account, nil
+}
+
+// parseDate parses a Date header. Clients use numeric time zones as well as
+// the HTTP date formats.
+func parseDate(s string) (time.Time, bool) {
+	if t, err := http.ParseTime(s); err == nil {
+		return t, true
+	}
+
+	t, err := time.Parse(time.RFC1123Z, s)
+
+	return t, err == nil
+}
+
+// signV2 returns the base64 encoded signature of r. date is the Date line of
+// the string to sign, which is the expiry time for presigned requests.
+func signV2(secret string, r *http.Request, date string, hostBucket string) string {
+	sts := strings.Join([]string{
+		r.Method,
+		r.Header.Get("Content-MD5"),
+		r.Header.Get("Content-Type"),
+		date,
+		canonicalAmzHeaders(r) + canonicalResource(r, hostBucket),
+	}, "\n")
+
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(sts))
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// canonicalAmzHeaders returns the x-amz-* headers as sorted lowercase
+// name:value lines. Multiple values are joined by commas.
+func canonicalAmzHeaders(r *http.Request) string {
+	names := make([]string, 0)
+
+	for name := range r.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-amz-") {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	var b []byte
+
+	for _, name := range names {
+		values := r.Header[http.CanonicalHeaderKey(name)]
+		trimmed := make([]string, len(values))
+
+		for i, v := range values {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+
+		b = append(b, name+":"+strings.Join(trimmed, ",")+"\n"...)
+	}
+
+	return string(b)
+}
+
+// canonicalResource returns the path of r as sent, prefixed with the bucket
+// of virtual-hosted-style requests, followed by the sorted subresources.
+func canonicalResource(r *http.Request, hostBucket string) string {
+	resource := r.URL.EscapedPath()
+
+	if resource == "" {
+		resource = "/"
+	}
+
+	if hostBucket != "" {
+		resource = "/" + hostBucket + resource
+	}
+
+	query, _ := url.ParseQuery(r.URL.RawQuery)
+	params := make([]string, 0)
+
+	for name, values := range query {
+		if !subresources[name] {
+			continue
+		}
+
+		for _, v := range values {
+			if v == "" {
+				params = append(params, name)
+			} else {
+				params = append(params, name+"="+v)
+			}
+		}
+	}
+
+	if len(params) == 0 {
+		return resource
+	}
+
+	sort.Strings(params)
+
+	return resource + "?" + strings.Join(params, "&")
+}
@@ -0,0 +1,314 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	amzDateFormat   = "20060102T150405Z"
+	scopeDateFormat = "20060102"
+	scopeService    = "s3"
+	scopeTerminator = "aws4_request"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// v4Authorization is a parsed Signature Version 4 Authorization header:
+//
+//	AWS4-HMAC-SHA256 Credential=<key>/<date>/<region>/s3/aws4_request,
+//	SignedHeaders=<header>;<header>, Signature=<hex>
+type v4Authorization struct {
+	accessKey     string
+	date          string
+	region        string
+	signedHeaders []string
+	signature     string
+}
+
+func (a *v4Authorization) scope() string {
+	return strings.Join([]string{a.date, a.region, scopeService, scopeTerminator}, "/")
+}
+
+// parseCredential parses the credential <key>/<date>/<region>/s3/aws4_request
+// into a.
+func (a *v4Authorization) parseCredential(c string) bool {
+	parts := strings.Split(c, "/")
+
+	if len(parts) != 5 || parts[0] == "" || parts[3] != scopeService || parts[4] != scopeTerminator {
+		return false
+	}
+
+	a.accessKey, a.date, a.region = parts[0], parts[1], parts[2]
+
+	return true
+}
+
+func parseV4Authorization(h string) (*v4Authorization, *common.Error) {
+	a := &v4Authorization{}
+
+	for _, field := range strings.Split(strings.TrimPrefix(h, sigV4Algorithm+" "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+
+		if len(kv) != 2 {
+			return nil, &common.ErrAuthorizationHeaderMalformed
+		}
+
+		switch kv[0] {
+		case "Credential":
+			if !a.parseCredential(kv[1]) {
+				return nil, &common.ErrAuthorizationHeaderMalformed
+			}
+		case "SignedHeaders":
+			a.signedHeaders = strings.Split(kv[1], ";")
+		case "Signature":
+			a.signature = kv[1]
+		}
+	}
+
+	if a.accessKey == "" || a.signedHeaders == nil || a.signature == "" {
+		return nil, &common.ErrAuthorizationHeaderMalformed
+	}
+
+	return a, nil
+}
+
+// requestTime returns the time of a request from the X-Amz-Date or the Date
+// header, along with its representation in the string to sign.
+func requestTime(r *http.Request) (time.Time, string, *common.Error) {
+	if s := r.Header.Get("X-Amz-Date"); s != "" {
+		t, err := time.Parse(amzDateFormat, s)
+
+		if err != nil {
+			return time.Time{}, "", &common.ErrAccessDenied
+		}
+
+		return t, s, nil
+	}
+
+	t, err := http.ParseTime(r.Header.Get("Date"))
+
+	if err != nil {
+		return time.Time{}, "", &common.ErrAccessDenied
+	}
+
+	return t, t.UTC().Format(amzDateFormat), nil
+}
+
+func (v *Verifier) verifyV4(r *http.Request, h string) (*Account, *common.Error) {
+	a, awserr := parseV4Authorization(h)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	account, ok := v.Credentials.Account(a.accessKey)
+
+	if !ok {
+		return nil, &common.ErrInvalidAccessKeyId
+	}
+
+	t, amzDate, awserr := requestTime(r)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	if awserr := v.checkSkew(t); awserr != nil {
+		return nil, awserr
+	}
+
+	if awserr := v.checkScope(a, t, &common.ErrAuthorizationHeaderMalformed); awserr != nil {
+		return nil, awserr
+	}
+
+	payload := r.Header.Get("X-Amz-Content-Sha256")
+
+	if payload == "" {
+		return nil, &common.ErrMissingContentSHA256
+	}
+
+	return v.checkV4Signature(r, a, account, amzDate, r.URL.Query(), payload)
+}
+
+// maxExpires is the longest validity of a presigned URL.
+const maxExpires = 7 * 24 * time.Hour
+
+// verifyV4Query verifies a presigned request, whose authentication is carried
+// by the X-Amz-* query parameters. The payload is not signed unless the
+// client sends its hash.
+func (v *Verifier) verifyV4Query(r *http.Request, query url.Values) (*Account, *common.Error) {
+	if query.Get("X-Amz-Algorithm") != sigV4Algorithm {
+		return nil, &common.ErrAuthorizationQueryParametersError
+	}
+
+	a := &v4Authorization{signature: query.Get("X-Amz-Signature")}
+
+	if !a.parseCredential(query.Get("X-Amz-Credential")) || a.signature == "" || query.Get("X-Amz-SignedHeaders") == "" {
+		return nil, &common.ErrAuthorizationQueryParametersError
+	}
+
+	a.signedHeaders = strings.Split(query.Get("X-Amz-SignedHeaders"), ";")
+	account, ok := v.Credentials.Account(a.accessKey)
+
+	if !ok {
+		return nil, &common.ErrInvalidAccessKeyId
+	}
+
+	amzDate := query.Get("X-Amz-Date")
+	t, err := time.Parse(amzDateFormat, amzDate)
+
+	if err != nil {
+		return nil, &common.ErrAuthorizationQueryParametersError
+	}
+
+	expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+
+	if err != nil || expires < 0 || time.Duration(expires)*time.Second > maxExpires {
+		return nil, &common.ErrAuthorizationQueryParametersError
+	}
+
+	if now := v.now(); now.After(t.Add(time.Duration(expires)*time.Second)) || t.Sub(now) > MaxSkew {
+		return nil, &common.ErrRequestExpired
+	}
+
+	if awserr := v.checkScope(a, t, &common.ErrAuthorizationQueryParametersError); awserr != nil {
+		return nil, awserr
+	}
+
+	payload := r.Header.Get("X-Amz-Content-Sha256")
+
+	if payload == "" {
+		payload = unsignedPayload
+	}
+
+	query.Del("X-Amz-Signature")
+
+	return v.checkV4Signature(r, a, account, amzDate, query, payload)
+}
+
+// checkScope checks that the credential scope of a matches the request time
+// t and the region of the server.
+func (v *Verifier) checkScope(a *v4Authorization, t time.Time, malformed *common.Error) *common.Error {
+	if t.UTC().Format(scopeDateFormat) != a.date || (v.Region != "" && a.region != v.Region) {
+		return malformed
+	}
+
+	return nil
+}
+
+// checkV4Signature compares the signature of a with the signature of the
+// canonical request and wraps the body of r to verify a signed payload hash.
+func (v *Verifier) checkV4Signature(r *http.Request, a *v4Authorization, account *Account, amzDate string, query url.Values, payload string) (*Account, *common.Error) {
+	cr := canonicalRequest(r, query, a.signedHeaders, payload)
+
+	if !hmac.Equal([]byte(a.signature), []byte(signV4(account.SecretKey, a, amzDate, cr))) {
+		return nil, &common.ErrSignatureDoesNotMatch
+	}
+
+	if payload != unsignedPayload {
+		sum, err := hex.DecodeString(payload)
+
+		if err != nil || len(sum) != sha256.Size {
+			return nil, &common.ErrXAmzContentSHA256Mismatch
+		}
+
+		r.Body = common.NewDigestReader(r.Body, sha256.New(), sum, &common.ErrXAmzContentSHA256Mismatch)
+	}
+
+	return account, nil
+}
+
+// canonicalRequest returns the canonical form of r, which is hashed into the
+// string to sign.
+func canonicalRequest(r *http.Request, query url.Values, signedHeaders []string, payload string) string {
+	path := r.URL.Path
+
+	if path == "" {
+		path = "/"
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		uriEncode(path, false),
+		canonicalQuery(query),
+		canonicalHeaders(r, signedHeaders),
+		strings.Join(signedHeaders, ";"),
+		payload,
+	}, "\n")
+}
+
+// canonicalQuery returns the encoded query parameters sorted by name and
+// value.
+func canonicalQuery(query url.Values) string {
+	params := make([]string, 0, len(query))
+
+	for k, vs := range query {
+		for _, v := range vs {
+			params = append(params, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+
+	sort.Strings(params)
+
+	return strings.Join(params, "&")
+}
+
+// canonicalHeaders returns the signed headers as lowercase name:value lines.
+// Values are trimmed and multiple values are joined by commas.
+func canonicalHeaders(r *http.Request, signedHeaders []string) string {
+	var b []byte
+
+	for _, name := range signedHeaders {
+		var values []string
+
+		if name == "host" {
+			values = []string{r.Host}
+		} else {
+			values = r.Header[http.CanonicalHeaderKey(name)]
+		}
+
+		trimmed := make([]string, len(values))
+
+		for i, v := range values {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+
+		b = append(b, name+":"+strings.Join(trimmed, ",")+"\n"...)
+	}
+
+	return string(b)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
+
+// signingKey derives the key signing requests in the credential scope of a.
+func signingKey(secret string, a *v4Authorization) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), a.date)
+	key = hmacSHA256(key, a.region)
+	key = hmacSHA256(key, scopeService)
+
+	return hmacSHA256(key, scopeTerminator)
+}
+
+// signV4 returns the hex encoded signature of the canonical request cr.
+func signV4(secret string, a *v4Authorization, amzDate string, cr string) string {
+	hash := sha256.Sum256([]byte(cr))
+	sts := strings.Join([]string{sigV4Algorithm, amzDate, a.scope(), hex.EncodeToString(hash[:])}, "\n")
+
+	return hex.EncodeToString(hmacSHA256(signingKey(secret, a), sts))
+}
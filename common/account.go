@@ -0,0 +1,30 @@
+package common
+
+// AnonymousID is the canonical user ID of unauthenticated requests.
+const AnonymousID = "65a011a29cdf8ec533ec3d1ccaae921c"
+
+// Principal is the account a request is made by, as resolved by the request
+// layer. Backends record it as the owner of the buckets, objects and uploads
+// it creates.
+type Principal struct {
+	ID          string // canonical user ID
+	DisplayName string
+}
+
+// Anonymous is the principal of unauthenticated requests.
+var Anonymous = &Principal{ID: AnonymousID, DisplayName: "anonymous"}
+
+// Owner returns the principal as the owner of a resource.
+func (p *Principal) Owner() Owner {
+	return Owner{ID: p.ID, DisplayName: p.DisplayName}
+}
+
+// Owns reports whether the principal is the owner o.
+func (p *Principal) Owns(o Owner) bool {
+	return o.ID == p.ID
+}
+
+// IsAnonymous reports whether the request is unauthenticated.
+func (p *Principal) IsAnonymous() bool {
+	return p.ID == AnonymousID
+}
@@ -0,0 +1,217 @@
+package common
+
+import (
+	"encoding/xml"
+)
+
+// Permissions of ACL grants.
+const (
+	PermissionFullControl = "FULL_CONTROL"
+	PermissionRead        = "READ"
+	PermissionWrite       = "WRITE"
+	PermissionReadACP     = "READ_ACP"
+	PermissionWriteACP    = "WRITE_ACP"
+)
+
+// Grantee types.
+const (
+	GranteeCanonicalUser = "CanonicalUser"
+	GranteeGroup         = "Group"
+	GranteeEmail         = "AmazonCustomerByEmail"
+)
+
+// URIs of the predefined groups.
+const (
+	AllUsers           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	AuthenticatedUsers = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+	LogDelivery        = "http://acs.amazonaws.com/groups/s3/LogDelivery"
+)
+
+const xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+
+// ACL is a canned ACL, as set by the x-amz-acl header.
+type ACL string
+
+const (
+	Private           = ACL("private")
+	PublicRead        = ACL("public-read")
+	PublicReadWrite   = ACL("public-read-write")
+	AuthenticatedRead = ACL("authenticated-read")
+	BucketOwnerRead   = ACL("bucket-owner-read")
+	BucketOwnerFull   = ACL("bucket-owner-full-control")
+	LogDeliveryWrite  = ACL("log-delivery-write")
+)
+
+// Grants returns the grants of the canned ACL for a resource owned by owner in
+// a bucket owned by bucketOwner.
+func (a ACL) Grants(owner Owner, bucketOwner Owner) ([]Grant, *Error) {
+	grants := []Grant{{Grantee: CanonicalUser(owner), Permission: PermissionFullControl}}
+
+	switch a {
+	case Private:
+	case PublicRead:
+		grants = append(grants, Grant{Group(AllUsers), PermissionRead})
+	case PublicReadWrite:
+		grants = append(grants, Grant{Group(AllUsers), PermissionRead}, Grant{Group(AllUsers), PermissionWrite})
+	case AuthenticatedRead:
+		grants = append(grants, Grant{Group(AuthenticatedUsers), PermissionRead})
+	case BucketOwnerRead:
+		if bucketOwner != owner {
+			grants = append(grants, Grant{CanonicalUser(bucketOwner), PermissionRead})
+		}
+	case BucketOwnerFull:
+		if bucketOwner != owner {
+			grants = append(grants, Grant{CanonicalUser(bucketOwner), PermissionFullControl})
+		}
+	case LogDeliveryWrite:
+		grants = append(grants, Grant{Group(LogDelivery), PermissionWrite}, Grant{Group(LogDelivery), PermissionReadACP})
+	default:
+		return nil, &ErrInvalidArgument
+	}
+
+	return grants, nil
+}
+
+// Grantee is the account or group a grant applies to. Its type is the
+// xsi:type attribute of the XML element.
+type Grantee struct {
+	Type         string `xml:"-"`
+	ID           string `xml:",omitempty"`
+	DisplayName  string `xml:",omitempty"`
+	EmailAddress string `xml:",omitempty"`
+	URI          string `xml:",omitempty"`
+}
+
+// CanonicalUser returns the grantee of an account.
+func CanonicalUser(o Owner) Grantee {
+	return Grantee{Type: GranteeCanonicalUser, ID: o.ID, DisplayName: o.DisplayName}
+}
+
+// Group returns the grantee of a predefined group.
+func Group(uri string) Grantee {
+	return Grantee{Type: GranteeGroup, URI: uri}
+}
+
+func (g Grantee) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = append(start.Attr,
+		xml.Attr{Name: xml.Name{Local: "xmlns:xsi"}, Value: xsiNamespace},
+		xml.Attr{Name: xml.Name{Local: "xsi:type"}, Value: g.Type})
+
+	type grantee Grantee
+
+	return e.EncodeElement(grantee(g), start)
+}
+
+func (g *Grantee) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type grantee Grantee
+	v := grantee{}
+
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+
+	*g = Grantee(v)
+
+	for _, a := range start.Attr {
+		if a.Name.Local == "type" {
+			g.Type = a.Value
+		}
+	}
+
+	return nil
+}
+
+// Matches reports whether the grantee includes the principal.
+func (g Grantee) Matches(p *Principal) bool {
+	switch g.Type {
+	case GranteeCanonicalUser:
+		return p.Owns(Owner{ID: g.ID})
+	case GranteeGroup:
+		return g.URI == AllUsers || (g.URI == AuthenticatedUsers && !p.IsAnonymous())
+	}
+
+	return false
+}
+
+type Grant struct {
+	Grantee    Grantee
+	Permission string
+}
+
+// AccessControlPolicy is the ACL of a bucket or an object, the body of GET
+// and PUT ?acl.
+type AccessControlPolicy struct {
+	XMLName xml.Name `xml:"AccessControlPolicy"`
+	Owner   Owner
+	Grants  []Grant `xml:"AccessControlList>Grant"`
+}
+
+// NewAccessControlPolicy returns the ACL of a resource as stored by the
+// backends. Resources stored without grants have the private canned ACL.
+func NewAccessControlPolicy(owner Owner, grants []Grant) *AccessControlPolicy {
+	if grants == nil {
+		grants, _ = Private.Grants(owner, owner)
+	}
+
+	return &AccessControlPolicy{Owner: owner, Grants: grants}
+}
+
+// Allows reports whether the ACL grants the permission to the principal. The
+// owner of a resource may always read and write its ACL.
+func (p *AccessControlPolicy) Allows(principal *Principal, permission string) bool {
+	if (permission == PermissionReadACP || permission == PermissionWriteACP) && principal.Owns(p.Owner) {
+		return true
+	}
+
+	for _, g := range p.Grants {
+		if (g.Permission == permission || g.Permission == PermissionFullControl) && g.Grantee.Matches(principal) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateGrants checks the grants of an ACL set by a client. Grantee types
+// which are omitted are derived from the grantee fields. Accounts can only be
+// granted permissions by their canonical ID, as there are no email
+// addresses on record.
+func ValidateGrants(grants []Grant) *Error {
+	for i := range grants {
+		g := &grants[i]
+
+		switch g.Permission {
+		case PermissionFullControl, PermissionRead, PermissionWrite, PermissionReadACP, PermissionWriteACP:
+		default:
+			return &ErrMalformedACLError
+		}
+
+		if g.Grantee.Type == "" {
+			switch {
+			case g.Grantee.ID != "":
+				g.Grantee.Type = GranteeCanonicalUser
+			case g.Grantee.URI != "":
+				g.Grantee.Type = GranteeGroup
+			case g.Grantee.EmailAddress != "":
+				g.Grantee.Type = GranteeEmail
+			}
+		}
+
+		switch g.Grantee.Type {
+		case GranteeCanonicalUser:
+			if g.Grantee.ID == "" {
+				return &ErrMalformedACLError
+			}
+		case GranteeGroup:
+			if g.Grantee.URI != AllUsers && g.Grantee.URI != AuthenticatedUsers && g.Grantee.URI != LogDelivery {
+				return &ErrInvalidArgument
+			}
+		case GranteeEmail:
+			return &ErrUnresolvableGrantByEmailAddress
+		default:
+			return &ErrMalformedACLError
+		}
+	}
+
+	return nil
+}
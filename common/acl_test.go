@@ -0,0 +1,121 @@
+package common
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var (
+	aliceOwner = Owner{ID: "alice", DisplayName: "alice"}
+	bobOwner   = Owner{ID: "bob", DisplayName: "bob"}
+)
+
+func TestCannedACL(t *testing.T) {
+	alice := &Principal{ID: "alice"}
+	bob := &Principal{ID: "bob"}
+
+	tests := []struct {
+		acl        ACL
+		principal  *Principal
+		permission string
+		allowed    bool
+	}{
+		{Private, alice, PermissionWrite, true},
+		{Private, bob, PermissionRead, false},
+		{Private, Anonymous, PermissionRead, false},
+		{PublicRead, Anonymous, PermissionRead, true},
+		{PublicRead, Anonymous, PermissionWrite, false},
+		{PublicReadWrite, Anonymous, PermissionWrite, true},
+		{AuthenticatedRead, bob, PermissionRead, true},
+		{AuthenticatedRead, Anonymous, PermissionRead, false},
+		{BucketOwnerRead, bob, PermissionRead, true},
+		{BucketOwnerRead, bob, PermissionWrite, false},
+		{BucketOwnerFull, bob, PermissionWriteACP, true},
+	}
+
+	for _, test := range tests {
+		grants, err := test.acl.Grants(aliceOwner, bobOwner)
+
+		if err != nil {
+			t.Fatalf("%s: %v", test.acl, err)
+		}
+
+		if allowed := NewAccessControlPolicy(aliceOwner, grants).Allows(test.principal, test.permission); allowed != test.allowed {
+			t.Errorf("%s: expected %s for %s to be allowed: %v", test.acl, test.permission, test.principal.ID, test.allowed)
+		}
+	}
+
+	if _, err := ACL("unknown").Grants(aliceOwner, aliceOwner); err != &ErrInvalidArgument {
+		t.Errorf("Expected ErrInvalidArgument, got %v", err)
+	}
+}
+
+func TestAccessControlPolicyOwner(t *testing.T) {
+	acl := NewAccessControlPolicy(aliceOwner, []Grant{})
+
+	if acl.Allows(&Principal{ID: "alice"}, PermissionRead) {
+		t.Error("Expected the owner to be denied without grants")
+	}
+
+	if !acl.Allows(&Principal{ID: "alice"}, PermissionWriteACP) {
+		t.Error("Expected the owner to be allowed to write the ACL")
+	}
+
+	ownerless := NewAccessControlPolicy(Owner{}, nil)
+
+	if ownerless.Allows(&Principal{ID: "bob"}, PermissionRead) || ownerless.Allows(&Principal{ID: "bob"}, PermissionWriteACP) {
+		t.Error("Expected resources without owner to belong to no principal")
+	}
+}
+
+func TestAccessControlPolicyXML(t *testing.T) {
+	grants, _ := PublicRead.Grants(aliceOwner, aliceOwner)
+	b, err := xml.Marshal(NewAccessControlPolicy(aliceOwner, grants))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(b), `<Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="Group"><URI>`+AllUsers+`</URI></Grantee>`) {
+		t.Errorf("Unexpected XML %s", b)
+	}
+
+	acp := AccessControlPolicy{}
+
+	if err := xml.Unmarshal(b, &acp); err != nil {
+		t.Fatal(err)
+	}
+
+	if acp.Owner != aliceOwner || !reflect.DeepEqual(acp.Grants, grants) {
+		t.Errorf("Expected %+v, got %+v", grants, acp.Grants)
+	}
+}
+
+func TestValidateGrants(t *testing.T) {
+	tests := []struct {
+		grant Grant
+		err   *Error
+	}{
+		{Grant{Grantee{ID: "bob"}, PermissionRead}, nil},
+		{Grant{Grantee{URI: AuthenticatedUsers}, PermissionWrite}, nil},
+		{Grant{Grantee{ID: "bob"}, "EVERYTHING"}, &ErrMalformedACLError},
+		{Grant{Grantee{}, PermissionRead}, &ErrMalformedACLError},
+		{Grant{Grantee{URI: "http://example.com/group"}, PermissionRead}, &ErrInvalidArgument},
+		{Grant{Grantee{EmailAddress: "bob@example.com"}, PermissionRead}, &ErrUnresolvableGrantByEmailAddress},
+	}
+
+	for _, test := range tests {
+		if err := ValidateGrants([]Grant{test.grant}); err != test.err {
+			t.Errorf("%+v: expected %v, got %v", test.grant, test.err, err)
+		}
+	}
+
+	grants := []Grant{{Grantee{ID: "bob"}, PermissionRead}}
+	ValidateGrants(grants)
+
+	if grants[0].Grantee.Type != GranteeCanonicalUser {
+		t.Errorf("Expected the grantee type to be derived, got %q", grants[0].Grantee.Type)
+	}
+}
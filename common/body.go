@@ -0,0 +1,91 @@
+package common
+
+import (
+	"bytes"
+	"hash"
+	"io"
+	"log"
+)
+
+// digestReader checks the digest of a request body while it is read.
+type digestReader struct {
+	io.ReadCloser
+	h        hash.Hash
+	sum      []byte
+	mismatch *Error
+}
+
+// NewDigestReader returns a reader which computes the digest h of r. At the
+// end of r it returns mismatch instead of io.EOF if the digest is not sum, so
+// that the contents are never stored.
+func NewDigestReader(r io.ReadCloser, h hash.Hash, sum []byte, mismatch *Error) io.ReadCloser {
+	return &digestReader{ReadCloser: r, h: h, sum: sum, mismatch: mismatch}
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.ReadCloser.Read(p)
+	d.h.Write(p[:n])
+
+	if err == io.EOF && !bytes.Equal(d.h.Sum(nil), d.sum) {
+		return n, d.mismatch
+	}
+
+	return n, err
+}
+
+// lengthReader checks the length of a body of unknown size while it is read.
+type lengthReader struct {
+	r        io.Reader
+	n        int64
+	min, max int64
+}
+
+// NewLengthReader returns a reader of r which fails with ErrEntityTooLarge
+// once r exceeds max bytes, and with ErrEntityTooSmall instead of io.EOF if r
+// ends before min bytes, so that the contents are never stored.
+func NewLengthReader(r io.Reader, min int64, max int64) io.Reader {
+	return &lengthReader{r: r, min: min, max: max}
+}
+
+func (l *lengthReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+
+	if l.n > l.max {
+		return n, &ErrEntityTooLarge
+	}
+
+	if err == io.EOF && l.n < l.min {
+		return n, &ErrEntityTooSmall
+	}
+
+	return n, err
+}
+
+// CheckBodySize returns ErrIncompleteBody if a body of n bytes is shorter
+// than the size it was announced with. A size of -1 is unknown.
+func CheckBodySize(n int64, size int64) *Error {
+	if size >= 0 && n < size {
+		return &ErrIncompleteBody
+	}
+
+	return nil
+}
+
+// BodyError returns the error to report for a failed read of a request body.
+// Readers which verify the body fail with an *Error, which is passed on, and
+// bodies ending before their Content-Length are incomplete. Any other error is
+// logged and reported as an internal error.
+func BodyError(err error) *Error {
+	if awserr, ok := err.(*Error); ok {
+		return awserr
+	}
+
+	if err == io.ErrUnexpectedEOF {
+		return &ErrIncompleteBody
+	}
+
+	log.Printf("Reading request body failed: %v", err)
+
+	return &ErrInternalError
+}
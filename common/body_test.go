@@ -0,0 +1,73 @@
+package common
+
+import (
+	"crypto/md5"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestDigestReader(t *testing.T) {
+	sum := md5.Sum([]byte("contents"))
+
+	tests := []struct {
+		body string
+		err  *Error
+	}{
+		{"contents", nil},
+		{"changed", &ErrBadDigest},
+	}
+
+	for _, test := range tests {
+		r := NewDigestReader(ioutil.NopCloser(strings.NewReader(test.body)), md5.New(), sum[:], &ErrBadDigest)
+
+		if _, err := ioutil.ReadAll(r); err != nil && BodyError(err) != test.err || err == nil && test.err != nil {
+			t.Errorf("%s: expected %v, got %v", test.body, test.err, err)
+		}
+	}
+}
+
+func TestBodyErrors(t *testing.T) {
+	if err := BodyError(io.ErrUnexpectedEOF); err != &ErrIncompleteBody {
+		t.Errorf("Expected ErrIncompleteBody for a truncated body, got %v", err)
+	}
+
+	if err := BodyError(&ErrBadDigest); err != &ErrBadDigest {
+		t.Errorf("Expected the error of the reader, got %v", err)
+	}
+
+	tests := []struct {
+		n, size int64
+		err     *Error
+	}{
+		{3, 3, nil},
+		{3, -1, nil},
+		{2, 3, &ErrIncompleteBody},
+	}
+
+	for _, test := range tests {
+		if err := CheckBodySize(test.n, test.size); err != test.err {
+			t.Errorf("%d of %d bytes: expected %v, got %v", test.n, test.size, test.err, err)
+		}
+	}
+}
+
+func TestLengthReader(t *testing.T) {
+	tests := []struct {
+		body string
+		err  *Error
+	}{
+		{"contents", nil},
+		{"body", &ErrEntityTooSmall},
+		{"long contents", &ErrEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		r := NewLengthReader(strings.NewReader(test.body), 5, 10)
+
+		if _, err := ioutil.ReadAll(r); err != nil && BodyError(err) != test.err || err == nil && test.err != nil {
+			t.Errorf("%s: expected %v, got %v", test.body, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package common
+
+import (
+	"net"
+	"strings"
+)
+
+// Limits of S3 on the length of bucket names.
+const (
+	MinBucketNameLength = 3
+	MaxBucketNameLength = 63
+)
+
+// ValidateBucketName checks a bucket name against the rules of S3: lowercase
+// letters, digits, dots and hyphens, beginning and ending with a letter or a
+// digit, without adjacent dots, and not formatted like an IP address. As
+// buckets are stored in directories named after them, this also rules out
+// names like "." and "..".
+func ValidateBucketName(name string) *Error {
+	if len(name) < MinBucketNameLength || len(name) > MaxBucketNameLength {
+		return &ErrInvalidBucketName
+	}
+
+	for i, c := range name {
+		alnum := c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+
+		if !alnum && (c != '.' && c != '-' || i == 0 || i == len(name)-1) {
+			return &ErrInvalidBucketName
+		}
+	}
+
+	if strings.Contains(name, "..") || net.ParseIP(name) != nil {
+		return &ErrInvalidBucketName
+	}
+
+	return nil
+}
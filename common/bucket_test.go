@@ -0,0 +1,35 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateBucketName(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *Error
+	}{
+		{"bucket", nil},
+		{"my-bucket.example.com", nil},
+		{"123", nil},
+		{strings.Repeat("b", 63), nil},
+		{"ab", &ErrInvalidBucketName},
+		{strings.Repeat("b", 64), &ErrInvalidBucketName},
+		{"Bucket", &ErrInvalidBucketName},
+		{"my_bucket", &ErrInvalidBucketName},
+		{"-bucket", &ErrInvalidBucketName},
+		{"bucket.", &ErrInvalidBucketName},
+		{"my..bucket", &ErrInvalidBucketName},
+		{"192.168.5.4", &ErrInvalidBucketName},
+		{".", &ErrInvalidBucketName},
+		{"..", &ErrInvalidBucketName},
+		{"...", &ErrInvalidBucketName},
+	}
+
+	for _, test := range tests {
+		if err := ValidateBucketName(test.name); err != test.err {
+			t.Errorf("%q: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package common
+
+import (
+	"strings"
+	"time"
+)
+
+// Conditions are the preconditions of a request on the ETag and the last
+// modification time of an object. Empty ETags and zero times are not set.
+type Conditions struct {
+	IfMatch           string
+	IfNoneMatch       string
+	IfModifiedSince   time.Time
+	IfUnmodifiedSince time.Time
+}
+
+// IsZero reports whether no condition is set.
+func (c *Conditions) IsZero() bool {
+	return c == nil || *c == Conditions{}
+}
+
+// Check evaluates the conditions of a read of the object described by info
+// with the precedence rules of S3: If-Match overrides If-Unmodified-Since and
+// If-None-Match overrides If-Modified-Since. It returns ErrPreconditionFailed
+// if If-Match or If-Unmodified-Since fail and ErrNotModified if If-None-Match
+// or If-Modified-Since fail. Times are compared in seconds, the precision of
+// HTTP dates.
+func (c *Conditions) Check(info *ObjectInfo) *Error {
+	if c.IsZero() {
+		return nil
+	}
+
+	modified := info.LastModified.Truncate(time.Second)
+
+	if c.IfMatch != "" {
+		if !etagMatches(c.IfMatch, info.ETag) {
+			return &ErrPreconditionFailed
+		}
+	} else if !c.IfUnmodifiedSince.IsZero() && modified.After(c.IfUnmodifiedSince) {
+		return &ErrPreconditionFailed
+	}
+
+	if c.IfNoneMatch != "" {
+		if etagMatches(c.IfNoneMatch, info.ETag) {
+			return &ErrNotModified
+		}
+	} else if !c.IfModifiedSince.IsZero() && !modified.After(c.IfModifiedSince) {
+		return &ErrNotModified
+	}
+
+	return nil
+}
+
+// CheckCopySource evaluates the x-amz-copy-source-if-* conditions of a copy
+// on its source like the conditions of a read, except that all failures are
+// reported as ErrPreconditionFailed.
+func (c *Conditions) CheckCopySource(src *ObjectInfo) *Error {
+	if awserr := c.Check(src); awserr != nil {
+		return &ErrPreconditionFailed
+	}
+
+	return nil
+}
+
+// CheckWrite evaluates the conditions of a write on the current version of
+// the key, nil if there is none or it is a delete marker. If-None-Match: *
+// fails with ErrPreconditionFailed if there is a current version, If-Match
+// with ErrNoSuchKey if there is none and with ErrPreconditionFailed if its
+// ETag does not match. The times are not evaluated for writes.
+func (c *Conditions) CheckWrite(current *ObjectInfo) *Error {
+	if c.IsZero() {
+		return nil
+	}
+
+	if c.IfNoneMatch != "" && current != nil {
+		return &ErrPreconditionFailed
+	}
+
+	if c.IfMatch != "" && current == nil {
+		return &ErrNoSuchKey
+	} else if c.IfMatch != "" && !etagMatches(c.IfMatch, current.ETag) {
+		return &ErrPreconditionFailed
+	}
+
+	return nil
+}
+
+// etagMatches reports whether the comma-separated list of ETags in header,
+// or "*", matches etag. Quotes and weak prefixes are ignored.
+func etagMatches(header string, etag string) bool {
+	for _, e := range strings.Split(header, ",") {
+		e = strings.TrimPrefix(strings.TrimSpace(e), "W/")
+
+		if e == "*" || strings.Trim(e, `"`) == strings.Trim(etag, `"`) {
+			return true
+		}
+	}
+
+	return false
+}
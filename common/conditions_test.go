@@ -0,0 +1,68 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConditionsCheck(t *testing.T) {
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 600, time.UTC)
+	info := &ObjectInfo{ETag: `"abc"`, LastModified: modified}
+	before, after := modified.Add(-time.Hour), modified.Add(time.Hour)
+	same := modified.Truncate(time.Second)
+
+	tests := []struct {
+		c   *Conditions
+		err *Error
+	}{
+		{nil, nil},
+		{&Conditions{}, nil},
+		{&Conditions{IfMatch: `"abc"`}, nil},
+		{&Conditions{IfMatch: `"xyz", "abc"`}, nil},
+		{&Conditions{IfMatch: "*"}, nil},
+		{&Conditions{IfMatch: `"xyz"`}, &ErrPreconditionFailed},
+		{&Conditions{IfNoneMatch: `"abc"`}, &ErrNotModified},
+		{&Conditions{IfNoneMatch: `W/"abc"`}, &ErrNotModified},
+		{&Conditions{IfNoneMatch: `"xyz"`}, nil},
+		{&Conditions{IfModifiedSince: same}, &ErrNotModified},
+		{&Conditions{IfModifiedSince: before}, nil},
+		{&Conditions{IfUnmodifiedSince: same}, nil},
+		{&Conditions{IfUnmodifiedSince: before}, &ErrPreconditionFailed},
+		// If-Match overrides If-Unmodified-Since and If-None-Match overrides
+		// If-Modified-Since.
+		{&Conditions{IfMatch: `"abc"`, IfUnmodifiedSince: before}, nil},
+		{&Conditions{IfNoneMatch: `"xyz"`, IfModifiedSince: after}, nil},
+		{&Conditions{IfMatch: `"xyz"`, IfNoneMatch: `"abc"`}, &ErrPreconditionFailed},
+		{&Conditions{IfUnmodifiedSince: after, IfModifiedSince: after}, &ErrNotModified},
+	}
+
+	for i, test := range tests {
+		if err := test.c.Check(info); err != test.err {
+			t.Errorf("%d: expected %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestConditionsCheckWrite(t *testing.T) {
+	current := &ObjectInfo{ETag: `"abc"`}
+
+	tests := []struct {
+		c       *Conditions
+		current *ObjectInfo
+		err     *Error
+	}{
+		{nil, current, nil},
+		{&Conditions{IfNoneMatch: "*"}, nil, nil},
+		{&Conditions{IfNoneMatch: "*"}, current, &ErrPreconditionFailed},
+		{&Conditions{IfMatch: `"abc"`}, current, nil},
+		{&Conditions{IfMatch: `"xyz"`}, current, &ErrPreconditionFailed},
+		{&Conditions{IfMatch: `"abc"`}, nil, &ErrNoSuchKey},
+		{&Conditions{IfModifiedSince: time.Now()}, current, nil},
+	}
+
+	for i, test := range tests {
+		if err := test.c.CheckWrite(test.current); err != test.err {
+			t.Errorf("%d: expected %v, got %v", i, test.err, err)
+		}
+	}
+}
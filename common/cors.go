@@ -0,0 +1,129 @@
+package common
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// MaxCORSRules is the maximum number of rules of a CORS configuration.
+const MaxCORSRules = 100
+
+// CORSConfiguration is the body of PUT and GET Bucket cors.
+type CORSConfiguration struct {
+	XMLName   xml.Name   `xml:"CORSConfiguration" json:"-"`
+	CORSRules []CORSRule `xml:"CORSRule"`
+}
+
+// CORSRule allows cross-origin requests from the matching origins with the
+// allowed methods and request headers. Origins and headers may contain a
+// single * wildcard.
+type CORSRule struct {
+	ID             string   `xml:",omitempty"`
+	AllowedOrigins []string `xml:"AllowedOrigin"`
+	AllowedMethods []string `xml:"AllowedMethod"`
+	AllowedHeaders []string `xml:"AllowedHeader,omitempty"`
+	ExposeHeaders  []string `xml:"ExposeHeader,omitempty"`
+	MaxAgeSeconds  int      `xml:",omitempty"`
+}
+
+// Validate checks a configuration set by a client.
+func (c *CORSConfiguration) Validate() *Error {
+	if len(c.CORSRules) == 0 || len(c.CORSRules) > MaxCORSRules {
+		return &ErrMalformedXML
+	}
+
+	for _, rule := range c.CORSRules {
+		if len(rule.AllowedOrigins) == 0 || len(rule.AllowedMethods) == 0 || len(rule.ID) > 255 || rule.MaxAgeSeconds < 0 {
+			return &ErrMalformedXML
+		}
+
+		for _, m := range rule.AllowedMethods {
+			switch m {
+			case "GET", "PUT", "HEAD", "POST", "DELETE":
+			default:
+				return &ErrInvalidCORSMethod
+			}
+		}
+
+		for _, o := range rule.AllowedOrigins {
+			if strings.Count(o, "*") > 1 {
+				return &ErrInvalidCORSOrigin
+			}
+		}
+
+		for _, h := range rule.AllowedHeaders {
+			if strings.Count(h, "*") > 1 {
+				return &ErrInvalidCORSHeader
+			}
+		}
+	}
+
+	return nil
+}
+
+// Match returns the first rule which allows a request from the origin with the
+// method and request headers, or nil if there is none.
+func (c *CORSConfiguration) Match(origin string, method string, headers []string) *CORSRule {
+	for i := range c.CORSRules {
+		if rule := &c.CORSRules[i]; rule.allows(origin, method, headers) {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+func (rule *CORSRule) allows(origin string, method string, headers []string) bool {
+	if !matchAnyCORS(rule.AllowedOrigins, origin) {
+		return false
+	}
+
+	found := false
+
+	for _, m := range rule.AllowedMethods {
+		found = found || m == method
+	}
+
+	if !found {
+		return false
+	}
+
+	for _, h := range headers {
+		if !matchAnyCORS(rule.AllowedHeaders, h) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AllowsAnyOrigin reports whether the rule applies to every origin, in which
+// case responses allow the origin *.
+func (rule *CORSRule) AllowsAnyOrigin() bool {
+	for _, o := range rule.AllowedOrigins {
+		if o == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchAnyCORS matches s case-insensitively against patterns with a single
+// * wildcard.
+func matchAnyCORS(patterns []string, s string) bool {
+	s = strings.ToLower(s)
+
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		i := strings.Index(p, "*")
+
+		if i < 0 && p == s {
+			return true
+		} else if i >= 0 && len(s) >= len(p)-1 && strings.HasPrefix(s, p[:i]) && strings.HasSuffix(s, p[i+1:]) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,59 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestCORSValidate(t *testing.T) {
+	rule := func(origin string, method string, header string) CORSConfiguration {
+		return CORSConfiguration{CORSRules: []CORSRule{{AllowedOrigins: []string{origin}, AllowedMethods: []string{method}, AllowedHeaders: []string{header}}}}
+	}
+
+	tests := []struct {
+		cors CORSConfiguration
+		err  *Error
+	}{
+		{rule("https://*.example.com", "PUT", "x-amz-*"), nil},
+		{CORSConfiguration{}, &ErrMalformedXML},
+		{CORSConfiguration{CORSRules: []CORSRule{{AllowedMethods: []string{"GET"}}}}, &ErrMalformedXML},
+		{rule("*", "PATCH", ""), &ErrInvalidCORSMethod},
+		{rule("*://*", "GET", ""), &ErrInvalidCORSOrigin},
+		{rule("*", "GET", "*-*"), &ErrInvalidCORSHeader},
+	}
+
+	for _, test := range tests {
+		if err := test.cors.Validate(); err != test.err {
+			t.Errorf("%+v: expected %v, got %v", test.cors, test.err, err)
+		}
+	}
+}
+
+func TestCORSMatch(t *testing.T) {
+	cors := CORSConfiguration{CORSRules: []CORSRule{
+		{ID: "upload", AllowedOrigins: []string{"https://*.example.com"}, AllowedMethods: []string{"PUT", "POST"}, AllowedHeaders: []string{"Content-*", "x-amz-acl"}},
+		{ID: "read", AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}},
+	}}
+
+	tests := []struct {
+		origin  string
+		method  string
+		headers []string
+		id      string
+	}{
+		{"https://app.example.com", "PUT", []string{"content-type", "X-Amz-Acl"}, "upload"},
+		{"https://app.example.com", "PUT", []string{"x-amz-meta-a"}, ""},
+		{"http://app.example.com", "PUT", nil, ""},
+		{"https://example.com", "PUT", nil, ""},
+		{"http://anywhere", "GET", nil, "read"},
+		{"http://anywhere", "GET", []string{"range"}, ""},
+		{"https://app.example.com", "DELETE", nil, ""},
+	}
+
+	for _, test := range tests {
+		rule := cors.Match(test.origin, test.method, test.headers)
+
+		if (rule == nil && test.id != "") || (rule != nil && rule.ID != test.id) {
+			t.Errorf("%s %s %v: expected rule %q, got %+v", test.method, test.origin, test.headers, test.id, rule)
+		}
+	}
+}
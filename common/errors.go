@@ -22,12 +22,23 @@ var (
 	ErrAccessDenied                            = Error{http.StatusForbidden, "AccessDenied", "Access Denied", "", "", ""}
 	ErrAccountProblem                          = Error{http.StatusForbidden, "AccountProblem", "There is a problem with your AWS account that prevents the operation from completing successfully.", "", "", ""}
 	ErrAmbigiousGrantByEmailAddress            = Error{http.StatusBadRequest, "AmbiguousGrantByEmailAddress", "The email address you provided is associated with more than one account.", "", "", ""}
+	ErrAuthorizationHeaderMalformed            = Error{http.StatusBadRequest, "AuthorizationHeaderMalformed", "The authorization header is malformed.", "", "", ""}
+	ErrAuthorizationMechanismNotSupported      = Error{http.StatusBadRequest, "InvalidRequest", "The authorization mechanism you have provided is not supported.", "", "", ""}
+	ErrAuthorizationQueryParametersError       = Error{http.StatusBadRequest, "AuthorizationQueryParametersError", "The authorization query parameters are malformed.", "", "", ""}
 	ErrBadDigest                               = Error{http.StatusBadRequest, "BadDigest", "The Content-MD5 you specified did not match what we received.", "", "", ""}
 	ErrBucketAlreadyExists                     = Error{http.StatusConflict, "BucketAlreadyExists", "The requested bucket name is not available. The bucket namespace is shared by all users of the system. Please select a different name and try again.", "", "", ""}
 	ErrBucketAlreadyOwnedByYou                 = Error{http.StatusConflict, "BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it. You get this error in all AWS regions except US Standard, us-east-1. In us-east-1 region, you will get 200 OK, but it is no-op (if bucket exists it Amazon S3 will not do anything).", "", "", ""}
 	ErrBucketNotEmpty                          = Error{http.StatusConflict, "BucketNotEmpty", "The bucket you tried to delete is not empty.", "", "", ""}
+	ErrBucketTagCount                          = Error{http.StatusBadRequest, "InvalidTag", "Bucket tag count cannot be greater than 50", "", "", ""}
+	ErrCannedACLWithGrants                     = Error{http.StatusBadRequest, "InvalidRequest", "Specifying both Canned ACLs and Header Grants is not allowed", "", "", ""}
+	ErrConditionalRequestConflict              = Error{http.StatusConflict, "ConditionalRequestConflict", "A conflicting conditional operation is currently in progress against this resource. Please try again.", "", "", ""}
+	ErrCORSForbidden                           = Error{http.StatusForbidden, "AccessForbidden", "CORSResponse: This CORS request is not allowed. This is usually because the evalution of Origin, request method / Access-Control-Request-Method or Access-Control-Request-Headers are not whitelisted by the resource's CORS spec.", "", "", ""}
+	ErrCORSMissingMethod                       = Error{http.StatusBadRequest, "BadRequest", "Invalid Access-Control-Request-Method: null", "", "", ""}
+	ErrCORSMissingOrigin                       = Error{http.StatusBadRequest, "BadRequest", "Insufficient information. Origin request header needed.", "", "", ""}
+	ErrCORSNotEnabled                          = Error{http.StatusForbidden, "AccessForbidden", "CORSResponse: CORS is not enabled for this bucket.", "", "", ""}
 	ErrCredentialsNotSupported                 = Error{http.StatusBadRequest, "CredentialsNotSupported", "This request does not support credentials.	", "", "", ""}
 	ErrCrossLocationLoggingProhibited          = Error{http.StatusForbidden, "CrossLocationLoggingProhibited", "Cross-location logging not allowed. Buckets in one geographic location cannot log information to a bucket in another location.	", "", "", ""}
+	ErrDuplicateTagKey                         = Error{http.StatusBadRequest, "InvalidTag", "Cannot provide multiple Tags with the same key", "", "", ""}
 	ErrEntityTooSmall                          = Error{http.StatusBadRequest, "EntityTooSmall", "Your proposed upload is smaller than the minimum allowed object size.	", "", "", ""}
 	ErrEntityTooLarge                          = Error{http.StatusBadRequest, "EntityTooLarge", "Your proposed upload exceeds the maximum allowed object size.", "", "", ""}
 	ErrExpiredToken                            = Error{http.StatusBadRequest, "ExpiredToken", "The provided token has expired.", "", "", ""}
@@ -40,26 +51,44 @@ var (
 	ErrInvalidAddressingHeader                 = Error{0, "InvalidAddressingHeader", "You must specify the Anonymous role.", "", "", ""} // Unclear HTTP Status Code???
 	ErrInvalidArgument                         = Error{http.StatusBadRequest, "InvalidArgument", "Invalid Argument", "", "", ""}
 	ErrInvalidBucketName                       = Error{http.StatusBadRequest, "InvalidBucketName", "The specified bucket is not valid.", "", "", ""}
+	ErrInvalidCopyRequest                      = Error{http.StatusBadRequest, "InvalidRequest", "This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata, storage class, website redirect location or encryption attributes.", "", "", ""}
+	ErrInvalidCopySource                       = Error{http.StatusBadRequest, "InvalidArgument", "Copy Source must mention the source bucket and key: sourcebucket/sourcekey", "", "", ""}
+	ErrInvalidCopySourceRange                  = Error{http.StatusBadRequest, "InvalidArgument", "The x-amz-copy-source-range value must be of the form bytes=first-last where first and last are the zero-based offsets of the first and last bytes to copy", "", "", ""}
+	ErrInvalidAuthorizationType                = Error{http.StatusBadRequest, "InvalidArgument", "Unsupported Authorization Type", "", "", ""}
+	ErrInvalidContinuationToken                = Error{http.StatusBadRequest, "InvalidArgument", "The continuation token provided is incorrect", "", "", ""}
 	ErrInvalidBucketState                      = Error{http.StatusConflict, "InvalidBucketState", "The request is not valid with the current state of the bucket.", "", "", ""}
+	ErrInvalidCORSHeader                       = Error{http.StatusBadRequest, "InvalidRequest", "AllowedHeader can not have more than one wildcard.", "", "", ""}
+	ErrInvalidCORSMethod                       = Error{http.StatusBadRequest, "InvalidRequest", "Found unsupported HTTP method in CORS config.", "", "", ""}
+	ErrInvalidCORSOrigin                       = Error{http.StatusBadRequest, "InvalidRequest", "AllowedOrigin can not have more than one wildcard.", "", "", ""}
 	ErrInvalidDigest                           = Error{http.StatusBadRequest, "InvalidDigest", "The Content-MD5 you specified is not valid.", "", "", ""}
 	ErrInvalidEncryptionAlgorithmError         = Error{http.StatusBadRequest, "InvalidEncryptionAlgorithmError", "The encryption request you specified is not valid. The valid value is AES256", "", "", ""}
 	ErrInvalidLocationConstraint               = Error{http.StatusBadRequest, "InvalidLocationConstraint", "", "", "", ""}
 	ErrInvalidObjectState                      = Error{http.StatusForbidden, "InvalidObjectState", "The operation is not valid for the current state of the object.	", "", "", ""}
 	ErrInvalidPart                             = Error{http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found. The part might not have been uploaded, or the specified entity tag might not have matched the part's entity tag.	", "", "", ""}
 	ErrInvalidPartOrder                        = Error{http.StatusBadRequest, "InvalidPartOrder", "The list of parts was not in ascending order.Parts list must specified in order by part number.	", "", "", ""}
+	ErrInvalidPartNumber                       = Error{http.StatusRequestedRangeNotSatisfiable, "InvalidPartNumber", "The requested partnumber is not satisfiable.", "", "", ""}
 	ErrInvalidPayer                            = Error{http.StatusForbidden, "InvalidPayer", "All access to this object has been disabled.", "", "", ""}
 	ErrInvalidPolicyDocument                   = Error{http.StatusBadRequest, "InvalidPolicyDocument", "The content of the form does not meet the conditions specified in the policy document.	", "", "", ""}
+	ErrPostPolicyExpired                       = Error{http.StatusForbidden, "AccessDenied", "Invalid according to Policy: Policy expired.", "", "", ""}
+	ErrPostPolicyConditionFailed               = Error{http.StatusForbidden, "AccessDenied", "Invalid according to Policy: Policy Condition failed.", "", "", ""}
+	ErrPostPolicyExtraFields                   = Error{http.StatusForbidden, "AccessDenied", "Invalid according to Policy: Extra input fields.", "", "", ""}
+	ErrPostMissingKey                          = Error{http.StatusBadRequest, "InvalidArgument", "Bucket POST must contain a field named 'key'.  If it is specified, please check the order of the fields.", "", "", ""}
 	ErrInvalidRange                            = Error{http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "The requested range cannot be satisfied.	", "", "", ""}
 	ErrInvalidSecurity                         = Error{http.StatusBadRequest, "InvalidSecurity", "The provided security credentials are not valid.	", "", "", ""}
 	ErrInvalidSOAPRequest                      = Error{http.StatusBadRequest, "InvalidSOAPRequest", "The SOAP request body is invalid.	", "", "", ""}
 	ErrInvalidRequest                          = Error{http.StatusBadRequest, "InvalidRequest", "SOAP requests must be made over an HTTPS connection", "", "", ""}
 	ErrInvalidStorageClass                     = Error{http.StatusBadRequest, "InvalidStorageClass", "The storage class you specified is not valid.	", "", "", ""}
+	ErrInvalidTag                              = Error{http.StatusBadRequest, "InvalidTag", "The tag provided was not a valid tag. This error can occur if the tag did not pass input validation.", "", "", ""}
+	ErrInvalidTagKey                           = Error{http.StatusBadRequest, "InvalidTag", "The TagKey you have provided is invalid", "", "", ""}
 	ErrInvalidTargetBucketForLogging           = Error{http.StatusBadRequest, "InvalidTargetBucketForLogging", "The target bucket for logging does not exist, is not owned by you, or does not have the appropriate grants for the log-delivery group.	", "", "", ""}
 	ErrInvalidToken                            = Error{http.StatusBadRequest, "InvalidToken", "The provided token is malformed or otherwise invalid.	", "", "", ""}
 	ErrInvalidURI                              = Error{http.StatusBadRequest, "InvalidURI", "Couldn't parse the specified URI.	", "", "", ""}
 	ErrKeyTooLong                              = Error{http.StatusBadRequest, "KeyTooLong", "Your key is too long.	", "", "", ""}
+	ErrLifecycleTagFilterWithAbort             = Error{http.StatusBadRequest, "InvalidRequest", "Tag-based filter cannot be used with AbortIncompleteMultipartUpload action", "", "", ""}
 	ErrMalformedACLError                       = Error{http.StatusBadRequest, "MalformedACLError", "The XML you provided was not well-formed or did not validate against our published schema.	", "", "", ""}
 	ErrMalformedPOSTRequest                    = Error{http.StatusBadRequest, "MalformedPOSTRequest", "The body of your POST request is not well-formed multipart/form-data.	", "", "", ""}
+	ErrMalformedPolicy                         = Error{http.StatusBadRequest, "MalformedPolicy", "Policies must be valid JSON and the first byte must be '{'", "", "", ""}
+	ErrMalformedPolicyDocumentSize             = Error{http.StatusBadRequest, "MalformedPolicy", "Policy exceeds the maximum allowed document size", "", "", ""}
 	ErrMalformedXML                            = Error{http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema.", "", "", ""}
 	ErrMaxMessageLengthExceeded                = Error{http.StatusBadRequest, "MaxMessageLengthExceeded", "Your request was too big.	", "", "", ""}
 	ErrMaxPostPreDataLengthExceededError       = Error{http.StatusBadRequest, "MaxPostPreDataLengthExceededError", "Your POST request fields preceding the upload file were too large.	", "", "", ""}
@@ -67,23 +96,31 @@ var (
 	ErrMethodNotAllowed                        = Error{http.StatusMethodNotAllowed, "MethodNotAllowed", "he specified method is not allowed against this resource.", "", "", ""}
 	ErrMissingAttachment                       = Error{0, "MissingAttachment", "A SOAP attachment was expected, but none were found.", "", "", ""} //???
 	ErrMissingContentLength                    = Error{http.StatusLengthRequired, "MissingContentLength", "You must provide the Content-Length HTTP header.", "", "", ""}
+	ErrMissingContentMD5                       = Error{http.StatusBadRequest, "InvalidRequest", "Missing required header for this request: Content-MD5", "", "", ""}
+	ErrMissingContentSHA256                    = Error{http.StatusBadRequest, "InvalidRequest", "Missing required header for this request: x-amz-content-sha256", "", "", ""}
 	ErrMissingRequestBodyError                 = Error{http.StatusBadRequest, "MissingRequestBodyError", "Request body is empty.", "", "", ""}
 	ErrMissingSecurityElement                  = Error{http.StatusBadRequest, "MissingSecurityElement", "The SOAP 1.1 request is missing a security element", "", "", ""}
 	ErrMissingSecurityHeader                   = Error{http.StatusBadRequest, "MissingSecurityHeader", "Your request is missing a required header.	", "", "", ""}
 	ErrNoLoggingStatusForKey                   = Error{http.StatusBadRequest, "NoLoggingStatusForKey", "There is no such thing as a logging status subresource for a key.	", "", "", ""}
 	ErrNoSuchBucket                            = Error{http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist.	", "", "", ""}
+	ErrNoSuchCORSConfiguration                 = Error{http.StatusNotFound, "NoSuchCORSConfiguration", "The CORS configuration does not exist", "", "", ""}
 	ErrNoSuchKey                               = Error{http.StatusNotFound, "NoSuchKey", "The specified key does not exist.", "", "", ""}
 	ErrNoSuchLifecycleConfiguration            = Error{http.StatusNotFound, "NoSuchLifecycleConfiguration", "The lifecycle configuration does not exist.	", "", "", ""}
+	ErrNoSuchTagSet                            = Error{http.StatusNotFound, "NoSuchTagSet", "The TagSet does not exist", "", "", ""}
 	ErrNoSuchUpload                            = Error{http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist. The upload ID might be invalid, or the multipart upload might have been aborted or completed.	", "", "", ""}
 	ErrNoSuchVersion                           = Error{http.StatusNotFound, "NoSuchVersion", "Indicates that the version ID specified in the request does not match an existing version", "", "", ""}
 	ErrNotImplemented                          = Error{http.StatusNotImplemented, "NotImplemented", "A header you provided implies functionality that is not implemented.", "", "", ""}
+	ErrNotModified                             = Error{http.StatusNotModified, "NotModified", "Not Modified", "", "", ""}
 	ErrNotSignedUp                             = Error{http.StatusForbidden, "NotSignedUp", "Your account is not signed up for the Amazon S3 service. You must sign up before you can use Amazon S3. ", "", "", ""}
-	ErrNoSuchBucketPolicy                      = Error{http.StatusForbidden, "NoSuchBucketPolicy", "The specified bucket does not have a bucket policy.", "", "", ""}
+	ErrObjectTagCount                          = Error{http.StatusBadRequest, "BadRequest", "Object tags cannot be greater than 10", "", "", ""}
+	ErrNoSuchBucketPolicy                      = Error{http.StatusNotFound, "NoSuchBucketPolicy", "The specified bucket does not have a bucket policy.", "", "", ""}
 	ErrOperationAborted                        = Error{http.StatusConflict, "OperationAborted", "A conflicting conditional operation is currently in progress against this resource. Try again.", "", "", ""}
 	ErrPermanentRedirect                       = Error{http.StatusMovedPermanently, "PermanentRedirect", "The bucket you are attempting to access must be addressed using the specified endpoint. Send all future requests to this endpoint.", "", "", ""}
 	ErrPreconditionFailed                      = Error{http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the preconditions you specified did not hold.", "", "", ""}
 	ErrRedirect                                = Error{307, "Redirect", "Temporary redirect.	", "", "", ""}
+	ErrRangeWithPartNumber                     = Error{http.StatusBadRequest, "InvalidRequest", "Cannot specify both Range header and partNumber query parameter.", "", "", ""}
 	ErrRestoreAlreadyInProgress                = Error{http.StatusConflict, "RestoreAlreadyInProgress", "Object restore is already in progress.", "", "", ""}
+	ErrRequestExpired                          = Error{http.StatusForbidden, "AccessDenied", "Request has expired", "", "", ""}
 	ErrRequestIsNotMultiPartContent            = Error{http.StatusBadRequest, "RequestIsNotMultiPartContent", "Bucket POST must be of the enclosure-type multipart/form-data.	", "", "", ""}
 	ErrRequestTimeout                          = Error{http.StatusBadRequest, "RequestTimeout", "Your socket connection to the server was not read from or written to within the timeout period.", "", "", ""}
 	ErrRequestTimeTooSkewed                    = Error{http.StatusForbidden, "RequestTimeTooSkewed", "The difference between the request time and the server's time is too large.", "", "", ""}
@@ -91,10 +128,13 @@ var (
 	ErrSignatureDoesNotMatch                   = Error{http.StatusForbidden, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided. Check your AWS secret access key and signing method", "", "", ""}
 	ErrServiceUnavailable                      = Error{http.StatusServiceUnavailable, "ServiceUnavailable", "Reduce your request rate.	", "", "", ""}
 	ErrSlowDown                                = Error{http.StatusServiceUnavailable, "SlowDown", "Reduce your request rate.", "", "", ""}
+	ErrTagKeyTooLong                           = Error{http.StatusBadRequest, "InvalidTag", "The TagKey you have provided is too long, max 128", "", "", ""}
+	ErrTagValueTooLong                         = Error{http.StatusBadRequest, "InvalidTag", "The TagValue you have provided is too long, max 256", "", "", ""}
 	ErrTemporaryRedirect                       = Error{307, "TemporaryRedirect", "You are being redirected to the bucket while DNS updates.	", "", "", ""}
 	ErrTokenRefreshRequired                    = Error{http.StatusBadRequest, "TokenRefreshRequired", "The provided token must be refreshed.", "", "", ""}
 	ErrTooManyBuckets                          = Error{http.StatusBadRequest, "TooManyBuckets", "You have attempted to create more buckets than allowed.", "", "", ""}
 	ErrUnexpectedContent                       = Error{http.StatusBadRequest, "UnexpectedContent", "This request does not support content.", "", "", ""}
 	ErrUnresolvableGrantByEmailAddress         = Error{http.StatusBadRequest, "UnresolvableGrantByEmailAddress", "The email address you provided does not match any account on record.", "", "", ""}
 	ErrUserKeyMustBeSpecified                  = Error{http.StatusBadRequest, "UserKeyMustBeSpecified", "The bucket POST must contain the specified field name. If it is specified, check the order of the fields.	", "", "", ""}
+	ErrXAmzContentSHA256Mismatch               = Error{http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed.", "", "", ""}
 )
@@ -0,0 +1,381 @@
+package common
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MaxLifecycleRules is the maximum number of rules of a lifecycle
+// configuration.
+const MaxLifecycleRules = 1000
+
+// Lifecycle rule states.
+const (
+	LifecycleEnabled  = "Enabled"
+	LifecycleDisabled = "Disabled"
+)
+
+// LifecycleConfiguration is the body of PUT and GET Bucket lifecycle.
+type LifecycleConfiguration struct {
+	XMLName xml.Name        `xml:"LifecycleConfiguration" json:"-"`
+	Rules   []LifecycleRule `xml:"Rule"`
+}
+
+// LifecycleRule expires the objects, noncurrent versions and incomplete
+// multipart uploads selected by its filter. The Prefix of the rule itself is
+// the filter of configurations predating Filter.
+type LifecycleRule struct {
+	ID                             string                          `xml:",omitempty"`
+	Filter                         *LifecycleFilter                `xml:",omitempty"`
+	Prefix                         string                          `xml:",omitempty"`
+	Status                         string                          `xml:",omitempty"`
+	Expiration                     *LifecycleExpiration            `xml:",omitempty"`
+	NoncurrentVersionExpiration    *NoncurrentVersionExpiration    `xml:",omitempty"`
+	AbortIncompleteMultipartUpload *AbortIncompleteMultipartUpload `xml:",omitempty"`
+}
+
+// LifecycleFilter selects objects by key prefix, by a tag, or by a prefix and
+// tags combined.
+type LifecycleFilter struct {
+	Prefix string        `xml:",omitempty"`
+	Tag    *Tag          `xml:",omitempty"`
+	And    *LifecycleAnd `xml:",omitempty"`
+}
+
+type LifecycleAnd struct {
+	Prefix string `xml:",omitempty"`
+	Tags   []Tag  `xml:"Tag"`
+}
+
+// LifecycleExpiration expires current versions a number of days after they
+// were written or at a date. ExpiredObjectDeleteMarker removes delete markers
+// which are the only version left of their key.
+type LifecycleExpiration struct {
+	Days                      int    `xml:",omitempty"`
+	Date                      string `xml:",omitempty"`
+	ExpiredObjectDeleteMarker bool   `xml:",omitempty"`
+}
+
+// NoncurrentVersionExpiration removes versions a number of days after they
+// became noncurrent.
+type NoncurrentVersionExpiration struct {
+	NoncurrentDays int
+}
+
+// AbortIncompleteMultipartUpload aborts uploads a number of days after they
+// were initiated.
+type AbortIncompleteMultipartUpload struct {
+	DaysAfterInitiation int
+}
+
+// LifecycleVersion is a version of a key as seen by the lifecycle rules.
+type LifecycleVersion struct {
+	VersionId    string
+	DeleteMarker bool
+	LastModified time.Time
+	Tags         map[string]string
+}
+
+// Validate checks a configuration set by a client.
+func (c *LifecycleConfiguration) Validate() *Error {
+	if len(c.Rules) == 0 || len(c.Rules) > MaxLifecycleRules {
+		return &ErrMalformedXML
+	}
+
+	ids := make(map[string]bool)
+
+	for _, rule := range c.Rules {
+		if rule.ID != "" && ids[rule.ID] {
+			return &ErrInvalidArgument
+		}
+
+		ids[rule.ID] = true
+
+		if awserr := rule.validate(); awserr != nil {
+			return awserr
+		}
+	}
+
+	return nil
+}
+
+func (rule *LifecycleRule) validate() *Error {
+	if len(rule.ID) > 255 || (rule.Status != LifecycleEnabled && rule.Status != LifecycleDisabled) {
+		return &ErrMalformedXML
+	}
+
+	if f := rule.Filter; f != nil {
+		n := 0
+
+		for _, set := range []bool{f.Prefix != "", f.Tag != nil, f.And != nil} {
+			if set {
+				n++
+			}
+		}
+
+		if n > 1 || rule.Prefix != "" {
+			return &ErrMalformedXML
+		}
+	}
+
+	if rule.Expiration == nil && rule.NoncurrentVersionExpiration == nil && rule.AbortIncompleteMultipartUpload == nil {
+		return &ErrMalformedXML
+	}
+
+	if e := rule.Expiration; e != nil {
+		n := 0
+
+		for _, set := range []bool{e.Days != 0, e.Date != "", e.ExpiredObjectDeleteMarker} {
+			if set {
+				n++
+			}
+		}
+
+		if n != 1 {
+			return &ErrMalformedXML
+		}
+
+		if e.Days < 0 {
+			return &ErrInvalidArgument
+		}
+
+		if e.Date != "" {
+			if _, awserr := parseLifecycleDate(e.Date); awserr != nil {
+				return awserr
+			}
+		}
+	}
+
+	if n := rule.NoncurrentVersionExpiration; n != nil && n.NoncurrentDays <= 0 {
+		return &ErrInvalidArgument
+	}
+
+	if a := rule.AbortIncompleteMultipartUpload; a != nil {
+		if a.DaysAfterInitiation <= 0 {
+			return &ErrInvalidArgument
+		}
+
+		if _, tags := rule.filter(); len(tags) > 0 {
+			return &ErrLifecycleTagFilterWithAbort
+		}
+	}
+
+	return nil
+}
+
+// parseLifecycleDate parses an expiration date, which has to be midnight UTC.
+func parseLifecycleDate(s string) (time.Time, *Error) {
+	t, err := time.Parse(time.RFC3339, s)
+
+	if err != nil || !t.Equal(t.Truncate(24*time.Hour)) {
+		return time.Time{}, &ErrInvalidArgument
+	}
+
+	return t, nil
+}
+
+// filter returns the prefix and the tags selecting the objects of the rule.
+func (rule *LifecycleRule) filter() (string, []Tag) {
+	switch f := rule.Filter; {
+	case f == nil:
+		return rule.Prefix, nil
+	case f.Tag != nil:
+		return "", []Tag{*f.Tag}
+	case f.And != nil:
+		return f.And.Prefix, f.And.Tags
+	}
+
+	return rule.Filter.Prefix, nil
+}
+
+// applies reports whether the rule is enabled and selects the key with the
+// tags.
+func (rule *LifecycleRule) applies(key string, tags map[string]string) bool {
+	if rule.Status != LifecycleEnabled {
+		return false
+	}
+
+	prefix, filterTags := rule.filter()
+
+	if !strings.HasPrefix(key, prefix) {
+		return false
+	}
+
+	for _, t := range filterTags {
+		if v, ok := tags[t.Key]; !ok || v != t.Value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// afterDays returns the time days after t, rounded up to the next midnight
+// UTC.
+func afterDays(t time.Time, days int) time.Time {
+	t = t.UTC().AddDate(0, 0, days)
+
+	if midnight := t.Truncate(24 * time.Hour); !midnight.Equal(t) {
+		return midnight.Add(24 * time.Hour)
+	}
+
+	return t
+}
+
+// Expiration returns the time at which the current version of a key written
+// at lastModified expires and the ID of the rule expiring it, or the zero time
+// if it doesn't expire.
+func (c *LifecycleConfiguration) Expiration(key string, tags map[string]string, lastModified time.Time) (time.Time, string) {
+	var expires time.Time
+	var id string
+
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		e := rule.Expiration
+
+		if e == nil || e.ExpiredObjectDeleteMarker || !rule.applies(key, tags) {
+			continue
+		}
+
+		t := afterDays(lastModified, e.Days)
+
+		if e.Date != "" {
+			t, _ = parseLifecycleDate(e.Date)
+		}
+
+		if expires.IsZero() || t.Before(expires) {
+			expires, id = t, rule.ID
+		}
+	}
+
+	return expires, id
+}
+
+// noncurrentExpiration returns the time at which a version which became
+// noncurrent at noncurrentSince is removed, or the zero time.
+func (c *LifecycleConfiguration) noncurrentExpiration(key string, tags map[string]string, noncurrentSince time.Time) time.Time {
+	var expires time.Time
+
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+
+		if rule.NoncurrentVersionExpiration == nil || !rule.applies(key, tags) {
+			continue
+		}
+
+		if t := afterDays(noncurrentSince, rule.NoncurrentVersionExpiration.NoncurrentDays); expires.IsZero() || t.Before(expires) {
+			expires = t
+		}
+	}
+
+	return expires
+}
+
+// removesDeleteMarker reports whether a delete marker which is the only
+// version of the key is removed.
+func (c *LifecycleConfiguration) removesDeleteMarker(key string) bool {
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+
+		if e := rule.Expiration; e != nil && e.ExpiredObjectDeleteMarker && rule.applies(key, nil) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Expire applies the rules at now to the versions of a key, newest first. It
+// returns whether the current version expires, which deletes it like a
+// DELETE without version ID, and the IDs of the versions to remove
+// permanently.
+func (c *LifecycleConfiguration) Expire(key string, versions []LifecycleVersion, now time.Time) (bool, []string) {
+	if len(versions) == 0 {
+		return false, nil
+	}
+
+	current := versions[0]
+	expired := false
+
+	if !current.DeleteMarker {
+		t, _ := c.Expiration(key, current.Tags, current.LastModified)
+		expired = !t.IsZero() && !now.Before(t)
+	}
+
+	remove := make([]string, 0)
+
+	for i := 1; i < len(versions); i++ {
+		v := versions[i]
+
+		if t := c.noncurrentExpiration(key, v.Tags, versions[i-1].LastModified); !t.IsZero() && !now.Before(t) {
+			remove = append(remove, v.VersionId)
+		}
+	}
+
+	if current.DeleteMarker && len(versions)-len(remove) == 1 && c.removesDeleteMarker(key) {
+		remove = append(remove, current.VersionId)
+	}
+
+	return expired, remove
+}
+
+// AbortsUpload reports whether an upload of the key initiated at initiated is
+// aborted at now.
+func (c *LifecycleConfiguration) AbortsUpload(key string, initiated time.Time, now time.Time) bool {
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+
+		if a := rule.AbortIncompleteMultipartUpload; a != nil && rule.applies(key, nil) && !now.Before(afterDays(initiated, a.DaysAfterInitiation)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ExpirationHeader formats the x-amz-expiration header of an object expiring
+// at expires by the rule id.
+func ExpirationHeader(expires time.Time, id string) string {
+	return fmt.Sprintf(`expiry-date="%s", rule-id="%s"`, expires.UTC().Format(http.TimeFormat), id)
+}
+
+// LifecycleWorker applies the lifecycle rules of all buckets of a backend
+// periodically. Now is the clock the rules are evaluated with, time.Now if it
+// is nil.
+type LifecycleWorker struct {
+	Backend  S3Backend
+	Interval time.Duration
+	Now      func() time.Time
+}
+
+// RunOnce applies the rules once.
+func (w *LifecycleWorker) RunOnce() *Error {
+	now := time.Now
+
+	if w.Now != nil {
+		now = w.Now
+	}
+
+	return w.Backend.ApplyLifecycle(now())
+}
+
+// Run applies the rules every Interval until stop is closed.
+func (w *LifecycleWorker) Run(stop <-chan struct{}) {
+	t := time.NewTicker(w.Interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			if awserr := w.RunOnce(); awserr != nil {
+				log.Printf("[lifecycle] %v", awserr)
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLifecycleValidate(t *testing.T) {
+	expire := &LifecycleExpiration{Days: 1}
+
+	tests := []struct {
+		rule LifecycleRule
+		err  *Error
+	}{
+		{LifecycleRule{Status: LifecycleEnabled, Filter: &LifecycleFilter{Prefix: "logs/"}, Expiration: expire}, nil},
+		{LifecycleRule{Status: LifecycleEnabled, Expiration: &LifecycleExpiration{Date: "2030-01-01T00:00:00Z"}}, nil},
+		{LifecycleRule{Status: "On", Expiration: expire}, &ErrMalformedXML},
+		{LifecycleRule{Status: LifecycleEnabled}, &ErrMalformedXML},
+		{LifecycleRule{Status: LifecycleEnabled, Filter: &LifecycleFilter{Prefix: "a", Tag: &Tag{"k", "v"}}, Expiration: expire}, &ErrMalformedXML},
+		{LifecycleRule{Status: LifecycleEnabled, Expiration: &LifecycleExpiration{Days: 1, ExpiredObjectDeleteMarker: true}}, &ErrMalformedXML},
+		{LifecycleRule{Status: LifecycleEnabled, Expiration: &LifecycleExpiration{Days: -1}}, &ErrInvalidArgument},
+		{LifecycleRule{Status: LifecycleEnabled, Expiration: &LifecycleExpiration{Date: "2030-01-01T12:00:00Z"}}, &ErrInvalidArgument},
+		{LifecycleRule{Status: LifecycleEnabled, NoncurrentVersionExpiration: &NoncurrentVersionExpiration{0}}, &ErrInvalidArgument},
+		{LifecycleRule{Status: LifecycleEnabled, Filter: &LifecycleFilter{Tag: &Tag{"k", "v"}}, AbortIncompleteMultipartUpload: &AbortIncompleteMultipartUpload{1}}, &ErrLifecycleTagFilterWithAbort},
+	}
+
+	for _, test := range tests {
+		c := LifecycleConfiguration{Rules: []LifecycleRule{test.rule}}
+
+		if err := c.Validate(); err != test.err {
+			t.Errorf("%+v: expected %v, got %v", test.rule, test.err, err)
+		}
+	}
+
+	c := LifecycleConfiguration{Rules: []LifecycleRule{{ID: "a", Status: LifecycleEnabled, Expiration: expire}, {ID: "a", Status: LifecycleEnabled, Expiration: expire}}}
+
+	if err := c.Validate(); err != &ErrInvalidArgument {
+		t.Errorf("Expected duplicate IDs to be rejected, got %v", err)
+	}
+}
+
+func TestLifecycleExpiration(t *testing.T) {
+	c := LifecycleConfiguration{Rules: []LifecycleRule{
+		{ID: "logs", Status: LifecycleEnabled, Prefix: "logs/", Expiration: &LifecycleExpiration{Days: 30}},
+		{ID: "tmp", Status: LifecycleEnabled, Filter: &LifecycleFilter{And: &LifecycleAnd{Prefix: "logs/", Tags: []Tag{{"tmp", "yes"}}}}, Expiration: &LifecycleExpiration{Days: 1}},
+		{ID: "disabled", Status: LifecycleDisabled, Expiration: &LifecycleExpiration{Days: 1}},
+	}}
+
+	written := time.Date(2020, 1, 1, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		key     string
+		tags    map[string]string
+		expires time.Time
+		id      string
+	}{
+		{"logs/a", nil, time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC), "logs"},
+		{"logs/a", map[string]string{"tmp": "yes"}, time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC), "tmp"},
+		{"logs/a", map[string]string{"tmp": "no"}, time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC), "logs"},
+		{"data/a", nil, time.Time{}, ""},
+	}
+
+	for _, test := range tests {
+		if expires, id := c.Expiration(test.key, test.tags, written); !expires.Equal(test.expires) || id != test.id {
+			t.Errorf("%s %v: expected %v by %q, got %v by %q", test.key, test.tags, test.expires, test.id, expires, id)
+		}
+	}
+
+	if h := ExpirationHeader(time.Date(2012, 12, 23, 0, 0, 0, 0, time.UTC), "rule"); h != `expiry-date="Sun, 23 Dec 2012 00:00:00 GMT", rule-id="rule"` {
+		t.Errorf("Unexpected header %s", h)
+	}
+}
+
+func TestLifecycleExpire(t *testing.T) {
+	c := LifecycleConfiguration{Rules: []LifecycleRule{
+		{Status: LifecycleEnabled, Expiration: &LifecycleExpiration{Days: 10}, NoncurrentVersionExpiration: &NoncurrentVersionExpiration{5}},
+		{Status: LifecycleEnabled, Expiration: &LifecycleExpiration{ExpiredObjectDeleteMarker: true}},
+		{Status: LifecycleEnabled, Filter: &LifecycleFilter{Prefix: "up"}, AbortIncompleteMultipartUpload: &AbortIncompleteMultipartUpload{2}},
+	}}
+
+	day := func(d int) time.Time { return time.Date(2020, 1, d, 12, 0, 0, 0, time.UTC) }
+	versions := []LifecycleVersion{{VersionId: "v3", LastModified: day(10)}, {VersionId: "v2", LastModified: day(5)}, {VersionId: "v1", LastModified: day(1)}}
+
+	tests := []struct {
+		now     time.Time
+		expired bool
+		remove  []string
+	}{
+		{day(8), false, []string{}},
+		{day(12), false, []string{"v1"}},
+		{day(16), false, []string{"v2", "v1"}},
+		{day(21), true, []string{"v2", "v1"}},
+	}
+
+	for _, test := range tests {
+		if expired, remove := c.Expire("key", versions, test.now); expired != test.expired || !reflect.DeepEqual(remove, test.remove) {
+			t.Errorf("%v: expected %v %v, got %v %v", test.now, test.expired, test.remove, expired, remove)
+		}
+	}
+
+	markers := []LifecycleVersion{{VersionId: "m", DeleteMarker: true, LastModified: day(10)}, {VersionId: "v1", LastModified: day(1)}}
+
+	if _, remove := c.Expire("key", markers, day(12)); !reflect.DeepEqual(remove, []string{}) {
+		t.Errorf("Expected the delete marker to be kept while versions are left, got %v", remove)
+	}
+
+	if _, remove := c.Expire("key", markers, day(16)); !reflect.DeepEqual(remove, []string{"v1", "m"}) {
+		t.Errorf("Expected the expired delete marker to be removed, got %v", remove)
+	}
+
+	if c.AbortsUpload("upload", day(1), day(2)) || !c.AbortsUpload("upload", day(1), day(4)) || c.AbortsUpload("other", day(1), day(4)) {
+		t.Error("Unexpected abort of incomplete uploads")
+	}
+}
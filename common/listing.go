@@ -0,0 +1,263 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// MaxKeys is the maximum (and default) number of keys returned by a listing.
+const MaxKeys = 1000
+
+// ListParams are the parameters of GET Bucket (List Objects).
+type ListParams struct {
+	Prefix    string
+	Delimiter string
+	Marker    string
+	MaxKeys   int
+}
+
+// ListV2Params are the parameters of GET Bucket (List Objects) Version 2. A
+// continuation token takes precedence over StartAfter.
+type ListV2Params struct {
+	Prefix            string
+	Delimiter         string
+	StartAfter        string
+	ContinuationToken string
+	MaxKeys           int
+	FetchOwner        bool
+}
+
+// ListV2Resp is the result of GET Bucket (List Objects) Version 2.
+type ListV2Resp struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Name                  string
+	Prefix                string
+	Delimiter             string `xml:",omitempty"`
+	MaxKeys               int
+	EncodingType          string `xml:",omitempty"`
+	KeyCount              int
+	IsTruncated           bool
+	ContinuationToken     string `xml:",omitempty"`
+	NextContinuationToken string `xml:",omitempty"`
+	StartAfter            string `xml:",omitempty"`
+	Contents              []Key
+	CommonPrefixes        []CommonPrefix
+}
+
+type keysByName []Key
+
+func (k keysByName) Len() int           { return len(k) }
+func (k keysByName) Swap(i, j int)      { k[i], k[j] = k[j], k[i] }
+func (k keysByName) Less(i, j int) bool { return k[i].Key < k[j].Key }
+
+// ListObjects builds the listing of the bucket name from all of its keys,
+// in any order. Keys are returned in lexicographic order starting after the
+// marker. Keys containing the delimiter after the prefix are rolled up into
+// a common prefix, which counts as a single key towards MaxKeys.
+func ListObjects(name string, keys []Key, params ListParams) *ListResp {
+	res, next := listObjects(name, keys, params)
+
+	// S3 only returns NextMarker if a delimiter was given. Without it the
+	// last key is the marker for the next request.
+	if res.IsTruncated && params.Delimiter != "" {
+		res.NextMarker = next
+	}
+
+	return res
+}
+
+// listObjects returns the listing and the last key or common prefix in it.
+func listObjects(name string, keys []Key, params ListParams) (*ListResp, string) {
+	if params.MaxKeys < 0 || params.MaxKeys > MaxKeys {
+		params.MaxKeys = MaxKeys
+	}
+
+	sort.Sort(keysByName(keys))
+
+	res := &ListResp{
+		Name:      name,
+		Prefix:    params.Prefix,
+		Delimiter: params.Delimiter,
+		Marker:    params.Marker,
+		MaxKeys:   params.MaxKeys,
+		Contents:  make([]Key, 0),
+	}
+
+	count := 0
+	next := ""
+
+	for _, k := range keys {
+		if k.Key <= params.Marker || !strings.HasPrefix(k.Key, params.Prefix) {
+			continue
+		}
+
+		cp := commonPrefix(k.Key, params.Prefix, params.Delimiter)
+
+		// A marker pointing to a common prefix skips all keys below it.
+		if cp != "" && (cp == next || cp <= params.Marker) {
+			continue
+		}
+
+		if count == params.MaxKeys {
+			res.IsTruncated = true
+			break
+		}
+
+		if cp != "" {
+			res.CommonPrefixes = append(res.CommonPrefixes, CommonPrefix{cp})
+			next = cp
+		} else {
+			res.Contents = append(res.Contents, k)
+			next = k.Key
+		}
+
+		count++
+	}
+
+	return res, next
+}
+
+// commonPrefix returns the common prefix key is rolled up into, or "" if it
+// isn't.
+func commonPrefix(key string, prefix string, delimiter string) string {
+	if delimiter == "" {
+		return ""
+	}
+
+	if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+		return key[:len(prefix)+i+len(delimiter)]
+	}
+
+	return ""
+}
+
+// URLEncode encodes all keys and prefixes of the listing, as requested by
+// encoding-type=url. It allows keys with characters which are not valid in
+// XML.
+func (l *ListResp) URLEncode() {
+	l.EncodingType = "url"
+	l.Prefix = url.QueryEscape(l.Prefix)
+	l.Delimiter = url.QueryEscape(l.Delimiter)
+	l.Marker = url.QueryEscape(l.Marker)
+	l.NextMarker = url.QueryEscape(l.NextMarker)
+
+	for i := range l.Contents {
+		l.Contents[i].Key = url.QueryEscape(l.Contents[i].Key)
+	}
+
+	for i := range l.CommonPrefixes {
+		l.CommonPrefixes[i].Prefix = url.QueryEscape(l.CommonPrefixes[i].Prefix)
+	}
+}
+
+// ListObjectsV2 is the Version 2 variant of ListObjects. Listings are resumed
+// from the position encoded in the continuation token.
+func ListObjectsV2(name string, keys []Key, params ListV2Params) (*ListV2Resp, *Error) {
+	marker := params.StartAfter
+
+	if params.ContinuationToken != "" {
+		m, ok := decodeContinuationToken(params.ContinuationToken)
+
+		if !ok {
+			return nil, &ErrInvalidContinuationToken
+		}
+
+		marker = m
+	}
+
+	l, next := listObjects(name, keys, ListParams{
+		Prefix:    params.Prefix,
+		Delimiter: params.Delimiter,
+		Marker:    marker,
+		MaxKeys:   params.MaxKeys,
+	})
+
+	res := &ListV2Resp{
+		Name:              l.Name,
+		Prefix:            l.Prefix,
+		Delimiter:         l.Delimiter,
+		MaxKeys:           l.MaxKeys,
+		KeyCount:          len(l.Contents) + len(l.CommonPrefixes),
+		IsTruncated:       l.IsTruncated,
+		ContinuationToken: params.ContinuationToken,
+		StartAfter:        params.StartAfter,
+		Contents:          l.Contents,
+		CommonPrefixes:    l.CommonPrefixes,
+	}
+
+	if res.IsTruncated {
+		res.NextContinuationToken = encodeContinuationToken(next)
+	}
+
+	if !params.FetchOwner {
+		for i := range res.Contents {
+			res.Contents[i].Owner = nil
+		}
+	}
+
+	return res, nil
+}
+
+// continuationSecret signs continuation tokens, so that clients can't forge
+// them. Tokens are only valid as long as the server is running.
+var continuationSecret = func() []byte {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return b
+}()
+
+func continuationMAC(marker []byte) []byte {
+	mac := hmac.New(sha256.New, continuationSecret)
+	mac.Write(marker)
+
+	return mac.Sum(nil)
+}
+
+// encodeContinuationToken returns an opaque token for resuming a listing
+// after marker.
+func encodeContinuationToken(marker string) string {
+	return base64.RawURLEncoding.EncodeToString(append(continuationMAC([]byte(marker)), marker...))
+}
+
+func decodeContinuationToken(token string) (string, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+
+	if err != nil || len(b) < sha256.Size {
+		return "", false
+	}
+
+	mac, marker := b[:sha256.Size], b[sha256.Size:]
+
+	if !hmac.Equal(mac, continuationMAC(marker)) {
+		return "", false
+	}
+
+	return string(marker), true
+}
+
+// URLEncode encodes all keys and prefixes of the listing, like
+// ListResp.URLEncode.
+func (l *ListV2Resp) URLEncode() {
+	l.EncodingType = "url"
+	l.Prefix = url.QueryEscape(l.Prefix)
+	l.Delimiter = url.QueryEscape(l.Delimiter)
+	l.StartAfter = url.QueryEscape(l.StartAfter)
+
+	for i := range l.Contents {
+		l.Contents[i].Key = url.QueryEscape(l.Contents[i].Key)
+	}
+
+	for i := range l.CommonPrefixes {
+		l.CommonPrefixes[i].Prefix = url.QueryEscape(l.CommonPrefixes[i].Prefix)
+	}
+}
@@ -0,0 +1,132 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func listKeys(names ...string) []Key {
+	keys := make([]Key, len(names))
+
+	for i, n := range names {
+		keys[i] = Key{Key: n}
+	}
+
+	return keys
+}
+
+func listedKeys(l *ListResp) []string {
+	names := make([]string, 0)
+
+	for _, k := range l.Contents {
+		names = append(names, k.Key)
+	}
+
+	return names
+}
+
+func listedPrefixes(l *ListResp) []string {
+	prefixes := make([]string, 0)
+
+	for _, cp := range l.CommonPrefixes {
+		prefixes = append(prefixes, cp.Prefix)
+	}
+
+	return prefixes
+}
+
+var listingKeys = []string{"photos/2016/b.jpg", "a.txt", "photos/2016/a.jpg", "photos/2017/c.jpg", "photos/index.html", "z.txt"}
+
+func TestListObjects(t *testing.T) {
+	tests := []struct {
+		params     ListParams
+		keys       []string
+		prefixes   []string
+		truncated  bool
+		nextMarker string
+	}{
+		{ListParams{MaxKeys: 1000}, []string{"a.txt", "photos/2016/a.jpg", "photos/2016/b.jpg", "photos/2017/c.jpg", "photos/index.html", "z.txt"}, []string{}, false, ""},
+		{ListParams{Prefix: "photos/", MaxKeys: 1000}, []string{"photos/2016/a.jpg", "photos/2016/b.jpg", "photos/2017/c.jpg", "photos/index.html"}, []string{}, false, ""},
+		{ListParams{Delimiter: "/", MaxKeys: 1000}, []string{"a.txt", "z.txt"}, []string{"photos/"}, false, ""},
+		{ListParams{Prefix: "photos/", Delimiter: "/", MaxKeys: 1000}, []string{"photos/index.html"}, []string{"photos/2016/", "photos/2017/"}, false, ""},
+		{ListParams{Marker: "photos/2016/a.jpg", MaxKeys: 2}, []string{"photos/2016/b.jpg", "photos/2017/c.jpg"}, []string{}, true, ""},
+		{ListParams{Delimiter: "/", MaxKeys: 2}, []string{"a.txt"}, []string{"photos/"}, true, "photos/"},
+		{ListParams{Delimiter: "/", Marker: "photos/", MaxKeys: 2}, []string{"z.txt"}, []string{}, false, ""},
+		{ListParams{Prefix: "photos/", Delimiter: "/", MaxKeys: 1}, []string{}, []string{"photos/2016/"}, true, "photos/2016/"},
+	}
+
+	for _, test := range tests {
+		l := ListObjects("bucket", listKeys(listingKeys...), test.params)
+
+		if !reflect.DeepEqual(listedKeys(l), test.keys) || !reflect.DeepEqual(listedPrefixes(l), test.prefixes) {
+			t.Errorf("%+v: expected keys %v and prefixes %v, got %v and %v", test.params, test.keys, test.prefixes, listedKeys(l), listedPrefixes(l))
+		}
+
+		if l.IsTruncated != test.truncated || l.NextMarker != test.nextMarker {
+			t.Errorf("%+v: expected truncated %v and next marker %q, got %v and %q", test.params, test.truncated, test.nextMarker, l.IsTruncated, l.NextMarker)
+		}
+	}
+}
+
+func TestListObjectsURLEncode(t *testing.T) {
+	l := ListObjects("bucket", listKeys("a b/c\x01", "a b/d"), ListParams{Prefix: "a b/", MaxKeys: 1000})
+	l.URLEncode()
+
+	if !reflect.DeepEqual(listedKeys(l), []string{"a+b%2Fc%01", "a+b%2Fd"}) || l.Prefix != "a+b%2F" || l.EncodingType != "url" {
+		t.Errorf("Unexpected encoded listing %+v", l)
+	}
+}
+
+func TestListObjectsV2Paging(t *testing.T) {
+	params := ListV2Params{Delimiter: "/", MaxKeys: 1}
+	keys := make([]string, 0)
+	prefixes := make([]string, 0)
+
+	for {
+		l, awserr := ListObjectsV2("bucket", listKeys(listingKeys...), params)
+
+		if awserr != nil {
+			t.Fatalf("Unexpected error %v", awserr)
+		}
+
+		if l.KeyCount != len(l.Contents)+len(l.CommonPrefixes) {
+			t.Errorf("Expected key count %d, got %d", len(l.Contents)+len(l.CommonPrefixes), l.KeyCount)
+		}
+
+		for _, k := range l.Contents {
+			keys = append(keys, k.Key)
+		}
+
+		for _, cp := range l.CommonPrefixes {
+			prefixes = append(prefixes, cp.Prefix)
+		}
+
+		if !l.IsTruncated {
+			break
+		}
+
+		params.ContinuationToken = l.NextContinuationToken
+	}
+
+	if !reflect.DeepEqual(keys, []string{"a.txt", "z.txt"}) || !reflect.DeepEqual(prefixes, []string{"photos/"}) {
+		t.Errorf("Expected keys [a.txt z.txt] and prefixes [photos/], got %v and %v", keys, prefixes)
+	}
+}
+
+func TestListObjectsV2InvalidToken(t *testing.T) {
+	token := encodeContinuationToken("a.txt")
+
+	for _, tok := range []string{"garbage", token[:len(token)-1] + "x", token + "x"} {
+		if _, awserr := ListObjectsV2("bucket", listKeys(listingKeys...), ListV2Params{ContinuationToken: tok, MaxKeys: 1000}); awserr != &ErrInvalidContinuationToken {
+			t.Errorf("%q: expected ErrInvalidContinuationToken, got %v", tok, awserr)
+		}
+	}
+}
+
+func TestListObjectsV2StartAfter(t *testing.T) {
+	l, awserr := ListObjectsV2("bucket", listKeys(listingKeys...), ListV2Params{StartAfter: "photos/index.html", MaxKeys: 1000})
+
+	if awserr != nil || len(l.Contents) != 1 || l.Contents[0].Key != "z.txt" || l.StartAfter != "photos/index.html" {
+		t.Errorf("Unexpected listing %+v, %v", l, awserr)
+	}
+}
@@ -0,0 +1,261 @@
+package common
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// MinPartSize is the minimum size of every part of a multipart upload
+	// but the last one.
+	MinPartSize = 5 * 1024 * 1024
+	// MaxPartNumber is the highest part number of a multipart upload.
+	MaxPartNumber = 10000
+	// MaxListParts is the maximum (and default) number of parts and uploads
+	// returned by List Parts and List Multipart Uploads.
+	MaxListParts = 1000
+)
+
+// TimeFormat is the format of timestamps in XML responses.
+const TimeFormat = "2006-01-02T15:04:05.000Z"
+
+// FormatTime formats t for XML responses.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(TimeFormat)
+}
+
+type InitiateMultipartUploadResult struct {
+	Bucket   string
+	Key      string
+	UploadId string
+}
+
+// CompleteMultipartUpload is the request body of Complete Multipart Upload.
+type CompleteMultipartUpload struct {
+	Parts []CompletedPart `xml:"Part"`
+}
+
+type CompletedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+type CompleteMultipartUploadResult struct {
+	Location string
+	Bucket   string
+	Key      string
+	ETag     string
+}
+
+type ListPartsResult struct {
+	Bucket               string
+	Key                  string
+	UploadId             string
+	Initiator            Owner
+	Owner                Owner
+	StorageClass         string
+	PartNumberMarker     int
+	NextPartNumberMarker int
+	MaxParts             int
+	IsTruncated          bool
+	Parts                []Part `xml:"Part"`
+}
+
+// Part is an uploaded part of a multipart upload.
+type Part struct {
+	PartNumber   int
+	LastModified string
+	ETag         string
+	Size         int64
+}
+
+// CopyPartResult is the response of Upload Part - Copy.
+type CopyPartResult struct {
+	ETag         string
+	LastModified string
+}
+
+type ListMultipartUploadsResult struct {
+	Bucket             string
+	KeyMarker          string
+	UploadIdMarker     string
+	NextKeyMarker      string
+	NextUploadIdMarker string
+	Prefix             string
+	MaxUploads         int
+	IsTruncated        bool
+	Uploads            []Upload `xml:"Upload"`
+}
+
+// Upload is a multipart upload in progress.
+type Upload struct {
+	Key          string
+	UploadId     string
+	Initiator    Owner
+	Owner        Owner
+	StorageClass string
+	Initiated    string
+}
+
+// NewUploadID returns a random ID for a multipart upload.
+func NewUploadID() string {
+	b := make([]byte, 24)
+
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// ETag returns the quoted ETag for the given MD5 sum.
+func ETag(sum []byte) string {
+	return `"` + hex.EncodeToString(sum) + `"`
+}
+
+// PartSizes returns the sizes of parts, which are recorded in the ObjectInfo
+// of the completed object.
+func PartSizes(parts []Part) []int64 {
+	sizes := make([]int64, len(parts))
+
+	for i, p := range parts {
+		sizes[i] = p.Size
+	}
+
+	return sizes
+}
+
+// CompleteParts checks the parts given in a Complete Multipart Upload request
+// against the uploaded parts. It returns the parts which make up the object
+// in order and the ETag of the object, which is the MD5 of the MD5 sums of
+// the parts followed by the number of parts.
+func CompleteParts(requested []CompletedPart, uploaded map[int]Part) ([]Part, string, *Error) {
+	if len(requested) == 0 {
+		return nil, "", &ErrMalformedXML
+	}
+
+	for i := 1; i < len(requested); i++ {
+		if requested[i].PartNumber <= requested[i-1].PartNumber {
+			return nil, "", &ErrInvalidPartOrder
+		}
+	}
+
+	parts := make([]Part, 0, len(requested))
+	h := md5.New()
+
+	for i, cp := range requested {
+		p, ok := uploaded[cp.PartNumber]
+
+		if !ok || strings.Trim(cp.ETag, `"`) != strings.Trim(p.ETag, `"`) {
+			return nil, "", &ErrInvalidPart
+		}
+
+		if i < len(requested)-1 && p.Size < MinPartSize {
+			return nil, "", &ErrEntityTooSmall
+		}
+
+		sum, err := hex.DecodeString(strings.Trim(p.ETag, `"`))
+
+		if err != nil {
+			return nil, "", &ErrInvalidPart
+		}
+
+		h.Write(sum)
+		parts = append(parts, p)
+	}
+
+	return parts, fmt.Sprintf(`"%x-%d"`, h.Sum(nil), len(parts)), nil
+}
+
+// PageParts fills the parts of a List Parts response, starting after
+// partNumberMarker.
+func PageParts(res *ListPartsResult, uploaded map[int]Part, partNumberMarker int, maxParts int) {
+	if maxParts <= 0 || maxParts > MaxListParts {
+		maxParts = MaxListParts
+	}
+
+	numbers := make([]int, 0, len(uploaded))
+
+	for n := range uploaded {
+		if n > partNumberMarker {
+			numbers = append(numbers, n)
+		}
+	}
+
+	sort.Ints(numbers)
+
+	res.PartNumberMarker = partNumberMarker
+	res.MaxParts = maxParts
+	res.Parts = make([]Part, 0, len(numbers))
+
+	for _, n := range numbers {
+		if len(res.Parts) == maxParts {
+			res.IsTruncated = true
+			break
+		}
+
+		res.Parts = append(res.Parts, uploaded[n])
+		res.NextPartNumberMarker = n
+	}
+}
+
+type uploadsByKey []Upload
+
+func (u uploadsByKey) Len() int      { return len(u) }
+func (u uploadsByKey) Swap(i, j int) { u[i], u[j] = u[j], u[i] }
+func (u uploadsByKey) Less(i, j int) bool {
+	if u[i].Key != u[j].Key {
+		return u[i].Key < u[j].Key
+	}
+
+	if u[i].Initiated != u[j].Initiated {
+		return u[i].Initiated < u[j].Initiated
+	}
+
+	return u[i].UploadId < u[j].UploadId
+}
+
+// PageUploads fills the uploads of a List Multipart Uploads response. Uploads
+// are ordered by key and by initiation time. Listing starts after keyMarker,
+// or after the upload uploadIDMarker of keyMarker if it is given.
+func PageUploads(res *ListMultipartUploadsResult, uploads []Upload, prefix string, keyMarker string, uploadIDMarker string, maxUploads int) {
+	if maxUploads <= 0 || maxUploads > MaxListParts {
+		maxUploads = MaxListParts
+	}
+
+	sort.Sort(uploadsByKey(uploads))
+
+	res.Prefix = prefix
+	res.KeyMarker = keyMarker
+	res.UploadIdMarker = uploadIDMarker
+	res.MaxUploads = maxUploads
+	res.Uploads = make([]Upload, 0)
+
+	// Uploads of keyMarker are skipped up to and including uploadIDMarker.
+	pastMarker := false
+
+	for _, u := range uploads {
+		if !strings.HasPrefix(u.Key, prefix) || u.Key < keyMarker {
+			continue
+		}
+
+		if u.Key == keyMarker && !pastMarker {
+			pastMarker = uploadIDMarker != "" && u.UploadId == uploadIDMarker
+			continue
+		}
+
+		if len(res.Uploads) == maxUploads {
+			res.IsTruncated = true
+			break
+		}
+
+		res.Uploads = append(res.Uploads, u)
+		res.NextKeyMarker = u.Key
+		res.NextUploadIdMarker = u.UploadId
+	}
+}
@@ -0,0 +1,164 @@
+package common
+
+import (
+	"time"
+)
+
+// StorageClassStandard is the default storage class. Objects are stored alike
+// whatever their class, the class is only recorded.
+const StorageClassStandard = "STANDARD"
+
+// storageClasses are the storage classes accepted by x-amz-storage-class.
+var storageClasses = map[string]bool{
+	StorageClassStandard:  true,
+	"REDUCED_REDUNDANCY":  true,
+	"STANDARD_IA":         true,
+	"ONEZONE_IA":          true,
+	"INTELLIGENT_TIERING": true,
+	"GLACIER":             true,
+	"GLACIER_IR":          true,
+	"DEEP_ARCHIVE":        true,
+}
+
+// MaxMetadataSize is the maximum size of the user metadata of an object, the
+// sum of the lengths of all keys and values.
+const MaxMetadataSize = 2 * 1024
+
+// ObjectInfo describes a version of an object. Writes pass the metadata set by
+// the client, ContentType to StorageClass, and the backends set the other
+// fields. Meta holds the user metadata, keyed by the lower case names of the
+// x-amz-meta-* headers without the prefix. PartSizes holds the sizes of the
+// parts of objects written by multipart uploads.
+type ObjectInfo struct {
+	Key                string `json:",omitempty"`
+	VersionId          string
+	DeleteMarker       bool `json:",omitempty"`
+	Size               int64
+	ETag               string `json:",omitempty"`
+	LastModified       time.Time
+	Owner              Owner
+	ContentType        string
+	ContentEncoding    string              `json:",omitempty"`
+	CacheControl       string              `json:",omitempty"`
+	ContentDisposition string              `json:",omitempty"`
+	Expires            string              `json:",omitempty"`
+	Meta               map[string][]string `json:",omitempty"`
+	StorageClass       string
+	PartSizes          []int64 `json:",omitempty"`
+}
+
+// Validate checks the metadata set by a client.
+func (info *ObjectInfo) Validate() *Error {
+	if info.StorageClass != "" && !storageClasses[info.StorageClass] {
+		return &ErrInvalidStorageClass
+	}
+
+	size := 0
+
+	for k, values := range info.Meta {
+		for _, v := range values {
+			size += len(k) + len(v)
+		}
+	}
+
+	if size > MaxMetadataSize {
+		return &ErrMetadataTooLarge
+	}
+
+	return nil
+}
+
+// Metadata returns a copy of the metadata set by the client, the base of a new
+// version. The storage class defaults to STANDARD. A nil info has no
+// metadata.
+func (info *ObjectInfo) Metadata() ObjectInfo {
+	m := ObjectInfo{StorageClass: StorageClassStandard}
+
+	if info == nil {
+		return m
+	}
+
+	m.ContentType = info.ContentType
+	m.ContentEncoding = info.ContentEncoding
+	m.CacheControl = info.CacheControl
+	m.ContentDisposition = info.ContentDisposition
+	m.Expires = info.Expires
+	m.Meta = info.Meta
+
+	if info.StorageClass != "" {
+		m.StorageClass = info.StorageClass
+	}
+
+	return m
+}
+
+// NewVersion returns the ObjectInfo of a new version of key with the metadata
+// and the contents described by size and etag, written now by owner.
+func (info *ObjectInfo) NewVersion(key string, size int64, etag string, owner Owner) ObjectInfo {
+	v := info.Metadata()
+	v.Key = key
+	v.Size = size
+	v.ETag = etag
+	v.LastModified = time.Now()
+	v.Owner = owner
+
+	return v
+}
+
+// PartRange returns the range of part n of the object, which has a single part
+// unless it was written by a multipart upload. The only part of an empty
+// object has no range.
+func (info *ObjectInfo) PartRange(n int) (*ByteRange, *Error) {
+	sizes := info.PartSizes
+
+	if len(sizes) == 0 {
+		sizes = []int64{info.Size}
+	}
+
+	if n < 1 || n > len(sizes) {
+		return nil, &ErrInvalidPartNumber
+	}
+
+	if info.Size == 0 {
+		return nil, nil
+	}
+
+	var first int64
+
+	for _, size := range sizes[:n-1] {
+		first += size
+	}
+
+	return &ByteRange{First: first, Last: first + sizes[n-1] - 1}, nil
+}
+
+// ListKey returns the entry of the version in an object listing.
+func (info *ObjectInfo) ListKey() Key {
+	owner := info.Owner
+
+	return Key{
+		Key:          info.Key,
+		LastModified: FormatTime(info.LastModified),
+		Size:         info.Size,
+		ETag:         info.ETag,
+		StorageClass: info.StorageClass,
+		Owner:        &owner,
+	}
+}
+
+// ListVersion returns the entry of the version in a versions listing.
+func (info *ObjectInfo) ListVersion(isLatest bool) Version {
+	owner := info.Owner
+
+	return Version{
+		Key:          info.Key,
+		VersionId:    info.VersionId,
+		IsLatest:     isLatest,
+		LastModified: FormatTime(info.LastModified),
+		ETag:         info.ETag,
+		Size:         info.Size,
+		Owner:        &owner,
+		StorageClass: info.StorageClass,
+		DeleteMarker: info.DeleteMarker,
+	}
+}
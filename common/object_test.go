@@ -0,0 +1,71 @@
+package common
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestObjectInfoValidate(t *testing.T) {
+	tests := []struct {
+		info ObjectInfo
+		err  *Error
+	}{
+		{ObjectInfo{}, nil},
+		{ObjectInfo{StorageClass: "GLACIER"}, nil},
+		{ObjectInfo{StorageClass: "COLD"}, &ErrInvalidStorageClass},
+		{ObjectInfo{Meta: map[string][]string{"k": {strings.Repeat("v", MaxMetadataSize-1)}}}, nil},
+		{ObjectInfo{Meta: map[string][]string{"k": {strings.Repeat("v", MaxMetadataSize)}}}, &ErrMetadataTooLarge},
+		{ObjectInfo{Meta: map[string][]string{"k": {strings.Repeat("v", 1024), strings.Repeat("v", 1024)}}}, &ErrMetadataTooLarge},
+	}
+
+	for i, test := range tests {
+		if err := test.info.Validate(); err != test.err {
+			t.Errorf("%d: expected %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestObjectInfoNewVersion(t *testing.T) {
+	owner := Owner{ID: "alice"}
+	info := &ObjectInfo{
+		Key:          "ignored",
+		VersionId:    "ignored",
+		Size:         42,
+		ContentType:  "text/plain",
+		CacheControl: "no-cache",
+		Meta:         map[string][]string{"color": {"blue"}},
+	}
+
+	v := info.NewVersion("key", 3, `"etag"`, owner)
+
+	if v.Key != "key" || v.VersionId != "" || v.Size != 3 || v.ETag != `"etag"` || v.Owner != owner || v.LastModified.IsZero() {
+		t.Errorf("Unexpected version %+v", v)
+	}
+
+	if v.ContentType != "text/plain" || v.CacheControl != "no-cache" || v.Meta["color"][0] != "blue" || v.StorageClass != StorageClassStandard {
+		t.Errorf("Expected the metadata to be kept, got %+v", v)
+	}
+
+	var none *ObjectInfo
+
+	if m := none.Metadata(); m.ContentType != "" || m.StorageClass != StorageClassStandard {
+		t.Errorf("Unexpected metadata of nil %+v", m)
+	}
+}
+
+func TestObjectInfoListKey(t *testing.T) {
+	info := ObjectInfo{Key: "key", VersionId: "v1", Size: 3, ETag: `"etag"`, LastModified: time.Unix(0, 0), Owner: Owner{ID: "alice"}, StorageClass: StorageClassStandard}
+
+	k := info.ListKey()
+
+	if k.Key != "key" || k.Size != 3 || k.ETag != `"etag"` || k.StorageClass != StorageClassStandard || k.Owner == nil || k.Owner.ID != "alice" || k.LastModified != FormatTime(info.LastModified) {
+		t.Errorf("Unexpected key %+v", k)
+	}
+
+	v := info.ListVersion(true)
+
+	if v.VersionId != "v1" || !v.IsLatest || v.ETag != `"etag"` || v.StorageClass != StorageClassStandard {
+		t.Errorf("Unexpected version %+v", v)
+	}
+}
@@ -0,0 +1,401 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// MaxPolicySize is the maximum size of a bucket policy document.
+const MaxPolicySize = 20 * 1024
+
+// Policy effects.
+const (
+	EffectAllow = "Allow"
+	EffectDeny  = "Deny"
+)
+
+// ResourcePrefix is the prefix of the ARNs of buckets and objects.
+const ResourcePrefix = "arn:aws:s3:::"
+
+// Decision is the result of evaluating a policy for a request.
+type Decision int
+
+const (
+	// NoDecision means that no statement applies to the request, so access
+	// is decided by the ACLs.
+	NoDecision Decision = iota
+	Allowed
+	Denied
+)
+
+// StringList is a policy element which is either a single value or an array
+// of values. Booleans and numbers, which are common in conditions, are kept
+// in their JSON form.
+type StringList []string
+
+func (l *StringList) UnmarshalJSON(b []byte) error {
+	var v interface{}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	values, ok := v.([]interface{})
+
+	if !ok {
+		values = []interface{}{v}
+	}
+
+	*l = StringList{}
+
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			*l = append(*l, v)
+		case bool, float64:
+			*l = append(*l, fmt.Sprint(v))
+		default:
+			return fmt.Errorf("Unexpected policy value %v", v)
+		}
+	}
+
+	return nil
+}
+
+// PolicyPrincipal is the Principal element of a statement, either "*" or a map
+// of principal types to principals. Accounts are identified by their
+// canonical ID, as CanonicalUser, as AWS or as the ARN
+// arn:aws:iam::<ID>:root.
+type PolicyPrincipal map[string]StringList
+
+func (p *PolicyPrincipal) UnmarshalJSON(b []byte) error {
+	var s string
+
+	if err := json.Unmarshal(b, &s); err == nil {
+		if s != "*" {
+			return fmt.Errorf("Unexpected principal %q", s)
+		}
+
+		*p = PolicyPrincipal{"AWS": StringList{"*"}}
+		return nil
+	}
+
+	m := map[string]StringList{}
+
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+
+	*p = PolicyPrincipal(m)
+
+	return nil
+}
+
+// Matches reports whether the principal of a request is included.
+func (p PolicyPrincipal) Matches(principal *Principal) bool {
+	for _, id := range p["AWS"] {
+		if id == "*" || id == principal.ID || id == "arn:aws:iam::"+principal.ID+":root" {
+			return true
+		}
+	}
+
+	for _, id := range p["CanonicalUser"] {
+		if id == principal.ID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Statement is a statement of a bucket policy. Conditions map operators to
+// condition keys and their values.
+type Statement struct {
+	Sid         string `json:",omitempty"`
+	Effect      string
+	Principal   PolicyPrincipal
+	Action      StringList                       `json:",omitempty"`
+	NotAction   StringList                       `json:",omitempty"`
+	Resource    StringList                       `json:",omitempty"`
+	NotResource StringList                       `json:",omitempty"`
+	Condition   map[string]map[string]StringList `json:",omitempty"`
+}
+
+// Policy is a bucket policy.
+type Policy struct {
+	Version   string `json:",omitempty"`
+	Id        string `json:",omitempty"`
+	Statement []Statement
+}
+
+// PolicyRequest is a request as seen by the policy evaluation. Resource is the
+// ARN of the addressed bucket or object. Conditions holds the values of the
+// condition keys of the request, keyed by their lower case names. Keys which
+// don't apply to the request are missing.
+type PolicyRequest struct {
+	Principal  *Principal
+	Action     string
+	Resource   string
+	Conditions map[string]string
+}
+
+// BucketResource returns the ARN of a bucket or, if object is not empty, of an
+// object.
+func BucketResource(bucket string, object string) string {
+	if object == "" {
+		return ResourcePrefix + bucket
+	}
+
+	return ResourcePrefix + bucket + "/" + object
+}
+
+// ParsePolicy parses and validates the policy document of a bucket.
+func ParsePolicy(doc []byte, bucket string) (*Policy, *Error) {
+	if len(doc) > MaxPolicySize {
+		return nil, &ErrMalformedPolicyDocumentSize
+	}
+
+	d := json.NewDecoder(bytes.NewReader(doc))
+	d.DisallowUnknownFields()
+
+	p := Policy{}
+
+	if err := d.Decode(&p); err != nil {
+		return nil, &ErrMalformedPolicy
+	}
+
+	if p.Version != "" && p.Version != "2012-10-17" && p.Version != "2008-10-17" {
+		return nil, &ErrMalformedPolicy
+	}
+
+	if len(p.Statement) == 0 {
+		return nil, &ErrMalformedPolicy
+	}
+
+	for _, s := range p.Statement {
+		if awserr := s.validate(bucket); awserr != nil {
+			return nil, awserr
+		}
+	}
+
+	return &p, nil
+}
+
+func (s *Statement) validate(bucket string) *Error {
+	if s.Effect != EffectAllow && s.Effect != EffectDeny {
+		return &ErrMalformedPolicy
+	}
+
+	if len(s.Principal) == 0 {
+		return &ErrMalformedPolicy
+	}
+
+	if (len(s.Action) == 0) == (len(s.NotAction) == 0) || (len(s.Resource) == 0) == (len(s.NotResource) == 0) {
+		return &ErrMalformedPolicy
+	}
+
+	for _, a := range append(append(StringList{}, s.Action...), s.NotAction...) {
+		if a != "*" && !strings.HasPrefix(strings.ToLower(a), "s3:") {
+			return &ErrMalformedPolicy
+		}
+	}
+
+	// Resources have to be in the bucket the policy is attached to.
+	for _, r := range append(append(StringList{}, s.Resource...), s.NotResource...) {
+		if !strings.HasPrefix(r, ResourcePrefix) {
+			return &ErrMalformedPolicy
+		}
+
+		name := strings.SplitN(strings.TrimPrefix(r, ResourcePrefix), "/", 2)[0]
+
+		if !wildcardMatch(name, bucket) {
+			return &ErrMalformedPolicy
+		}
+	}
+
+	for op, keys := range s.Condition {
+		base := strings.TrimSuffix(op, "IfExists")
+
+		if _, ok := conditionOperators[base]; !ok || op == "NullIfExists" {
+			return &ErrMalformedPolicy
+		}
+
+		for _, values := range keys {
+			for _, v := range values {
+				if _, ok := parseIPNet(v); !ok && (base == "IpAddress" || base == "NotIpAddress") {
+					return &ErrMalformedPolicy
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Evaluate evaluates the policy for a request. An explicit deny overrides any
+// allow.
+func (p *Policy) Evaluate(r *PolicyRequest) Decision {
+	if p == nil {
+		return NoDecision
+	}
+
+	d := NoDecision
+
+	for _, s := range p.Statement {
+		if !s.applies(r) {
+			continue
+		}
+
+		if s.Effect == EffectDeny {
+			return Denied
+		}
+
+		d = Allowed
+	}
+
+	return d
+}
+
+func (s *Statement) applies(r *PolicyRequest) bool {
+	if !s.Principal.Matches(r.Principal) {
+		return false
+	}
+
+	if len(s.Action) > 0 && !matchesAny(s.Action, r.Action, true) {
+		return false
+	} else if len(s.NotAction) > 0 && matchesAny(s.NotAction, r.Action, true) {
+		return false
+	}
+
+	if len(s.Resource) > 0 && !matchesAny(s.Resource, r.Resource, false) {
+		return false
+	} else if len(s.NotResource) > 0 && matchesAny(s.NotResource, r.Resource, false) {
+		return false
+	}
+
+	for op, keys := range s.Condition {
+		for key, values := range keys {
+			if !evaluateCondition(op, values, r.Conditions, strings.ToLower(key)) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func matchesAny(patterns []string, s string, ignoreCase bool) bool {
+	for _, p := range patterns {
+		if ignoreCase && wildcardMatch(strings.ToLower(p), strings.ToLower(s)) {
+			return true
+		} else if !ignoreCase && wildcardMatch(p, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// conditionOperators maps the supported condition operators to a function
+// matching a value of the request against a value of the condition, and
+// whether the operator is negated.
+var conditionOperators = map[string]struct {
+	match   func(value string, v string) bool
+	negated bool
+}{
+	"StringEquals":              {func(value, v string) bool { return value == v }, false},
+	"StringNotEquals":           {func(value, v string) bool { return value == v }, true},
+	"StringEqualsIgnoreCase":    {strings.EqualFold, false},
+	"StringNotEqualsIgnoreCase": {strings.EqualFold, true},
+	"StringLike":                {func(value, v string) bool { return wildcardMatch(v, value) }, false},
+	"StringNotLike":             {func(value, v string) bool { return wildcardMatch(v, value) }, true},
+	"Bool":                      {strings.EqualFold, false},
+	"IpAddress":                 {matchIP, false},
+	"NotIpAddress":              {matchIP, true},
+	"Null":                      {nil, false},
+}
+
+// evaluateCondition evaluates a condition on a key. The condition holds if
+// any of the values matches, a negated condition if none does. Missing keys
+// only satisfy negated conditions and the IfExists variants of operators.
+func evaluateCondition(op string, values []string, conditions map[string]string, key string) bool {
+	value, ok := conditions[key]
+
+	if op == "Null" {
+		for _, v := range values {
+			if strings.EqualFold(v, "true") != ok {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	ifExists := strings.HasSuffix(op, "IfExists")
+	o := conditionOperators[strings.TrimSuffix(op, "IfExists")]
+
+	if !ok {
+		return o.negated || ifExists
+	}
+
+	for _, v := range values {
+		if o.match(value, v) {
+			return !o.negated
+		}
+	}
+
+	return o.negated
+}
+
+func parseIPNet(s string) (*net.IPNet, bool) {
+	if !strings.Contains(s, "/") {
+		if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+			s += "/32"
+		} else {
+			s += "/128"
+		}
+	}
+
+	_, n, err := net.ParseCIDR(s)
+
+	return n, err == nil
+}
+
+func matchIP(value string, v string) bool {
+	n, ok := parseIPNet(v)
+	ip := net.ParseIP(value)
+
+	return ok && ip != nil && n.Contains(ip)
+}
+
+// wildcardMatch matches s against a pattern in which * matches any sequence
+// of characters and ? any single character.
+func wildcardMatch(pattern string, s string) bool {
+	star, next := -1, 0
+	p, i := 0, 0
+
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, i
+			p++
+		case star >= 0:
+			next++
+			p, i = star+1, next
+		default:
+			return false
+		}
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
@@ -0,0 +1,142 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		doc string
+		err *Error
+	}{
+		{`{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*"}]}`, nil},
+		{`{"Statement": [{"Effect": "Deny", "Principal": {"AWS": ["alice"]}, "NotAction": ["s3:Get*"], "Resource": ["arn:aws:s3:::bucket", "arn:aws:s3:::bu*/*"],
+			"Condition": {"Bool": {"aws:SecureTransport": false}, "IpAddress": {"aws:SourceIp": "10.0.0.0/8"}}}]}`, nil},
+		{`not json`, &ErrMalformedPolicy},
+		{`{"Statement": []}`, &ErrMalformedPolicy},
+		{`{"Version": "2020-01-01", "Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::bucket"}]}`, &ErrMalformedPolicy},
+		{`{"Statement": [{"Effect": "Maybe", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::bucket"}]}`, &ErrMalformedPolicy},
+		{`{"Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "arn:aws:s3:::bucket"}]}`, &ErrMalformedPolicy},
+		{`{"Statement": [{"Effect": "Allow", "Principal": "alice", "Action": "s3:*", "Resource": "arn:aws:s3:::bucket"}]}`, &ErrMalformedPolicy},
+		{`{"Statement": [{"Effect": "Allow", "Principal": "*", "Resource": "arn:aws:s3:::bucket"}]}`, &ErrMalformedPolicy},
+		{`{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "ec2:*", "Resource": "arn:aws:s3:::bucket"}]}`, &ErrMalformedPolicy},
+		{`{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::other/*"}]}`, &ErrMalformedPolicy},
+		{`{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::bucket", "Condition": {"Like": {"s3:prefix": "a"}}}]}`, &ErrMalformedPolicy},
+		{`{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::bucket", "Condition": {"IpAddress": {"aws:SourceIp": "a.b.c.d"}}}]}`, &ErrMalformedPolicy},
+		{`{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::bucket", "Unknown": true}]}`, &ErrMalformedPolicy},
+	}
+
+	for _, test := range tests {
+		if _, err := ParsePolicy([]byte(test.doc), "bucket"); err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.doc, test.err, err)
+		}
+	}
+
+	if _, err := ParsePolicy(make([]byte, MaxPolicySize+1), "bucket"); err != &ErrMalformedPolicyDocumentSize {
+		t.Errorf("Expected ErrMalformedPolicyDocumentSize, got %v", err)
+	}
+}
+
+func TestPolicyEvaluate(t *testing.T) {
+	p, err := ParsePolicy([]byte(`{"Version": "2012-10-17", "Statement": [
+		{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/public/*"},
+		{"Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::bob:root"}, "Action": "s3:ListBucket", "Resource": "arn:aws:s3:::bucket",
+			"Condition": {"StringLike": {"s3:prefix": ["home/bob/*", ""]}}},
+		{"Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::bucket/*",
+			"Condition": {"Bool": {"aws:SecureTransport": "false"}, "NotIpAddress": {"aws:SourceIp": ["10.0.0.0/8", "::1"]}}}
+	]}`), "bucket")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bob := &Principal{ID: "bob"}
+	local := map[string]string{"aws:securetransport": "false", "aws:sourceip": "10.1.2.3"}
+	remote := map[string]string{"aws:securetransport": "false", "aws:sourceip": "192.0.2.1"}
+	secure := map[string]string{"aws:securetransport": "true", "aws:sourceip": "192.0.2.1"}
+
+	tests := []struct {
+		principal  *Principal
+		action     string
+		resource   string
+		conditions map[string]string
+		decision   Decision
+	}{
+		{Anonymous, "s3:GetObject", "arn:aws:s3:::bucket/public/a", local, Allowed},
+		{Anonymous, "S3:getobject", "arn:aws:s3:::bucket/public/a", local, Allowed},
+		{Anonymous, "s3:GetObject", "arn:aws:s3:::bucket/Public/a", local, NoDecision},
+		{Anonymous, "s3:PutObject", "arn:aws:s3:::bucket/public/a", local, NoDecision},
+		{Anonymous, "s3:GetObject", "arn:aws:s3:::bucket/public/a", remote, Denied},
+		{Anonymous, "s3:GetObject", "arn:aws:s3:::bucket/public/a", secure, Allowed},
+		{bob, "s3:ListBucket", "arn:aws:s3:::bucket", map[string]string{"s3:prefix": "home/bob/docs"}, Allowed},
+		{bob, "s3:ListBucket", "arn:aws:s3:::bucket", map[string]string{"s3:prefix": ""}, Allowed},
+		{bob, "s3:ListBucket", "arn:aws:s3:::bucket", map[string]string{"s3:prefix": "home/alice/"}, NoDecision},
+		{bob, "s3:ListBucket", "arn:aws:s3:::bucket", map[string]string{}, NoDecision},
+		{&Principal{ID: "alice"}, "s3:ListBucket", "arn:aws:s3:::bucket", map[string]string{"s3:prefix": "home/bob/"}, NoDecision},
+	}
+
+	for _, test := range tests {
+		r := &PolicyRequest{Principal: test.principal, Action: test.action, Resource: test.resource, Conditions: test.conditions}
+
+		if d := p.Evaluate(r); d != test.decision {
+			t.Errorf("%+v: expected %d, got %d", r, test.decision, d)
+		}
+	}
+
+	if d := (*Policy)(nil).Evaluate(&PolicyRequest{Principal: bob}); d != NoDecision {
+		t.Errorf("Expected no decision without a policy, got %d", d)
+	}
+}
+
+func TestConditionOperators(t *testing.T) {
+	conditions := map[string]string{"s3:prefix": "Home/"}
+
+	tests := []struct {
+		op     string
+		values []string
+		holds  bool
+	}{
+		{"StringEquals", []string{"a", "Home/"}, true},
+		{"StringNotEquals", []string{"Home/"}, false},
+		{"StringEqualsIgnoreCase", []string{"home/"}, true},
+		{"StringNotLike", []string{"h*"}, true},
+		{"Null", []string{"false"}, true},
+		{"Null", []string{"true"}, false},
+	}
+
+	for _, test := range tests {
+		if holds := evaluateCondition(test.op, test.values, conditions, "s3:prefix"); holds != test.holds {
+			t.Errorf("%s %v: expected %v", test.op, test.values, test.holds)
+		}
+	}
+
+	if !evaluateCondition("StringEqualsIfExists", []string{"a"}, conditions, "s3:delimiter") {
+		t.Error("Expected IfExists to hold for a missing key")
+	}
+
+	if evaluateCondition("StringEquals", []string{"a"}, conditions, "s3:delimiter") {
+		t.Error("Expected a missing key not to match")
+	}
+}
+
+func TestWildcardMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		matches bool
+	}{
+		{"*", "", true},
+		{"a*c", "abbc", true},
+		{"a*c", "abcd", false},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"*/*.jpg", "dir/sub/img.jpg", true},
+		{"abc", "ab", false},
+	}
+
+	for _, test := range tests {
+		if wildcardMatch(test.pattern, test.s) != test.matches {
+			t.Errorf("%q %q: expected %v", test.pattern, test.s, test.matches)
+		}
+	}
+}
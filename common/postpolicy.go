@@ -0,0 +1,162 @@
+package common
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Operators of the conditions of a POST policy.
+const (
+	PostEq                 = "eq"
+	PostStartsWith         = "starts-with"
+	PostContentLengthRange = "content-length-range"
+)
+
+// PostCondition is a condition of a POST policy, either an object
+// {"field": "value"}, which is short for ["eq", "$field", "value"], or an
+// array ["eq" | "starts-with", "$field", "value"] or
+// ["content-length-range", min, max]. Field names are kept in lowercase, as
+// they are matched case-insensitively.
+type PostCondition struct {
+	Op    string
+	Field string
+	Value string
+	Min   int64
+	Max   int64
+}
+
+func (c *PostCondition) UnmarshalJSON(b []byte) error {
+	m := map[string]string{}
+
+	if err := json.Unmarshal(b, &m); err == nil {
+		if len(m) != 1 {
+			return fmt.Errorf("Unexpected condition %s", b)
+		}
+
+		for field, value := range m {
+			*c = PostCondition{Op: PostEq, Field: strings.ToLower(field), Value: value}
+		}
+
+		return nil
+	}
+
+	var a []interface{}
+
+	if err := json.Unmarshal(b, &a); err != nil {
+		return err
+	}
+
+	if len(a) != 3 {
+		return fmt.Errorf("Unexpected condition %s", b)
+	}
+
+	op, _ := a[0].(string)
+	op = strings.ToLower(op)
+
+	if op == PostContentLengthRange {
+		min, ok1 := a[1].(float64)
+		max, ok2 := a[2].(float64)
+
+		if !ok1 || !ok2 || min < 0 || max < min {
+			return fmt.Errorf("Unexpected content length range %s", b)
+		}
+
+		*c = PostCondition{Op: op, Min: int64(min), Max: int64(max)}
+		return nil
+	}
+
+	field, ok1 := a[1].(string)
+	value, ok2 := a[2].(string)
+
+	if !ok1 || !ok2 || !strings.HasPrefix(field, "$") || (op != PostEq && op != PostStartsWith) {
+		return fmt.Errorf("Unexpected condition %s", b)
+	}
+
+	*c = PostCondition{Op: op, Field: strings.ToLower(field[1:]), Value: value}
+
+	return nil
+}
+
+// PostPolicy is the policy document of a browser-based POST upload, which
+// limits the forms signed with it.
+type PostPolicy struct {
+	Expiration time.Time       `json:"expiration"`
+	Conditions []PostCondition `json:"conditions"`
+}
+
+// postUnchecked are the form fields which need no condition.
+var postUnchecked = map[string]bool{
+	"awsaccesskeyid":  true,
+	"bucket":          true,
+	"file":            true,
+	"policy":          true,
+	"signature":       true,
+	"x-amz-signature": true,
+}
+
+// ParsePostPolicy decodes the base64 encoded policy field of a POST form.
+func ParsePostPolicy(encoded string) (*PostPolicy, *Error) {
+	doc, err := base64.StdEncoding.DecodeString(encoded)
+
+	if err != nil {
+		return nil, &ErrInvalidPolicyDocument
+	}
+
+	p := &PostPolicy{}
+
+	if err := json.Unmarshal(doc, p); err != nil || p.Expiration.IsZero() {
+		return nil, &ErrInvalidPolicyDocument
+	}
+
+	return p, nil
+}
+
+// Check checks the fields of a form, keyed by lowercase name, against the
+// policy at now. Every field, but the signature, the policy itself and fields
+// prefixed with x-ignore-, has to be named by a condition. The length of the
+// file is checked while it is read, see LengthRange.
+func (p *PostPolicy) Check(fields map[string]string, now time.Time) *Error {
+	if now.After(p.Expiration) {
+		return &ErrPostPolicyExpired
+	}
+
+	covered := map[string]bool{}
+
+	for _, c := range p.Conditions {
+		switch c.Op {
+		case PostEq:
+			if fields[c.Field] != c.Value {
+				return &ErrPostPolicyConditionFailed
+			}
+		case PostStartsWith:
+			if !strings.HasPrefix(fields[c.Field], c.Value) {
+				return &ErrPostPolicyConditionFailed
+			}
+		}
+
+		covered[c.Field] = true
+	}
+
+	for name := range fields {
+		if !covered[name] && !postUnchecked[name] && !strings.HasPrefix(name, "x-ignore-") {
+			return &ErrPostPolicyExtraFields
+		}
+	}
+
+	return nil
+}
+
+// LengthRange returns the limits of the content-length-range condition, if
+// the policy has one.
+func (p *PostPolicy) LengthRange() (int64, int64, bool) {
+	for _, c := range p.Conditions {
+		if c.Op == PostContentLengthRange {
+			return c.Min, c.Max, true
+		}
+	}
+
+	return 0, 0, false
+}
@@ -0,0 +1,92 @@
+package common
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func encodePostPolicy(doc string) string {
+	return base64.StdEncoding.EncodeToString([]byte(doc))
+}
+
+func TestParsePostPolicy(t *testing.T) {
+	p, err := ParsePostPolicy(encodePostPolicy(`{"expiration": "2017-01-01T12:00:00.000Z", "conditions": [
+		{"bucket": "bucket"},
+		["starts-with", "$key", "uploads/"],
+		["eq", "$Content-Type", "image/png"],
+		["content-length-range", 1, 1024]
+	]}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.Expiration.Equal(time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)) || len(p.Conditions) != 4 {
+		t.Fatalf("Unexpected policy %+v", p)
+	}
+
+	expected := []PostCondition{
+		{Op: PostEq, Field: "bucket", Value: "bucket"},
+		{Op: PostStartsWith, Field: "key", Value: "uploads/"},
+		{Op: PostEq, Field: "content-type", Value: "image/png"},
+		{Op: PostContentLengthRange, Min: 1, Max: 1024},
+	}
+
+	for i, c := range p.Conditions {
+		if c != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], c)
+		}
+	}
+
+	if min, max, ok := p.LengthRange(); !ok || min != 1 || max != 1024 {
+		t.Errorf("Unexpected length range %d-%d, %v", min, max, ok)
+	}
+
+	for _, doc := range []string{
+		`{"conditions": []}`,
+		`{"expiration": "2017-01-01T12:00:00.000Z", "conditions": [["in", "$key", "a"]]}`,
+		`{"expiration": "2017-01-01T12:00:00.000Z", "conditions": [["eq", "key", "a"]]}`,
+		`{"expiration": "2017-01-01T12:00:00.000Z", "conditions": [["content-length-range", 10, 1]]}`,
+		`{"expiration": "2017-01-01T12:00:00.000Z", "conditions": [{"key": "a", "acl": "private"}]}`,
+	} {
+		if _, err := ParsePostPolicy(encodePostPolicy(doc)); err != &ErrInvalidPolicyDocument {
+			t.Errorf("%s: expected ErrInvalidPolicyDocument, got %v", doc, err)
+		}
+	}
+
+	if _, err := ParsePostPolicy("not base64!"); err != &ErrInvalidPolicyDocument {
+		t.Errorf("Expected ErrInvalidPolicyDocument, got %v", err)
+	}
+}
+
+func TestPostPolicyCheck(t *testing.T) {
+	p := &PostPolicy{
+		Expiration: time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC),
+		Conditions: []PostCondition{
+			{Op: PostEq, Field: "bucket", Value: "bucket"},
+			{Op: PostStartsWith, Field: "key", Value: "uploads/"},
+			{Op: PostStartsWith, Field: "content-type", Value: ""},
+		},
+	}
+	now := time.Date(2017, 1, 1, 11, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		fields map[string]string
+		now    time.Time
+		err    *Error
+	}{
+		{map[string]string{"bucket": "bucket", "key": "uploads/a", "policy": "p", "x-amz-signature": "s"}, now, nil},
+		{map[string]string{"bucket": "bucket", "key": "uploads/a", "content-type": "text/plain", "x-ignore-me": "x"}, now, nil},
+		{map[string]string{"bucket": "bucket", "key": "uploads/a"}, p.Expiration.Add(time.Second), &ErrPostPolicyExpired},
+		{map[string]string{"bucket": "other", "key": "uploads/a"}, now, &ErrPostPolicyConditionFailed},
+		{map[string]string{"bucket": "bucket", "key": "a"}, now, &ErrPostPolicyConditionFailed},
+		{map[string]string{"bucket": "bucket", "key": "uploads/a", "acl": "public-read"}, now, &ErrPostPolicyExtraFields},
+	}
+
+	for _, test := range tests {
+		if err := p.Check(test.fields, test.now); err != test.err {
+			t.Errorf("%v: expected %v, got %v", test.fields, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ByteRange is a range of the contents of an object, from First to Last
+// inclusive.
+type ByteRange struct {
+	First int64
+	Last  int64
+}
+
+// Length returns the number of bytes in the range.
+func (br *ByteRange) Length() int64 {
+	return br.Last - br.First + 1
+}
+
+// ContentRange returns the Content-Range header of the range of an object of
+// size bytes.
+func (br *ByteRange) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", br.First, br.Last, size)
+}
+
+// ParseRange parses the Range header of a read of an object of size bytes. It
+// returns nil for the whole object. Like S3, it ignores headers which are not
+// a single byte range, and returns ErrInvalidRange for ranges starting beyond
+// the end of the object. Ranges ending beyond the end are shortened.
+func ParseRange(header string, size int64) (*ByteRange, *Error) {
+	if !strings.HasPrefix(header, "bytes=") {
+		return nil, nil
+	}
+
+	spec := strings.TrimSpace(strings.TrimPrefix(header, "bytes="))
+	i := strings.Index(spec, "-")
+
+	if i < 0 || strings.Contains(spec, ",") {
+		return nil, nil
+	}
+
+	first, last := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+
+		if err != nil || n < 0 {
+			return nil, nil
+		}
+
+		if n == 0 || size == 0 {
+			return nil, &ErrInvalidRange
+		}
+
+		if n > size {
+			n = size
+		}
+
+		return &ByteRange{First: size - n, Last: size - 1}, nil
+	}
+
+	br := &ByteRange{Last: size - 1}
+	var err error
+
+	if br.First, err = strconv.ParseInt(first, 10, 64); err != nil || br.First < 0 {
+		return nil, nil
+	}
+
+	if last != "" {
+		if br.Last, err = strconv.ParseInt(last, 10, 64); err != nil || br.Last < br.First {
+			return nil, nil
+		}
+	}
+
+	if br.First >= size {
+		return nil, &ErrInvalidRange
+	}
+
+	if br.Last >= size {
+		br.Last = size - 1
+	}
+
+	return br, nil
+}
+
+// ParseCopySourceRange parses the x-amz-copy-source-range header of Upload
+// Part - Copy. It returns nil for the whole source. Unlike the Range header,
+// the header has to be a single range of the form bytes=first-last.
+func ParseCopySourceRange(header string) (*ByteRange, *Error) {
+	if header == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(header, "bytes=") {
+		return nil, &ErrInvalidCopySourceRange
+	}
+
+	spec := strings.TrimPrefix(header, "bytes=")
+	i := strings.Index(spec, "-")
+
+	if i < 0 {
+		return nil, &ErrInvalidCopySourceRange
+	}
+
+	first, err := strconv.ParseInt(spec[:i], 10, 64)
+
+	if err != nil || first < 0 {
+		return nil, &ErrInvalidCopySourceRange
+	}
+
+	last, err := strconv.ParseInt(spec[i+1:], 10, 64)
+
+	if err != nil || last < first {
+		return nil, &ErrInvalidCopySourceRange
+	}
+
+	return &ByteRange{First: first, Last: last}, nil
+}
+
+// CheckSize returns ErrInvalidRange if the range does not lie within an
+// object of size bytes.
+func (br *ByteRange) CheckSize(size int64) *Error {
+	if br.Last >= size {
+		return &ErrInvalidRange
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header string
+		size   int64
+		br     *ByteRange
+		err    *Error
+	}{
+		{"", 10, nil, nil},
+		{"bytes=0-4", 10, &ByteRange{0, 4}, nil},
+		{"bytes=5-", 10, &ByteRange{5, 9}, nil},
+		{"bytes=-3", 10, &ByteRange{7, 9}, nil},
+		{"bytes=-20", 10, &ByteRange{0, 9}, nil},
+		{"bytes=8-20", 10, &ByteRange{8, 9}, nil},
+		{"bytes=9-9", 10, &ByteRange{9, 9}, nil},
+		{"bytes=10-", 10, nil, &ErrInvalidRange},
+		{"bytes=-0", 10, nil, &ErrInvalidRange},
+		{"bytes=0-", 0, nil, &ErrInvalidRange},
+		{"bytes=-1", 0, nil, &ErrInvalidRange},
+		{"bytes=5-4", 10, nil, nil},
+		{"bytes=0-1,3-4", 10, nil, nil},
+		{"bytes=a-b", 10, nil, nil},
+		{"items=0-4", 10, nil, nil},
+	}
+
+	for _, test := range tests {
+		br, err := ParseRange(test.header, test.size)
+
+		if err != test.err || (br == nil) != (test.br == nil) || br != nil && *br != *test.br {
+			t.Errorf("%q of %d bytes: expected %v, %v, got %v, %v", test.header, test.size, test.br, test.err, br, err)
+		}
+	}
+
+	if cr := (&ByteRange{2, 4}).ContentRange(10); cr != "bytes 2-4/10" {
+		t.Errorf("Unexpected Content-Range %s", cr)
+	}
+}
+
+func TestParseCopySourceRange(t *testing.T) {
+	tests := []struct {
+		header string
+		br     *ByteRange
+		err    *Error
+	}{
+		{"", nil, nil},
+		{"bytes=0-4", &ByteRange{0, 4}, nil},
+		{"bytes=9-9", &ByteRange{9, 9}, nil},
+		{"bytes=5-", nil, &ErrInvalidCopySourceRange},
+		{"bytes=-3", nil, &ErrInvalidCopySourceRange},
+		{"bytes=5-4", nil, &ErrInvalidCopySourceRange},
+		{"bytes=0-1,3-4", nil, &ErrInvalidCopySourceRange},
+		{"items=0-4", nil, &ErrInvalidCopySourceRange},
+	}
+
+	for _, test := range tests {
+		br, err := ParseCopySourceRange(test.header)
+
+		if err != test.err || (br == nil) != (test.br == nil) || br != nil && *br != *test.br {
+			t.Errorf("%q: expected %v, %v, got %v, %v", test.header, test.br, test.err, br, err)
+		}
+	}
+
+	if err := (&ByteRange{5, 9}).CheckSize(10); err != nil {
+		t.Errorf("Expected the range to fit, got %v", err)
+	}
+
+	if err := (&ByteRange{5, 10}).CheckSize(10); err != &ErrInvalidRange {
+		t.Errorf("Expected ErrInvalidRange, got %v", err)
+	}
+}
+
+func TestPartRange(t *testing.T) {
+	multipart := &ObjectInfo{Size: 12, PartSizes: []int64{5, 5, 2}}
+	single := &ObjectInfo{Size: 7}
+
+	tests := []struct {
+		info *ObjectInfo
+		n    int
+		br   *ByteRange
+		err  *Error
+	}{
+		{multipart, 1, &ByteRange{0, 4}, nil},
+		{multipart, 3, &ByteRange{10, 11}, nil},
+		{multipart, 4, nil, &ErrInvalidPartNumber},
+		{multipart, 0, nil, &ErrInvalidPartNumber},
+		{single, 1, &ByteRange{0, 6}, nil},
+		{single, 2, nil, &ErrInvalidPartNumber},
+		{&ObjectInfo{}, 1, nil, nil},
+	}
+
+	for i, test := range tests {
+		br, err := test.info.PartRange(test.n)
+
+		if err != test.err || (br == nil) != (test.br == nil) || br != nil && *br != *test.br {
+			t.Errorf("%d: expected %v, %v, got %v, %v", i, test.br, test.err, br, err)
+		}
+	}
+}
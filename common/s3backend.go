@@ -1,6 +1,10 @@
 package common
 
-import "time"
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
 
 //var (
 //ErrNotFound      = errors.New("Not found")
@@ -10,7 +14,7 @@ import "time"
 
 type ListAllMyBucketsResult struct {
 	Owner   Owner
-	Buckets []*Bucket
+	Buckets []*Bucket `xml:"Buckets>Bucket"`
 }
 
 type Bucket struct {
@@ -18,41 +22,110 @@ type Bucket struct {
 	CreationDate time.Time
 }
 
-type ListBucketResult struct {
-	Name        string
-	Prefix      string
-	Marker      string
-	MaxKeys     int
-	IsTruncated bool
-	Contents    []Contents
-}
+type Metadata struct{}
 
-type Contents struct {
-	Key          string
-	LastModified *time.Time
-	ETag         string
-	Size         int
-	StorageClass string
-	Owner        Owner
+// ObjectReader gives access to the contents of a stored object. It is
+// seekable, so parts of an object can be read without reading the whole
+// object. The caller has to close it.
+type ObjectReader interface {
+	io.ReadSeeker
+	io.Closer
 }
 
-type Metadata struct{}
-
+// S3Backend is implemented by the storage backends. Object contents are
+// passed as streams in both directions, so objects never have to be held in
+// memory by the server. The size passed along with a reader is the number of
+// bytes announced by the client, or -1 if it is unknown.
+//
+// Objects are written with the metadata of an ObjectInfo, nil for none, and
+// writes return the ObjectInfo of the new version. Its version ID is
+// NullVersionID unless versioning is enabled on the bucket. An empty version
+// ID addresses the current version of an object. If the addressed version is
+// a delete marker, reads return its ObjectInfo along with the error,
+// ErrNoSuchKey for the current version and ErrMethodNotAllowed for a specific
+// one.
+//
+// principal is the account making the request. It becomes the owner of
+// created buckets, objects and uploads, and GetService lists the buckets it
+// owns. Permissions are checked by the request layer. Buckets and objects are
+// stored with the grants of their ACL, nil for the private canned ACL.
+// Bucket policies are stored as the document set by the client, which is
+// validated by the request layer.
+//
+// ApplyLifecycle expires the objects, versions and uploads of all buckets as
+// their lifecycle rules demand at now. ObjectExpiration returns when the
+// addressed version expires, or the zero time if it is not the current
+// version or no rule applies.
+//
+// PutObject and CompleteMultipartUpload only write if the conditions on the
+// current version of the key hold, nil for none. The conditions are checked
+// before the contents are received and again atomically with the write. If
+// they fail at first, the write fails with the error of CheckWrite, and if
+// they fail only later, a concurrent write got in between and it fails with
+// ErrConditionalRequestConflict.
+//
+// PutObjectCopy copies a version of an object, the current one for an empty
+// version ID, to another key as described by the CopyOptions, without the
+// contents passing through the request layer.
+//
+// UploadPartCopy likewise copies a version of an object, or the range br of
+// it, nil for the whole object, to a part of a multipart upload. The source
+// conditions are checked like those of PutObjectCopy.
+//
+// DeleteObjects deletes the objects like DeleteObject, holding the lock of the
+// backend once for the whole batch. Failures of single objects are reported
+// in the result; only a missing bucket fails the call.
+//
+// Buckets and object versions carry a tag set, which the backends check
+// against the limits of S3. Objects are written with the given tags, nil for
+// none, except for PutObjectCopy which keeps the tags of the source if the
+// tags of the options are nil. Tagging operations on objects return the
+// version ID of the version they addressed.
 type S3Backend interface {
-	GetService(auth string) (*ListAllMyBucketsResult, *Error)
-	DeleteBucket(bucket string, auth string) *Error
-	GetBucketObjects(bucket string, auth string) (*ListBucketResult, *Error)
-	HeadBucket(bucket string, auth string) *Error
-	PutBucket(bucket string, auth string) *Error // More Parameters available
-	DeleteObject(bucket string, object string, auth string) *Error
-	GetObject(bucket string, object string, auth string) ([]byte, string, *Error)
-	//GetObjectStream(bucket string, object string, auth string) (io.WriteCloser, *Error)
-	HeadObject(bucket string, object string, auth string) *Error
-	PutObject(bucket string, object string, data []byte, contentType string, auth string) *Error
-	//PutObjectStream(bucket string, object string, r io.ReadCloser, auth string) *Error
-	PutObjectCopy(bucket string, object string, targetBucket string, targetObject string, auth string) *Error
-	PostObject(bucket string, object string, data []byte, contentType string, auth string) *Error
-	//PostObjectStream(bucket string, object string, r io.ReadCloser, auth string) *Error
+	GetService(principal *Principal) (*ListAllMyBucketsResult, *Error)
+	DeleteBucket(bucket string, principal *Principal) *Error
+	GetBucketObjects(bucket string, params ListParams, principal *Principal) (*ListResp, *Error)
+	ListObjectsV2(bucket string, params ListV2Params, principal *Principal) (*ListV2Resp, *Error)
+	ListObjectVersions(bucket string, params VersionsParams, principal *Principal) (*VersionsResp, *Error)
+	HeadBucket(bucket string, principal *Principal) *Error
+	PutBucket(bucket string, grants []Grant, principal *Principal) *Error // More Parameters available
+	GetBucketACL(bucket string, principal *Principal) (*AccessControlPolicy, *Error)
+	PutBucketACL(bucket string, grants []Grant, principal *Principal) *Error
+	GetBucketPolicy(bucket string, principal *Principal) ([]byte, *Error)
+	PutBucketPolicy(bucket string, policy []byte, principal *Principal) *Error
+	DeleteBucketPolicy(bucket string, principal *Principal) *Error
+	GetBucketCORS(bucket string, principal *Principal) (*CORSConfiguration, *Error)
+	PutBucketCORS(bucket string, cors *CORSConfiguration, principal *Principal) *Error
+	DeleteBucketCORS(bucket string, principal *Principal) *Error
+	GetBucketLifecycle(bucket string, principal *Principal) (*LifecycleConfiguration, *Error)
+	PutBucketLifecycle(bucket string, lifecycle *LifecycleConfiguration, principal *Principal) *Error
+	DeleteBucketLifecycle(bucket string, principal *Principal) *Error
+	GetBucketTagging(bucket string, principal *Principal) ([]Tag, *Error)
+	PutBucketTagging(bucket string, tags []Tag, principal *Principal) *Error
+	DeleteBucketTagging(bucket string, principal *Principal) *Error
+	ObjectExpiration(bucket string, object string, versionID string, principal *Principal) (time.Time, string, *Error) // expiry date, rule ID
+	ApplyLifecycle(now time.Time) *Error
+	GetBucketVersioning(bucket string, principal *Principal) (string, *Error)
+	PutBucketVersioning(bucket string, status string, principal *Principal) *Error
+	DeleteObject(bucket string, object string, versionID string, principal *Principal) (string, bool, *Error) // version ID, delete marker
+	DeleteObjects(bucket string, objects []Object, principal *Principal) (*DeleteResult, *Error)
+	GetObject(bucket string, object string, versionID string, principal *Principal) (ObjectReader, *ObjectInfo, *Error)
+	HeadObject(bucket string, object string, versionID string, principal *Principal) (*ObjectInfo, *Error)
+	PutObject(bucket string, object string, r io.Reader, size int64, info *ObjectInfo, grants []Grant, tags []Tag, cond *Conditions, principal *Principal) (*ObjectInfo, *Error)
+	PutObjectCopy(bucket string, object string, versionID string, targetBucket string, targetObject string, opts *CopyOptions, principal *Principal) (*ObjectInfo, string, *Error) // copy, source version ID
+	PostObject(bucket string, object string, r io.Reader, size int64, info *ObjectInfo, grants []Grant, tags []Tag, principal *Principal) (*ObjectInfo, *Error)
+	GetObjectACL(bucket string, object string, versionID string, principal *Principal) (*AccessControlPolicy, *Error)
+	PutObjectACL(bucket string, object string, versionID string, grants []Grant, principal *Principal) *Error
+	GetObjectTagging(bucket string, object string, versionID string, principal *Principal) ([]Tag, string, *Error) // tags, version ID
+	PutObjectTagging(bucket string, object string, versionID string, tags []Tag, principal *Principal) (string, *Error)
+	DeleteObjectTagging(bucket string, object string, versionID string, principal *Principal) (string, *Error)
+	CreateMultipartUpload(bucket string, object string, info *ObjectInfo, grants []Grant, tags []Tag, principal *Principal) (string, *Error)                                                                                 // upload ID
+	UploadPart(bucket string, object string, uploadID string, partNumber int, r io.Reader, size int64, principal *Principal) (string, *Error)                                                                                // ETag
+	UploadPartCopy(bucket string, object string, versionID string, targetBucket string, targetObject string, uploadID string, partNumber int, br *ByteRange, cond *Conditions, principal *Principal) (*Part, string, *Error) // part, source version ID
+	CompleteMultipartUpload(bucket string, object string, uploadID string, parts []CompletedPart, cond *Conditions, principal *Principal) (string, string, *Error)                                                           // ETag, version ID
+	AbortMultipartUpload(bucket string, object string, uploadID string, principal *Principal) *Error
+	ListParts(bucket string, object string, uploadID string, partNumberMarker int, maxParts int, principal *Principal) (*ListPartsResult, *Error)
+	ListMultipartUploads(bucket string, prefix string, keyMarker string, uploadIDMarker string, maxUploads int, principal *Principal) (*ListMultipartUploadsResult, *Error)
 	Reset()
 }
 
@@ -66,10 +139,32 @@ type Options struct {
 	// x-amz-storage-class []string
 }
 
+// The values of x-amz-metadata-directive and x-amz-tagging-directive.
+const (
+	DirectiveCopy    = "COPY"
+	DirectiveReplace = "REPLACE"
+)
+
+// CopyOptions describe the version written by PutObjectCopy. With the COPY
+// metadata directive, the default, the copy keeps the metadata of the source,
+// with REPLACE it gets the metadata of Info. The copy has the grants of
+// Grants and keeps the tags of the source if Tags is nil. SourceConditions
+// are evaluated on the source with CheckCopySource.
 type CopyOptions struct {
-	Options
 	MetadataDirective string
-	ContentType       string
+	Info              *ObjectInfo
+	Grants            []Grant
+	Tags              []Tag
+	SourceConditions  *Conditions
+}
+
+// Metadata returns the metadata of the copy of the source src.
+func (opts *CopyOptions) Metadata(src *ObjectInfo) *ObjectInfo {
+	if opts.MetadataDirective == DirectiveReplace {
+		return opts.Info
+	}
+
+	return src
 }
 
 // CopyObjectResult is the output from a Copy request
@@ -78,17 +173,20 @@ type CopyObjectResult struct {
 	LastModified string
 }
 
-type ACL string
+// PostResponse is the response of a POST upload with success_action_status
+// 201.
+type PostResponse struct {
+	Location string
+	Bucket   string
+	Key      string
+	ETag     string
+}
 
-const (
-	Private           = ACL("private")
-	PublicRead        = ACL("public-read")
-	PublicReadWrite   = ACL("public-read-write")
-	AuthenticatedRead = ACL("authenticated-read")
-	BucketOwnerRead   = ACL("bucket-owner-read")
-	BucketOwnerFull   = ACL("bucket-owner-full-control")
-)
+// MaxDeleteObjects is the maximum number of keys of a Delete Multiple Objects
+// request.
+const MaxDeleteObjects = 1000
 
+// Delete is the request body of Delete Multiple Objects.
 type Delete struct {
 	Quiet   bool     `xml:"Quiet,omitempty"`
 	Objects []Object `xml:"Object"`
@@ -99,16 +197,60 @@ type Object struct {
 	VersionId string `xml:"VersionId,omitempty"`
 }
 
+// DeleteResult is the response of Delete Multiple Objects.
+type DeleteResult struct {
+	Deleted []DeletedObject
+	Errors  []DeleteError `xml:"Error"`
+}
+
+type DeletedObject struct {
+	Key                   string
+	VersionId             string `xml:",omitempty"`
+	DeleteMarker          bool   `xml:",omitempty"`
+	DeleteMarkerVersionId string `xml:",omitempty"`
+}
+
+type DeleteError struct {
+	Key       string
+	VersionId string `xml:",omitempty"`
+	Code      string
+	Message   string
+}
+
+// Add records the outcome of deleting o, given the results of DeleteObject.
+func (res *DeleteResult) Add(o Object, versionID string, deleteMarker bool, awserr *Error) {
+	if awserr != nil {
+		res.Errors = append(res.Errors, DeleteError{Key: o.Key, VersionId: o.VersionId, Code: awserr.Code, Message: awserr.Message})
+		return
+	}
+
+	d := DeletedObject{Key: o.Key, VersionId: o.VersionId}
+
+	if deleteMarker {
+		d.DeleteMarker = true
+		d.DeleteMarkerVersionId = versionID
+	}
+
+	res.Deleted = append(res.Deleted, d)
+}
+
+// ListResp is the result of GET Bucket (List Objects).
 type ListResp struct {
+	XMLName        xml.Name `xml:"ListBucketResult"`
 	Name           string
 	Prefix         string
-	Delimiter      string
+	Delimiter      string `xml:",omitempty"`
 	Marker         string
-	NextMarker     string
+	NextMarker     string `xml:",omitempty"`
 	MaxKeys        int
+	EncodingType   string `xml:",omitempty"`
 	IsTruncated    bool
 	Contents       []Key
-	CommonPrefixes []string `xml:">Prefix"`
+	CommonPrefixes []CommonPrefix
+}
+
+type CommonPrefix struct {
+	Prefix string
 }
 
 // The Key type represents an item stored in an S3 bucket.
@@ -118,30 +260,7 @@ type Key struct {
 	Size         int64
 	ETag         string
 	StorageClass string
-	Owner        Owner
-}
-
-type VersionsResp struct {
-	Name            string
-	Prefix          string
-	KeyMarker       string
-	VersionIdMarker string
-	MaxKeys         int
-	Delimiter       string
-	IsTruncated     bool
-	Versions        []Version
-	CommonPrefixes  []string `xml:">Prefix"`
-}
-
-type Version struct {
-	Key          string
-	VersionId    string
-	IsLatest     bool
-	LastModified string
-	ETag         string
-	Size         int64
-	Owner        Owner
-	StorageClass string
+	Owner        *Owner `xml:",omitempty"`
 }
 
 type Owner struct {
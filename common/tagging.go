@@ -0,0 +1,121 @@
+package common
+
+import (
+	"encoding/xml"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits of S3 on tags. Lengths are counted in characters.
+const (
+	MaxObjectTags     = 10
+	MaxBucketTags     = 50
+	MaxTagKeyLength   = 128
+	MaxTagValueLength = 256
+)
+
+// Tag is a tag of a bucket or an object, and a condition of lifecycle filters.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// Tagging is the body of PUT and GET Bucket and Object tagging.
+type Tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	TagSet  TagSet
+}
+
+// TagSet is a struct of its own, so an empty set is written as <TagSet/>.
+type TagSet struct {
+	Tags []Tag `xml:"Tag"`
+}
+
+// NewTagging returns the document of a tag set.
+func NewTagging(tags []Tag) *Tagging {
+	return &Tagging{TagSet: TagSet{Tags: tags}}
+}
+
+// ValidateObjectTags checks the tag set of an object.
+func ValidateObjectTags(tags []Tag) *Error {
+	if len(tags) > MaxObjectTags {
+		return &ErrObjectTagCount
+	}
+
+	return validateTags(tags)
+}
+
+// ValidateBucketTags checks the tag set of a bucket.
+func ValidateBucketTags(tags []Tag) *Error {
+	if len(tags) > MaxBucketTags {
+		return &ErrBucketTagCount
+	}
+
+	return validateTags(tags)
+}
+
+// validateTags checks the keys and values of a tag set. Keys are unique, and
+// the prefix aws: is reserved for tags set by AWS.
+func validateTags(tags []Tag) *Error {
+	keys := make(map[string]bool, len(tags))
+
+	for _, t := range tags {
+		if t.Key == "" || strings.HasPrefix(strings.ToLower(t.Key), "aws:") {
+			return &ErrInvalidTagKey
+		} else if utf8.RuneCountInString(t.Key) > MaxTagKeyLength {
+			return &ErrTagKeyTooLong
+		} else if utf8.RuneCountInString(t.Value) > MaxTagValueLength {
+			return &ErrTagValueTooLong
+		} else if keys[t.Key] {
+			return &ErrDuplicateTagKey
+		}
+
+		keys[t.Key] = true
+	}
+
+	return nil
+}
+
+// ParseTaggingHeader parses the x-amz-tagging header, which holds the tags
+// URL-encoded like a query string. The tags are ordered by key.
+func ParseTaggingHeader(s string) ([]Tag, *Error) {
+	values, err := url.ParseQuery(s)
+
+	if err != nil {
+		return nil, &ErrInvalidTag
+	}
+
+	keys := make([]string, 0, len(values))
+
+	for k, v := range values {
+		if len(v) > 1 {
+			return nil, &ErrDuplicateTagKey
+		}
+
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	tags := make([]Tag, len(keys))
+
+	for i, k := range keys {
+		tags[i] = Tag{Key: k, Value: values[k][0]}
+	}
+
+	return tags, nil
+}
+
+// TagMap returns the tags as a map of keys to values, as lifecycle rules
+// match them.
+func TagMap(tags []Tag) map[string]string {
+	m := make(map[string]string, len(tags))
+
+	for _, t := range tags {
+		m[t.Key] = t.Value
+	}
+
+	return m
+}
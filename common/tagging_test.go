@@ -0,0 +1,88 @@
+package common
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateTags(t *testing.T) {
+	many := func(n int) []Tag {
+		tags := make([]Tag, n)
+
+		for i := range tags {
+			tags[i] = Tag{Key: strings.Repeat("k", i+1)}
+		}
+
+		return tags
+	}
+
+	tests := []struct {
+		tags   []Tag
+		object *Error
+		bucket *Error
+	}{
+		{nil, nil, nil},
+		{[]Tag{{"project", "s3server"}, {"empty", ""}}, nil, nil},
+		{many(11), &ErrObjectTagCount, nil},
+		{many(51), &ErrObjectTagCount, &ErrBucketTagCount},
+		{[]Tag{{"", "v"}}, &ErrInvalidTagKey, &ErrInvalidTagKey},
+		{[]Tag{{"aws:createdBy", "v"}}, &ErrInvalidTagKey, &ErrInvalidTagKey},
+		{[]Tag{{strings.Repeat("ü", 128), strings.Repeat("ü", 256)}}, nil, nil},
+		{[]Tag{{strings.Repeat("k", 129), "v"}}, &ErrTagKeyTooLong, &ErrTagKeyTooLong},
+		{[]Tag{{"k", strings.Repeat("v", 257)}}, &ErrTagValueTooLong, &ErrTagValueTooLong},
+		{[]Tag{{"k", "a"}, {"k", "b"}}, &ErrDuplicateTagKey, &ErrDuplicateTagKey},
+	}
+
+	for i, test := range tests {
+		if err := ValidateObjectTags(test.tags); err != test.object {
+			t.Errorf("%d: expected %v for an object, got %v", i, test.object, err)
+		}
+
+		if err := ValidateBucketTags(test.tags); err != test.bucket {
+			t.Errorf("%d: expected %v for a bucket, got %v", i, test.bucket, err)
+		}
+	}
+}
+
+func TestParseTaggingHeader(t *testing.T) {
+	tests := []struct {
+		header string
+		tags   []Tag
+		err    *Error
+	}{
+		{"", []Tag{}, nil},
+		{"b=2&a=1", []Tag{{"a", "1"}, {"b", "2"}}, nil},
+		{"key%20with%20space=a%26b&empty", []Tag{{"empty", ""}, {"key with space", "a&b"}}, nil},
+		{"a=1&a=2", nil, &ErrDuplicateTagKey},
+		{"a=%zz", nil, &ErrInvalidTag},
+	}
+
+	for _, test := range tests {
+		tags, err := ParseTaggingHeader(test.header)
+
+		if err != test.err || (err == nil && !reflect.DeepEqual(tags, test.tags)) {
+			t.Errorf("%q: expected %v, %v, got %v, %v", test.header, test.tags, test.err, tags, err)
+		}
+	}
+}
+
+func TestTaggingXML(t *testing.T) {
+	b, err := xml.Marshal(NewTagging(nil))
+
+	if err != nil || string(b) != "<Tagging><TagSet></TagSet></Tagging>" {
+		t.Errorf("Expected an empty tag set, got %s, %v", b, err)
+	}
+
+	tagging := Tagging{}
+	doc := "<Tagging><TagSet><Tag><Key>a</Key><Value>1</Value></Tag><Tag><Key>b</Key><Value></Value></Tag></TagSet></Tagging>"
+
+	if err := xml.Unmarshal([]byte(doc), &tagging); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []Tag{{"a", "1"}, {"b", ""}}; !reflect.DeepEqual(tagging.TagSet.Tags, expected) {
+		t.Errorf("Expected %v, got %v", expected, tagging.TagSet.Tags)
+	}
+}
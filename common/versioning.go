@@ -0,0 +1,189 @@
+package common
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/xml"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Versioning states of a bucket. Buckets which never had versioning enabled
+// have the empty state.
+const (
+	VersioningEnabled   = "Enabled"
+	VersioningSuspended = "Suspended"
+)
+
+// NullVersionID is the version ID of objects written while versioning is not
+// enabled. Such a write replaces the previous null version.
+const NullVersionID = "null"
+
+// VersioningConfiguration is the body of PUT and GET Bucket versioning.
+type VersioningConfiguration struct {
+	XMLName xml.Name `xml:"VersioningConfiguration"`
+	Status  string   `xml:",omitempty"`
+}
+
+// VersionsParams are the parameters of GET Bucket Object versions.
+type VersionsParams struct {
+	Prefix          string
+	Delimiter       string
+	KeyMarker       string
+	VersionIdMarker string
+	MaxKeys         int
+}
+
+// VersionsResp is the result of GET Bucket Object versions.
+type VersionsResp struct {
+	XMLName             xml.Name `xml:"ListVersionsResult"`
+	Name                string
+	Prefix              string
+	KeyMarker           string
+	VersionIdMarker     string
+	NextKeyMarker       string `xml:",omitempty"`
+	NextVersionIdMarker string `xml:",omitempty"`
+	MaxKeys             int
+	Delimiter           string `xml:",omitempty"`
+	EncodingType        string `xml:",omitempty"`
+	IsTruncated         bool
+	Versions            []Version           `xml:"Version"`
+	DeleteMarkers       []DeleteMarkerEntry `xml:"DeleteMarker"`
+	CommonPrefixes      []CommonPrefix
+}
+
+// Version is a version of an object. Backends pass delete markers as versions
+// as well, they are returned as DeleteMarkerEntry.
+type Version struct {
+	Key          string
+	VersionId    string
+	IsLatest     bool
+	LastModified string
+	ETag         string
+	Size         int64
+	Owner        *Owner `xml:",omitempty"`
+	StorageClass string
+	DeleteMarker bool `xml:"-"`
+}
+
+// DeleteMarkerEntry is a delete marker in a versions listing.
+type DeleteMarkerEntry struct {
+	Key          string
+	VersionId    string
+	IsLatest     bool
+	LastModified string
+	Owner        *Owner `xml:",omitempty"`
+}
+
+// NewVersionID returns a random version ID.
+func NewVersionID() string {
+	b := make([]byte, 24)
+
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+type versionsByKey []Version
+
+func (v versionsByKey) Len() int           { return len(v) }
+func (v versionsByKey) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v versionsByKey) Less(i, j int) bool { return v[i].Key < v[j].Key }
+
+// ListObjectVersions builds the versions listing of the bucket name. The
+// versions of every key have to be passed newest first, the keys may be in
+// any order. Listing starts after all versions of KeyMarker, or after the
+// version VersionIdMarker of KeyMarker if it is given. Common prefixes are
+// rolled up like in ListObjects.
+func ListObjectVersions(name string, versions []Version, params VersionsParams) *VersionsResp {
+	if params.MaxKeys < 0 || params.MaxKeys > MaxKeys {
+		params.MaxKeys = MaxKeys
+	}
+
+	sort.Stable(versionsByKey(versions))
+
+	res := &VersionsResp{
+		Name:            name,
+		Prefix:          params.Prefix,
+		KeyMarker:       params.KeyMarker,
+		VersionIdMarker: params.VersionIdMarker,
+		MaxKeys:         params.MaxKeys,
+		Delimiter:       params.Delimiter,
+		Versions:        make([]Version, 0),
+	}
+
+	count := 0
+	next, nextVersion := "", ""
+	pastMarker := false
+
+	for _, v := range versions {
+		if !strings.HasPrefix(v.Key, params.Prefix) || v.Key < params.KeyMarker {
+			continue
+		}
+
+		if v.Key == params.KeyMarker && !pastMarker {
+			pastMarker = params.VersionIdMarker != "" && v.VersionId == params.VersionIdMarker
+			continue
+		}
+
+		cp := commonPrefix(v.Key, params.Prefix, params.Delimiter)
+
+		if cp != "" && (cp == next || cp <= params.KeyMarker) {
+			continue
+		}
+
+		if count == params.MaxKeys {
+			res.IsTruncated = true
+			res.NextKeyMarker = next
+			res.NextVersionIdMarker = nextVersion
+			break
+		}
+
+		switch {
+		case cp != "":
+			res.CommonPrefixes = append(res.CommonPrefixes, CommonPrefix{cp})
+			next, nextVersion = cp, ""
+		case v.DeleteMarker:
+			res.DeleteMarkers = append(res.DeleteMarkers, DeleteMarkerEntry{
+				Key:          v.Key,
+				VersionId:    v.VersionId,
+				IsLatest:     v.IsLatest,
+				LastModified: v.LastModified,
+				Owner:        v.Owner,
+			})
+			next, nextVersion = v.Key, v.VersionId
+		default:
+			res.Versions = append(res.Versions, v)
+			next, nextVersion = v.Key, v.VersionId
+		}
+
+		count++
+	}
+
+	return res
+}
+
+// URLEncode encodes all keys and prefixes of the listing, like
+// ListResp.URLEncode.
+func (l *VersionsResp) URLEncode() {
+	l.EncodingType = "url"
+	l.Prefix = url.QueryEscape(l.Prefix)
+	l.Delimiter = url.QueryEscape(l.Delimiter)
+	l.KeyMarker = url.QueryEscape(l.KeyMarker)
+	l.NextKeyMarker = url.QueryEscape(l.NextKeyMarker)
+
+	for i := range l.Versions {
+		l.Versions[i].Key = url.QueryEscape(l.Versions[i].Key)
+	}
+
+	for i := range l.DeleteMarkers {
+		l.DeleteMarkers[i].Key = url.QueryEscape(l.DeleteMarkers[i].Key)
+	}
+
+	for i := range l.CommonPrefixes {
+		l.CommonPrefixes[i].Prefix = url.QueryEscape(l.CommonPrefixes[i].Prefix)
+	}
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func listedVersions(l *VersionsResp) []string {
+	listed := make([]string, 0)
+
+	for _, v := range l.Versions {
+		listed = append(listed, v.Key+"@"+v.VersionId)
+	}
+
+	for _, m := range l.DeleteMarkers {
+		listed = append(listed, m.Key+"@"+m.VersionId+"!")
+	}
+
+	for _, cp := range l.CommonPrefixes {
+		listed = append(listed, cp.Prefix)
+	}
+
+	return listed
+}
+
+var listingVersions = []Version{
+	{Key: "b", VersionId: "b2", IsLatest: true},
+	{Key: "b", VersionId: "b1"},
+	{Key: "a", VersionId: "a3", IsLatest: true, DeleteMarker: true},
+	{Key: "a", VersionId: "a2"},
+	{Key: "a", VersionId: "a1"},
+	{Key: "dir/x", VersionId: "x1", IsLatest: true},
+}
+
+func TestListObjectVersions(t *testing.T) {
+	tests := []struct {
+		params        VersionsParams
+		listed        []string
+		truncated     bool
+		nextKey       string
+		nextVersionID string
+	}{
+		{VersionsParams{MaxKeys: 1000}, []string{"a@a2", "a@a1", "b@b2", "b@b1", "dir/x@x1", "a@a3!"}, false, "", ""},
+		{VersionsParams{MaxKeys: 2}, []string{"a@a2", "a@a3!"}, true, "a", "a2"},
+		{VersionsParams{KeyMarker: "a", VersionIdMarker: "a2", MaxKeys: 2}, []string{"a@a1", "b@b2"}, true, "b", "b2"},
+		{VersionsParams{KeyMarker: "a", MaxKeys: 1000}, []string{"b@b2", "b@b1", "dir/x@x1"}, false, "", ""},
+		{VersionsParams{Delimiter: "/", KeyMarker: "b", MaxKeys: 1000}, []string{"dir/"}, false, "", ""},
+		{VersionsParams{Prefix: "dir/", MaxKeys: 1000}, []string{"dir/x@x1"}, false, "", ""},
+	}
+
+	for _, test := range tests {
+		l := ListObjectVersions("bucket", append([]Version(nil), listingVersions...), test.params)
+
+		if !reflect.DeepEqual(listedVersions(l), test.listed) {
+			t.Errorf("%+v: expected %v, got %v", test.params, test.listed, listedVersions(l))
+		}
+
+		if l.IsTruncated != test.truncated || l.NextKeyMarker != test.nextKey || l.NextVersionIdMarker != test.nextVersionID {
+			t.Errorf("%+v: expected truncated %v at %q/%q, got %v at %q/%q", test.params, test.truncated, test.nextKey, test.nextVersionID, l.IsTruncated, l.NextKeyMarker, l.NextVersionIdMarker)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+// requestConditions returns the preconditions set by the If-* headers of r,
+// each prefixed with prefix. Dates which cannot be parsed are ignored.
+func requestConditions(r *http.Request, prefix string) *common.Conditions {
+	c := &common.Conditions{
+		IfMatch:     r.Header.Get(prefix + "If-Match"),
+		IfNoneMatch: r.Header.Get(prefix + "If-None-Match"),
+	}
+
+	c.IfModifiedSince, _ = http.ParseTime(r.Header.Get(prefix + "If-Modified-Since"))
+	c.IfUnmodifiedSince, _ = http.ParseTime(r.Header.Get(prefix + "If-Unmodified-Since"))
+
+	return c
+}
+
+// writeConditions returns the preconditions of a PUT Object or Complete
+// Multipart Upload request. Like S3, it only accepts If-None-Match: * and
+// If-Match on writes.
+func writeConditions(r *http.Request) (*common.Conditions, *common.Error) {
+	c := &common.Conditions{
+		IfMatch:     r.Header.Get("If-Match"),
+		IfNoneMatch: r.Header.Get("If-None-Match"),
+	}
+
+	if c.IfNoneMatch != "" && c.IfNoneMatch != "*" {
+		return nil, &common.ErrNotImplemented
+	}
+
+	return c, nil
+}
+
+// checkReadConditions evaluates the preconditions of a GET or HEAD request on
+// the object described by info. If the object was not modified, it writes
+// the 304 response, which has no body, and returns false, like for failed
+// preconditions.
+func checkReadConditions(w http.ResponseWriter, r *http.Request, info *common.ObjectInfo) bool {
+	awserr := requestConditions(r, "").Check(info)
+
+	if awserr == &common.ErrNotModified {
+		w.Header().Set("ETag", info.ETag)
+		w.Header().Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusNotModified)
+		return false
+	}
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return false
+	}
+
+	return true
+}
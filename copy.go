@@ -0,0 +1,176 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+// copySource returns the request reading the source of a copy, named by the
+// URL-encoded x-amz-copy-source header as bucket/key, optionally with a
+// leading slash and a versionId parameter. The principal of the copy has to
+// be authorized to read the source.
+func copySource(r *http.Request, rd *S3Request) (*S3Request, *common.Error) {
+	source := r.Header.Get("x-amz-copy-source")
+	var query string
+
+	if i := strings.Index(source, "?"); i >= 0 {
+		source, query = source[:i], source[i+1:]
+	}
+
+	source, err := url.PathUnescape(strings.TrimPrefix(source, "/"))
+
+	if err != nil {
+		return nil, &common.ErrInvalidCopySource
+	}
+
+	i := strings.Index(source, "/")
+
+	if i <= 0 || i == len(source)-1 {
+		return nil, &common.ErrInvalidCopySource
+	}
+
+	params, err := url.ParseQuery(query)
+
+	if err != nil {
+		return nil, &common.ErrInvalidCopySource
+	}
+
+	src := &S3Request{
+		bucket:    source[:i],
+		object:    source[i+1:],
+		method:    "GET",
+		s3method:  GETOBJECT,
+		principal: rd.principal,
+		params:    map[string][]string{},
+	}
+
+	if v := params.Get("versionId"); v != "" {
+		src.params["versionId"] = []string{v}
+	}
+
+	if awserr := authorize(r, src); awserr != nil {
+		return nil, awserr
+	}
+
+	return src, nil
+}
+
+// directive returns the value of a COPY/REPLACE directive header, COPY if it
+// is not set.
+func directive(r *http.Request, name string) (string, *common.Error) {
+	switch d := r.Header.Get(name); d {
+	case "", common.DirectiveCopy:
+		return common.DirectiveCopy, nil
+	case common.DirectiveReplace:
+		return d, nil
+	}
+
+	return "", &common.ErrInvalidArgument
+}
+
+// copyOptions returns the options of a copy set by the headers of r.
+func copyOptions(r *http.Request, rd *S3Request) (*common.CopyOptions, *common.Error) {
+	opts := &common.CopyOptions{SourceConditions: requestConditions(r, "x-amz-copy-source-")}
+	var awserr *common.Error
+
+	if opts.MetadataDirective, awserr = directive(r, "x-amz-metadata-directive"); awserr != nil {
+		return nil, awserr
+	}
+
+	if opts.MetadataDirective == common.DirectiveReplace {
+		if opts.Info, awserr = objectMetadata(r, rd); awserr != nil {
+			return nil, awserr
+		}
+	}
+
+	tagging, awserr := directive(r, "x-amz-tagging-directive")
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	if tagging == common.DirectiveReplace {
+		if opts.Tags, awserr = requestTags(r); awserr != nil {
+			return nil, awserr
+		} else if opts.Tags == nil {
+			opts.Tags = []common.Tag{}
+		}
+	}
+
+	if opts.Grants, awserr = objectGrants(r, rd); awserr != nil {
+		return nil, awserr
+	}
+
+	return opts, nil
+}
+
+func copyObjectHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("copyObjectHandler", rd)
+	src, awserr := copySource(r, rd)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	opts, awserr := copyOptions(r, rd)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	if src.bucket == rd.bucket && src.object == rd.object && opts.MetadataDirective == common.DirectiveCopy && !src.HasParam("versionId") {
+		writeError(w, &common.ErrInvalidCopyRequest)
+		return
+	}
+
+	info, versionID, awserr := backend.PutObjectCopy(src.bucket, src.object, src.Param("versionId"), rd.bucket, rd.object, opts, rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	setVersionHeader(w, "x-amz-copy-source-version-id", src, versionID)
+	setVersionHeader(w, "x-amz-version-id", rd, info.VersionId)
+	writeXML(w, &common.CopyObjectResult{ETag: info.ETag, LastModified: common.FormatTime(info.LastModified)})
+}
+
+func uploadPartCopyHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("uploadPartCopyHandler", rd)
+	partNumber, awserr := rd.IntParam("partNumber", 0)
+
+	if awserr != nil || partNumber < 1 || partNumber > common.MaxPartNumber {
+		writeError(w, &common.ErrInvalidArgument)
+		return
+	}
+
+	br, awserr := common.ParseCopySourceRange(r.Header.Get("x-amz-copy-source-range"))
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	src, awserr := copySource(r, rd)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	cond := requestConditions(r, "x-amz-copy-source-")
+	part, versionID, awserr := backend.UploadPartCopy(src.bucket, src.object, src.Param("versionId"), rd.bucket, rd.object, rd.Param("uploadId"), partNumber, br, cond, rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	setVersionHeader(w, "x-amz-copy-source-version-id", src, versionID)
+	writeXML(w, &common.CopyPartResult{ETag: part.ETag, LastModified: part.LastModified})
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+// writeCORSHeaders sets the headers allowing the response to be read by the
+// origin.
+func writeCORSHeaders(w http.ResponseWriter, rule *common.CORSRule, origin string) {
+	h := w.Header()
+
+	if rule.AllowsAnyOrigin() {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	h.Set("Access-Control-Allow-Methods", strings.Join(rule.AllowedMethods, ", "))
+
+	if len(rule.ExposeHeaders) > 0 {
+		h.Set("Access-Control-Expose-Headers", strings.Join(rule.ExposeHeaders, ", "))
+	}
+
+	h.Set("Vary", "Origin, Access-Control-Request-Headers, Access-Control-Request-Method")
+}
+
+// setCORSHeaders adds the CORS headers to the response of a request sent with
+// an Origin header, if the CORS configuration of the bucket allows it.
+func setCORSHeaders(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	cors, awserr := backend.GetBucketCORS(rd.bucket, rd.principal)
+
+	if awserr != nil {
+		return
+	}
+
+	origin := r.Header.Get("Origin")
+
+	if rule := cors.Match(origin, r.Method, nil); rule != nil {
+		writeCORSHeaders(w, rule, origin)
+	}
+}
+
+// optionsObjectHandler answers preflight requests, which ask whether a
+// cross-origin request with the method and headers may be sent.
+func optionsObjectHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("optionsObjectHandler", rd)
+	origin := r.Header.Get("Origin")
+	method := r.Header.Get("Access-Control-Request-Method")
+
+	if origin == "" {
+		writeError(w, &common.ErrCORSMissingOrigin)
+		return
+	} else if method == "" {
+		writeError(w, &common.ErrCORSMissingMethod)
+		return
+	}
+
+	cors, awserr := backend.GetBucketCORS(rd.bucket, rd.principal)
+
+	if awserr == &common.ErrNoSuchCORSConfiguration {
+		writeError(w, &common.ErrCORSNotEnabled)
+		return
+	} else if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	headers := make([]string, 0)
+
+	for _, v := range r.Header["Access-Control-Request-Headers"] {
+		for _, h := range strings.Split(v, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				headers = append(headers, h)
+			}
+		}
+	}
+
+	rule := cors.Match(origin, method, headers)
+
+	if rule == nil {
+		writeError(w, &common.ErrCORSForbidden)
+		return
+	}
+
+	writeCORSHeaders(w, rule, origin)
+
+	if len(headers) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	}
+
+	if rule.MaxAgeSeconds > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(rule.MaxAgeSeconds))
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func getBucketCORSHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("getBucketCORSHandler", rd)
+	cors, awserr := backend.GetBucketCORS(rd.bucket, rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	writeXML(w, cors)
+}
+
+func putBucketCORSHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("putBucketCORSHandler", rd)
+	cors := &common.CORSConfiguration{}
+
+	if awserr := readXML(r, cors, &common.ErrMalformedXML); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	if awserr := cors.Validate(); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	if awserr := backend.PutBucketCORS(rd.bucket, cors, rd.principal); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func deleteBucketCORSHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("deleteBucketCORSHandler", rd)
+
+	if awserr := backend.DeleteBucketCORS(rd.bucket, rd.principal); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
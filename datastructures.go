@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/0x434D53/s3server/common"
@@ -11,6 +12,8 @@ type S3METHOD int
 
 func (s S3METHOD) String() string {
 	switch s {
+	case GETSERVICE:
+		return "GET Service"
 	case GETBUCKET_OBJECTLIST:
 		return "GET Bucket objectlist"
 	case GETBUCKET:
@@ -99,6 +102,32 @@ func (s S3METHOD) String() string {
 		return "PUT Object"
 	case PUTOBJECT_ACL:
 		return "PUT Object acl"
+	case POSTOBJECT_UPLOADS:
+		return "Initiate Multipart Upload"
+	case PUTOBJECT_PART:
+		return "Upload Part"
+	case POSTOBJECT_COMPLETEUPLOAD:
+		return "Complete Multipart Upload"
+	case DELETEOBJECT_UPLOAD:
+		return "Abort Multipart Upload"
+	case GETOBJECT_PARTS:
+		return "List Parts"
+	case GETBUCKET_UPLOADS:
+		return "List Multipart Uploads"
+	case GETBUCKET_V2:
+		return "GET Bucket (List Objects) Version 2"
+	case OPTIONSOBJECT:
+		return "OPTIONS object"
+	case GETOBJECT_TAGGING:
+		return "GET Object tagging"
+	case PUTOBJECT_TAGGING:
+		return "PUT Object tagging"
+	case DELETEOBJECT_TAGGING:
+		return "DELETE Object tagging"
+	case PUTOBJECT_PART_COPY:
+		return "Upload Part - Copy"
+	case POSTBUCKET_DELETE:
+		return "Delete Multiple Objects"
 	}
 
 	return ""
@@ -243,6 +272,20 @@ const (
 	PUTOBJECT
 	PUTOBJECT_ACL
 	PUTOBJECT_COPY
+	GETSERVICE
+	POSTOBJECT_UPLOADS
+	PUTOBJECT_PART
+	POSTOBJECT_COMPLETEUPLOAD
+	DELETEOBJECT_UPLOAD
+	GETOBJECT_PARTS
+	GETBUCKET_UPLOADS
+	GETBUCKET_V2
+	OPTIONSOBJECT
+	GETOBJECT_TAGGING
+	PUTOBJECT_TAGGING
+	DELETEOBJECT_TAGGING
+	PUTOBJECT_PART_COPY
+	POSTBUCKET_DELETE
 )
 
 type S3Request struct {
@@ -255,6 +298,10 @@ type S3Request struct {
 	lastModified         time.Time
 	versionID            string
 	serversideEncryption bool
+	virtualHost          bool                        // bucket addressed by the Host header
+	principal            *common.Principal           // account the request is made by
+	bucketACL            *common.AccessControlPolicy // ACL of the addressed bucket
+	bucketPolicy         *common.Policy              // policy of the bucket of a multi-object delete
 	params map[string][]string
 }
 
@@ -268,3 +315,40 @@ func (s S3Request) HasParam(param string) bool {
 	}
 	return false
 }
+
+// Param returns the first value of the query parameter, or "" if it is not
+// set.
+func (s S3Request) Param(param string) string {
+	if v := s.params[param]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// IntParam returns the value of an integer query parameter, or def if it is
+// not set.
+func (s S3Request) IntParam(param string, def int) (int, *common.Error) {
+	if !s.HasParam(param) {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(s.Param(param))
+
+	if err != nil || i < 0 {
+		return 0, &common.ErrInvalidArgument
+	}
+
+	return i, nil
+}
+
+// EncodingType returns the encoding-type of a listing request. The only
+// encoding supported by S3 is "url".
+func (s S3Request) EncodingType() (string, *common.Error) {
+	e := s.Param("encoding-type")
+
+	if e != "" && e != "url" {
+		return "", &common.ErrInvalidArgument
+	}
+
+	return e, nil
+}
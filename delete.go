@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+// deleteObjectsHandler handles Delete Multiple Objects. Every key is
+// authorized like a DELETE Object request, against the bucket ACL and policy
+// read once by authorize, and keys the principal may not delete are reported
+// as errors without reaching the backend.
+func deleteObjectsHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("deleteObjectsHandler", rd)
+
+	if _, ok := r.Header["Content-Md5"]; !ok {
+		writeError(w, &common.ErrMissingContentMD5)
+		return
+	}
+
+	d := common.Delete{}
+
+	if awserr := readXML(r, &d, &common.ErrMalformedXML); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	if len(d.Objects) == 0 || len(d.Objects) > common.MaxDeleteObjects {
+		writeError(w, &common.ErrMalformedXML)
+		return
+	}
+
+	denied := &common.DeleteResult{}
+	objects := make([]common.Object, 0, len(d.Objects))
+
+	for _, o := range d.Objects {
+		if o.Key == "" {
+			writeError(w, &common.ErrMalformedXML)
+			return
+		}
+
+		if len(o.Key) > maxKeyLength {
+			denied.Add(o, "", false, &common.ErrKeyTooLong)
+			continue
+		}
+
+		kr := &S3Request{
+			bucket:    rd.bucket,
+			object:    o.Key,
+			method:    "DELETE",
+			s3method:  DELETEOBJECT,
+			principal: rd.principal,
+			params:    map[string][]string{},
+			bucketACL: rd.bucketACL,
+		}
+
+		if o.VersionId != "" {
+			kr.params["versionId"] = []string{o.VersionId}
+		}
+
+		if awserr := checkAccess(r, kr, rd.bucketPolicy); awserr != nil {
+			denied.Add(o, "", false, awserr)
+			continue
+		}
+
+		objects = append(objects, o)
+	}
+
+	res := &common.DeleteResult{}
+
+	if len(objects) > 0 {
+		var awserr *common.Error
+
+		if res, awserr = backend.DeleteObjects(rd.bucket, objects, rd.principal); awserr != nil {
+			writeError(w, awserr)
+			return
+		}
+	}
+
+	res.Errors = append(res.Errors, denied.Errors...)
+
+	if d.Quiet {
+		res.Deleted = nil
+	}
+
+	writeXML(w, res)
+}
@@ -9,10 +9,10 @@ import (
 )
 
 func main() {
-	a := &common.ListBucketResult{}
-	//	a.Contents = make([]common.Contents, 0)
-	a.Contents = append(a.Contents, common.Contents{"test"})
-	a.Contents = append(a.Contents, common.Contents{"test1"})
+	a := &common.ListResp{}
+	//	a.Contents = make([]common.Key, 0)
+	a.Contents = append(a.Contents, common.Key{Key: "test"})
+	a.Contents = append(a.Contents, common.Key{Key: "test1"})
 
 	enc := xml.NewEncoder(os.Stdout)
 	enc.Indent(" ", "  ")
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+// setExpirationHeader sets x-amz-expiration if a lifecycle rule expires the
+// object read by rd.
+func setExpirationHeader(w http.ResponseWriter, rd *S3Request, versionID string) {
+	expires, id, awserr := backend.ObjectExpiration(rd.bucket, rd.object, versionID, rd.principal)
+
+	if awserr == nil && !expires.IsZero() {
+		w.Header().Set("x-amz-expiration", common.ExpirationHeader(expires, id))
+	}
+}
+
+func getBucketLifecycleHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("getBucketLifecycleHandler", rd)
+	lifecycle, awserr := backend.GetBucketLifecycle(rd.bucket, rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	writeXML(w, lifecycle)
+}
+
+func putBucketLifecycleHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("putBucketLifecycleHandler", rd)
+	lifecycle := &common.LifecycleConfiguration{}
+
+	if awserr := readXML(r, lifecycle, &common.ErrMalformedXML); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	if awserr := lifecycle.Validate(); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	if awserr := backend.PutBucketLifecycle(rd.bucket, lifecycle, rd.principal); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func deleteBucketLifecycleHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("deleteBucketLifecycleHandler", rd)
+
+	if awserr := backend.DeleteBucketLifecycle(rd.bucket, rd.principal); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
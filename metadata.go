@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+// metaPrefix is the prefix of the headers carrying user metadata.
+const metaPrefix = "x-amz-meta-"
+
+// defaultContentType is the content type of objects written without one.
+const defaultContentType = "binary/octet-stream"
+
+// objectMetadata returns the metadata set by the headers of a write.
+func objectMetadata(r *http.Request, rd *S3Request) (*common.ObjectInfo, *common.Error) {
+	info := &common.ObjectInfo{
+		ContentType:        rd.ContentType,
+		ContentEncoding:    rd.contentEncoding,
+		CacheControl:       r.Header.Get("Cache-Control"),
+		ContentDisposition: r.Header.Get("Content-Disposition"),
+		Expires:            r.Header.Get("Expires"),
+		StorageClass:       r.Header.Get("x-amz-storage-class"),
+	}
+
+	if info.ContentType == "" {
+		info.ContentType = defaultContentType
+	}
+
+	for name, values := range r.Header {
+		if key := strings.ToLower(name); strings.HasPrefix(key, metaPrefix) {
+			if info.Meta == nil {
+				info.Meta = make(map[string][]string)
+			}
+
+			info.Meta[strings.TrimPrefix(key, metaPrefix)] = values
+		}
+	}
+
+	if awserr := info.Validate(); awserr != nil {
+		return nil, awserr
+	}
+
+	return info, nil
+}
+
+// setObjectHeaders sets the headers describing the object read by a GET or
+// HEAD request rd.
+func setObjectHeaders(w http.ResponseWriter, rd *S3Request, info *common.ObjectInfo) {
+	setCommondResponseHeaders(w, &common.ResponseHeaders{
+		ContentLength: strconv.FormatInt(info.Size, 10),
+		ContentType:   info.ContentType,
+		ETag:          info.ETag,
+	})
+	setVersionHeader(w, "x-amz-version-id", rd, info.VersionId)
+
+	h := w.Header()
+	h.Set("Accept-Ranges", "bytes")
+	h.Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+
+	for name, value := range map[string]string{
+		"Content-Encoding":    info.ContentEncoding,
+		"Cache-Control":       info.CacheControl,
+		"Content-Disposition": info.ContentDisposition,
+		"Expires":             info.Expires,
+	} {
+		if value != "" {
+			h.Set(name, value)
+		}
+	}
+
+	for key, values := range info.Meta {
+		for _, v := range values {
+			h.Add(metaPrefix+key, v)
+		}
+	}
+
+	if info.StorageClass != "" && info.StorageClass != common.StorageClassStandard {
+		h.Set("x-amz-storage-class", info.StorageClass)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+func initiateMultipartUploadHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("initiateMultipartUploadHandler", rd)
+	grants, awserr := objectGrants(r, rd)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	tags, awserr := requestTags(r)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	info, awserr := objectMetadata(r, rd)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	id, awserr := backend.CreateMultipartUpload(rd.bucket, rd.object, info, grants, tags, rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	writeXML(w, &common.InitiateMultipartUploadResult{Bucket: rd.bucket, Key: rd.object, UploadId: id})
+}
+
+func uploadPartHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("uploadPartHandler", rd)
+	partNumber, awserr := rd.IntParam("partNumber", 0)
+
+	if awserr != nil || partNumber < 1 || partNumber > common.MaxPartNumber {
+		writeError(w, &common.ErrInvalidArgument)
+		return
+	}
+
+	etag, awserr := backend.UploadPart(rd.bucket, rd.object, rd.Param("uploadId"), partNumber, r.Body, r.ContentLength, rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusOK)
+}
+
+func completeMultipartUploadHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("completeMultipartUploadHandler", rd)
+	cond, awserr := writeConditions(r)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	cmu := common.CompleteMultipartUpload{}
+
+	if awserr := readXML(r, &cmu, &common.ErrMalformedXML); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	etag, versionID, awserr := backend.CompleteMultipartUpload(rd.bucket, rd.object, rd.Param("uploadId"), cmu.Parts, cond, rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	setVersionHeader(w, "x-amz-version-id", rd, versionID)
+
+	writeXML(w, &common.CompleteMultipartUploadResult{
+		Location: objectURL(r, rd, rd.object),
+		Bucket:   rd.bucket,
+		Key:      rd.object,
+		ETag:     etag,
+	})
+}
+
+func abortMultipartUploadHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("abortMultipartUploadHandler", rd)
+	awserr := backend.AbortMultipartUpload(rd.bucket, rd.object, rd.Param("uploadId"), rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func listPartsHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("listPartsHandler", rd)
+	marker, awserr := rd.IntParam("part-number-marker", 0)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	maxParts, awserr := rd.IntParam("max-parts", common.MaxListParts)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	res, awserr := backend.ListParts(rd.bucket, rd.object, rd.Param("uploadId"), marker, maxParts, rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	writeXML(w, res)
+}
+
+func listMultipartUploadsHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("listMultipartUploadsHandler", rd)
+	maxUploads, awserr := rd.IntParam("max-uploads", common.MaxListParts)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	res, awserr := backend.ListMultipartUploads(rd.bucket, rd.Param("prefix"), rd.Param("key-marker"), rd.Param("upload-id-marker"), maxUploads, rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	writeXML(w, res)
+}
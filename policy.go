@@ -0,0 +1,223 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+// bucketActions are the policy actions of the operations on buckets.
+var bucketActions = map[S3METHOD]string{
+	GETBUCKET:                "s3:ListBucket",
+	GETBUCKET_V2:             "s3:ListBucket",
+	HEADBUCKET:               "s3:ListBucket",
+	GETBUCKET_OBJECTVERSION:  "s3:ListBucketVersions",
+	GETBUCKET_UPLOADS:        "s3:ListBucketMultipartUploads",
+	GETBUCKET_ACL:            "s3:GetBucketAcl",
+	PUTBUCKET_ACL:            "s3:PutBucketAcl",
+	GETBUCKET_CORS:           "s3:GetBucketCORS",
+	PUTBUCKET_CORS:           "s3:PutBucketCORS",
+	DELETEBUCKET_CORS:        "s3:PutBucketCORS",
+	GETBUCKET_LIFECYCLE:      "s3:GetLifecycleConfiguration",
+	PUTBUCKET_LIFECYCLE:      "s3:PutLifecycleConfiguration",
+	DELETEBUCKET_LIFTCYCLE:   "s3:PutLifecycleConfiguration",
+	GETBUCKET_POLICY:         "s3:GetBucketPolicy",
+	PUTBUCKET_POLICY:         "s3:PutBucketPolicy",
+	DELETEBUCKET_POLICY:      "s3:DeleteBucketPolicy",
+	GETBUCKET_LOCATION:       "s3:GetBucketLocation",
+	GETBUCKET_LOGGING:        "s3:GetBucketLogging",
+	PUTBUCKET_LOGGING:        "s3:PutBucketLogging",
+	GETBUCKET_NOTIFICATION:   "s3:GetBucketNotification",
+	PUTBUCKET_NOTIFICATION:   "s3:PutBucketNotification",
+	GETBUCKET_REPLICATION:    "s3:GetReplicationConfiguration",
+	PUTBUCKET_REPLICATION:    "s3:PutReplicationConfiguration",
+	DELETEBUCKET_REPLICATION: "s3:DeleteReplicationConfiguration",
+	GETBUCKET_TAGGING:        "s3:GetBucketTagging",
+	PUTBUCKET_TAGGING:        "s3:PutBucketTagging",
+	DELETEBUCKET_TAGGING:     "s3:PutBucketTagging",
+	GETBUCKET_REQUESTPAYMENT: "s3:GetBucketRequestPayment",
+	PUTBUCKET_REQUESTPAYMENT: "s3:PutBucketRequestPayment",
+	GETBUCKET_VERSIONING:     "s3:GetBucketVersioning",
+	PUTBUCKET_VERSIONING:     "s3:PutBucketVersioning",
+	GETBUCKET_WEBSITE:        "s3:GetBucketWebsite",
+	PUTBUCKET_WEBSITE:        "s3:PutBucketWebsite",
+	DELETEBUCKET_WEBSITE:     "s3:DeleteBucketWebsite",
+	DELETEBUCKET:             "s3:DeleteBucket",
+}
+
+// objectActions are the policy actions of the operations on objects. Actions
+// on a specific version are named by versionActions.
+var objectActions = map[S3METHOD]string{
+	GETOBJECT:                 "s3:GetObject",
+	HEADOBJECT:                "s3:GetObject",
+	GETOBJECT_TORRENT:         "s3:GetObjectTorrent",
+	GETOBJECT_ACL:             "s3:GetObjectAcl",
+	PUTOBJECT_ACL:             "s3:PutObjectAcl",
+	GETOBJECT_TAGGING:         "s3:GetObjectTagging",
+	PUTOBJECT_TAGGING:         "s3:PutObjectTagging",
+	DELETEOBJECT_TAGGING:      "s3:DeleteObjectTagging",
+	DELETEOBJECT:              "s3:DeleteObject",
+	PUTOBJECT:                 "s3:PutObject",
+	PUTOBJECT_COPY:            "s3:PutObject",
+	POSTOBJECT:                "s3:PutObject",
+	POSTOBJECT_UPLOADS:        "s3:PutObject",
+	PUTOBJECT_PART:            "s3:PutObject",
+	PUTOBJECT_PART_COPY:       "s3:PutObject",
+	POSTOBJECT_COMPLETEUPLOAD: "s3:PutObject",
+	POSTOBJECT_RESTORE:        "s3:RestoreObject",
+	DELETEOBJECT_UPLOAD:       "s3:AbortMultipartUpload",
+	GETOBJECT_PARTS:           "s3:ListMultipartUploadParts",
+}
+
+var versionActions = map[string]string{
+	"s3:GetObject":           "s3:GetObjectVersion",
+	"s3:GetObjectAcl":        "s3:GetObjectVersionAcl",
+	"s3:PutObjectAcl":        "s3:PutObjectVersionAcl",
+	"s3:GetObjectTagging":    "s3:GetObjectVersionTagging",
+	"s3:PutObjectTagging":    "s3:PutObjectVersionTagging",
+	"s3:DeleteObjectTagging": "s3:DeleteObjectVersionTagging",
+	"s3:DeleteObject":        "s3:DeleteObjectVersion",
+}
+
+// policyRequest returns the request as seen by the bucket policy.
+func policyRequest(r *http.Request, rd *S3Request) *common.PolicyRequest {
+	pr := &common.PolicyRequest{
+		Principal:  rd.principal,
+		Resource:   common.BucketResource(rd.bucket, ""),
+		Conditions: conditionKeys(r, rd),
+	}
+
+	if action, ok := objectActions[rd.s3method]; ok {
+		pr.Resource = common.BucketResource(rd.bucket, rd.object)
+		pr.Action = action
+
+		if versioned, ok := versionActions[action]; ok && rd.Param("versionId") != "" {
+			pr.Action = versioned
+		}
+	} else {
+		pr.Action = bucketActions[rd.s3method]
+	}
+
+	return pr
+}
+
+// conditionKeys returns the values of the policy condition keys of a request.
+func conditionKeys(r *http.Request, rd *S3Request) map[string]string {
+	keys := map[string]string{
+		"aws:securetransport": strconv.FormatBool(r.TLS != nil),
+	}
+
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		keys["aws:sourceip"] = ip
+	}
+
+	if ua := r.UserAgent(); ua != "" {
+		keys["aws:useragent"] = ua
+	}
+
+	if referer := r.Referer(); referer != "" {
+		keys["aws:referer"] = referer
+	}
+
+	if !rd.principal.IsAnonymous() {
+		keys["aws:userid"] = rd.principal.ID
+	}
+
+	for _, param := range []string{"prefix", "delimiter", "max-keys"} {
+		if rd.HasParam(param) {
+			keys["s3:"+param] = rd.Param(param)
+		}
+	}
+
+	if rd.Param("versionId") != "" {
+		keys["s3:versionid"] = rd.Param("versionId")
+	}
+
+	if acl := r.Header.Get("x-amz-acl"); acl != "" {
+		keys["s3:x-amz-acl"] = acl
+	}
+
+	return keys
+}
+
+// bucketPolicy returns the policy of the addressed bucket, or nil if it has
+// none.
+func bucketPolicy(rd *S3Request) (*common.Policy, *common.Error) {
+	doc, awserr := backend.GetBucketPolicy(rd.bucket, rd.principal)
+
+	if awserr == &common.ErrNoSuchBucketPolicy {
+		return nil, nil
+	} else if awserr != nil {
+		return nil, awserr
+	}
+
+	return common.ParsePolicy(doc, rd.bucket)
+}
+
+// mayList reports whether the principal may list the bucket, in which case
+// it may learn that an object does not exist.
+func mayList(r *http.Request, rd *S3Request, policy *common.Policy) bool {
+	pr := policyRequest(r, rd)
+	pr.Action = "s3:ListBucket"
+	pr.Resource = common.BucketResource(rd.bucket, "")
+
+	switch policy.Evaluate(pr) {
+	case common.Allowed:
+		return true
+	case common.Denied:
+		return false
+	}
+
+	return rd.bucketACL.Allows(rd.principal, common.PermissionRead)
+}
+
+func getBucketPolicyHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("getBucketPolicyHandler", rd)
+	doc, awserr := backend.GetBucketPolicy(rd.bucket, rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(doc)
+}
+
+func putBucketPolicyHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("putBucketPolicyHandler", rd)
+	doc, err := ioutil.ReadAll(io.LimitReader(r.Body, common.MaxPolicySize+1))
+
+	if err != nil {
+		writeError(w, common.BodyError(err))
+		return
+	}
+
+	if _, awserr := common.ParsePolicy(doc, rd.bucket); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	if awserr := backend.PutBucketPolicy(rd.bucket, doc, rd.principal); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func deleteBucketPolicyHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("deleteBucketPolicyHandler", rd)
+
+	if awserr := backend.DeleteBucketPolicy(rd.bucket, rd.principal); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+// maxPostFormSize is the maximum size of the form fields preceding the file
+// of a POST upload.
+const maxPostFormSize = 20 * 1024
+
+// readPostForm reads the fields of a POST upload up to the file, keyed by
+// lowercase name. Like S3, it ignores the fields following the file, which is
+// returned unread.
+func readPostForm(r *http.Request) (map[string]string, *multipart.Part, *common.Error) {
+	mr, err := r.MultipartReader()
+
+	if err != nil {
+		return nil, nil, &common.ErrRequestIsNotMultiPartContent
+	}
+
+	fields := map[string]string{}
+	size := int64(0)
+
+	for {
+		p, err := mr.NextPart()
+
+		if err == io.EOF {
+			return nil, nil, &common.ErrIncorrectNumberOfFilesInPostRequest
+		} else if err != nil {
+			return nil, nil, &common.ErrMalformedPOSTRequest
+		}
+
+		name := strings.ToLower(p.FormName())
+
+		if name == "file" {
+			return fields, p, nil
+		}
+
+		value, err := ioutil.ReadAll(io.LimitReader(p, maxPostFormSize-size+1))
+
+		if err != nil {
+			return nil, nil, &common.ErrMalformedPOSTRequest
+		}
+
+		if size += int64(len(value)); size > maxPostFormSize {
+			return nil, nil, &common.ErrMaxPostPreDataLengthExceededError
+		}
+
+		fields[name] = string(value)
+	}
+}
+
+// formRequest returns a request carrying the fields of the POST form of r as
+// headers, so that it is authorized and its metadata and grants are read like
+// those of a PUT. The canned ACL is the acl field. The connection, the user
+// agent and the referer are those of r, they can't be set by the form.
+func formRequest(r *http.Request, fields map[string]string) *http.Request {
+	h := http.Header{}
+
+	for name, value := range fields {
+		if name == "acl" {
+			name = "x-amz-acl"
+		}
+
+		h.Set(name, value)
+	}
+
+	for _, name := range []string{"User-Agent", "Referer"} {
+		h[name] = r.Header[name]
+	}
+
+	return &http.Request{Header: h, RemoteAddr: r.RemoteAddr, TLS: r.TLS}
+}
+
+// formTags returns the tags of the Tagging document in the tagging field.
+func formTags(fields map[string]string) ([]common.Tag, *common.Error) {
+	doc, ok := fields["tagging"]
+
+	if !ok {
+		return nil, nil
+	}
+
+	tagging := &common.Tagging{}
+
+	if err := xml.Unmarshal([]byte(doc), tagging); err != nil {
+		return nil, &common.ErrMalformedXML
+	}
+
+	return tagging.TagSet.Tags, nil
+}
+
+// objectURL returns the URL of the object key written by the request rd.
+func objectURL(r *http.Request, rd *S3Request, key string) string {
+	u := &url.URL{Scheme: "http", Host: r.Host, Path: "/" + rd.bucket + "/" + key}
+
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+
+	if rd.virtualHost {
+		u.Path = "/" + key
+	}
+
+	return u.String()
+}
+
+// postObjectHandler handles browser-based uploads. The key, the metadata and
+// the authentication are form fields, so the request is only authorized once
+// the fields preceding the file have been read. A ${filename} in the key is
+// replaced by the name of the uploaded file, a content-md5 field is compared
+// with the file.
+func postObjectHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("postObjectHandler", rd)
+	fields, file, awserr := readPostForm(r)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	key := strings.Replace(fields["key"], "${filename}", file.FileName(), -1)
+
+	if key == "" {
+		writeError(w, &common.ErrPostMissingKey)
+		return
+	} else if len(key) > maxKeyLength {
+		writeError(w, &common.ErrKeyTooLong)
+		return
+	}
+
+	fields["key"] = key
+	fields["bucket"] = rd.bucket
+
+	if verifier != nil {
+		account, awserr := verifier.VerifyPost(fields)
+
+		if awserr != nil {
+			writeError(w, awserr)
+			return
+		}
+
+		if account != nil {
+			rd.principal = account.Principal()
+		}
+	}
+
+	var body io.Reader = file
+
+	if encoded, ok := fields["content-md5"]; ok {
+		sum, err := base64.StdEncoding.DecodeString(encoded)
+
+		if err != nil || len(sum) != md5.Size {
+			writeError(w, &common.ErrInvalidDigest)
+			return
+		}
+
+		body = common.NewDigestReader(file, md5.New(), sum, &common.ErrBadDigest)
+	}
+
+	if encoded, ok := fields["policy"]; ok {
+		policy, awserr := common.ParsePostPolicy(encoded)
+
+		if awserr != nil {
+			writeError(w, awserr)
+			return
+		}
+
+		if awserr := policy.Check(fields, time.Now()); awserr != nil {
+			writeError(w, awserr)
+			return
+		}
+
+		if min, max, ok := policy.LengthRange(); ok {
+			body = common.NewLengthReader(body, min, max)
+		}
+	}
+
+	rd.object = key
+	fr := formRequest(r, fields)
+
+	if awserr := authorize(fr, rd); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	frd := *rd
+	frd.ContentType = fr.Header.Get("Content-Type")
+	frd.contentEncoding = fr.Header.Get("Content-Encoding")
+
+	info, awserr := objectMetadata(fr, &frd)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	grants, awserr := objectGrants(fr, rd)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	tags, awserr := formTags(fields)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	info, awserr = backend.PostObject(rd.bucket, key, body, -1, info, grants, tags, rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	location := objectURL(r, rd, key)
+	w.Header().Set("ETag", info.ETag)
+	w.Header().Set("Location", location)
+	setVersionHeader(w, "x-amz-version-id", rd, info.VersionId)
+
+	redirect := fields["success_action_redirect"]
+
+	if redirect == "" {
+		redirect = fields["redirect"]
+	}
+
+	if u, err := url.Parse(redirect); redirect != "" && err == nil && u.IsAbs() {
+		q := u.Query()
+		q.Set("bucket", rd.bucket)
+		q.Set("key", key)
+		q.Set("etag", info.ETag)
+		u.RawQuery = q.Encode()
+		http.Redirect(w, r, u.String(), http.StatusSeeOther)
+		return
+	}
+
+	switch fields["success_action_status"] {
+	case "200":
+		w.WriteHeader(http.StatusOK)
+	case "201":
+		// writeXML can't set the status, so the headers are sent first.
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusCreated)
+		writeXML(w, &common.PostResponse{Location: location, Bucket: rd.bucket, Key: key, ETag: info.ETag})
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
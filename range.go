@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+// objectRange returns the range of the object selected by the partNumber
+// parameter or the Range header of a GET or HEAD request, or nil for the
+// whole object.
+func objectRange(r *http.Request, rd *S3Request, info *common.ObjectInfo) (*common.ByteRange, *common.Error) {
+	if !rd.HasParam("partNumber") {
+		return common.ParseRange(r.Header.Get("Range"), info.Size)
+	}
+
+	if r.Header.Get("Range") != "" {
+		return nil, &common.ErrRangeWithPartNumber
+	}
+
+	n, awserr := rd.IntParam("partNumber", 0)
+
+	if awserr != nil {
+		return nil, &common.ErrInvalidArgument
+	}
+
+	return info.PartRange(n)
+}
+
+// writeRangeHeaders writes the headers describing the range br of the object
+// read by a GET or HEAD request and the status code, 206 for a range and 200
+// for the whole object. Reads of a part also return the number of parts.
+func writeRangeHeaders(w http.ResponseWriter, rd *S3Request, info *common.ObjectInfo, br *common.ByteRange) {
+	if rd.HasParam("partNumber") && len(info.PartSizes) > 0 {
+		w.Header().Set("x-amz-mp-parts-count", strconv.Itoa(len(info.PartSizes)))
+	}
+
+	if br == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.FormatInt(br.Length(), 10))
+	w.Header().Set("Content-Range", br.ContentRange(info.Size))
+	w.WriteHeader(http.StatusPartialContent)
+}
+
+// writeRangeError writes the error of an unsatisfiable range, along with the
+// size of the object.
+func writeRangeError(w http.ResponseWriter, info *common.ObjectInfo, awserr *common.Error) {
+	if awserr.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+	}
+
+	writeError(w, awserr)
+}
@@ -0,0 +1,65 @@
+package s3disk
+
+import (
+	"github.com/0x434D53/s3server/common"
+)
+
+func (d *Disk) GetBucketACL(bucketName string, principal *common.Principal) (*common.AccessControlPolicy, *common.Error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	b, awserr := d.readBucket(bucketName)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	return common.NewAccessControlPolicy(b.Owner, b.Grants), nil
+}
+
+func (d *Disk) PutBucketACL(bucketName string, grants []common.Grant, principal *common.Principal) *common.Error {
+	d.Lock()
+	defer d.Unlock()
+
+	b, awserr := d.readBucket(bucketName)
+
+	if awserr != nil {
+		return awserr
+	}
+
+	b.Grants = grants
+
+	if err := writeJSON(d.getBucketRecordPath(bucketName), b); err != nil {
+		return internalError("PutBucketACL", err)
+	}
+
+	return nil
+}
+
+func (d *Disk) GetObjectACL(bucketName string, objectName string, versionID string, principal *common.Principal) (*common.AccessControlPolicy, *common.Error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	v, awserr := d.readVersion(bucketName, objectName, versionID)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	if awserr := deleteMarkerError(v, versionID); awserr != nil {
+		return nil, awserr
+	}
+
+	return common.NewAccessControlPolicy(v.Owner, v.Grants), nil
+}
+
+func (d *Disk) PutObjectACL(bucketName string, objectName string, versionID string, grants []common.Grant, principal *common.Principal) *common.Error {
+	d.Lock()
+	defer d.Unlock()
+
+	_, awserr := d.updateVersion(bucketName, objectName, versionID, func(v *version) {
+		v.Grants = grants
+	})
+
+	return awserr
+}
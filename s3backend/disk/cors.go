@@ -0,0 +1,45 @@
+package s3disk
+
+import (
+	"github.com/0x434D53/s3server/common"
+)
+
+func (d *Disk) GetBucketCORS(bucketName string, principal *common.Principal) (*common.CORSConfiguration, *common.Error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	b, awserr := d.readBucket(bucketName)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	if b.CORS == nil {
+		return nil, &common.ErrNoSuchCORSConfiguration
+	}
+
+	return b.CORS, nil
+}
+
+func (d *Disk) PutBucketCORS(bucketName string, cors *common.CORSConfiguration, principal *common.Principal) *common.Error {
+	d.Lock()
+	defer d.Unlock()
+
+	b, awserr := d.readBucket(bucketName)
+
+	if awserr != nil {
+		return awserr
+	}
+
+	b.CORS = cors
+
+	if err := writeJSON(d.getBucketRecordPath(bucketName), b); err != nil {
+		return internalError("PutBucketCORS", err)
+	}
+
+	return nil
+}
+
+func (d *Disk) DeleteBucketCORS(bucketName string, principal *common.Principal) *common.Error {
+	return d.PutBucketCORS(bucketName, nil, principal)
+}
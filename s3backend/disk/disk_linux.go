@@ -11,5 +11,5 @@ import (
 func GetCTime(fi os.FileInfo) time.Time {
 	stat := fi.Sys().(*syscall.Stat_t)
 
-	return time.Unix(stat.Ctime.Unix())
+	return time.Unix(stat.Ctim.Unix())
 }
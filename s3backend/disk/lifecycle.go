@@ -0,0 +1,180 @@
+package s3disk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+func (d *Disk) GetBucketLifecycle(bucketName string, principal *common.Principal) (*common.LifecycleConfiguration, *common.Error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	b, awserr := d.readBucket(bucketName)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	if b.Lifecycle == nil {
+		return nil, &common.ErrNoSuchLifecycleConfiguration
+	}
+
+	return b.Lifecycle, nil
+}
+
+func (d *Disk) PutBucketLifecycle(bucketName string, lifecycle *common.LifecycleConfiguration, principal *common.Principal) *common.Error {
+	d.Lock()
+	defer d.Unlock()
+
+	b, awserr := d.readBucket(bucketName)
+
+	if awserr != nil {
+		return awserr
+	}
+
+	b.Lifecycle = lifecycle
+
+	if err := writeJSON(d.getBucketRecordPath(bucketName), b); err != nil {
+		return internalError("PutBucketLifecycle", err)
+	}
+
+	return nil
+}
+
+func (d *Disk) DeleteBucketLifecycle(bucketName string, principal *common.Principal) *common.Error {
+	return d.PutBucketLifecycle(bucketName, nil, principal)
+}
+
+func (d *Disk) ObjectExpiration(bucketName string, objectName string, versionID string, principal *common.Principal) (time.Time, string, *common.Error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	b, awserr := d.readBucket(bucketName)
+
+	if awserr != nil || b.Lifecycle == nil {
+		return time.Time{}, "", awserr
+	}
+
+	o, awserr := d.readObject(bucketName, objectName)
+
+	if awserr == &common.ErrNoSuchKey {
+		return time.Time{}, "", nil
+	} else if awserr != nil {
+		return time.Time{}, "", awserr
+	}
+
+	v := &o.Versions[0]
+
+	if v.DeleteMarker || (versionID != "" && versionID != v.VersionId) {
+		return time.Time{}, "", nil
+	}
+
+	expires, id := b.Lifecycle.Expiration(objectName, common.TagMap(v.Tags), v.LastModified)
+
+	return expires, id, nil
+}
+
+func (d *Disk) ApplyLifecycle(now time.Time) *common.Error {
+	d.Lock()
+	defer d.Unlock()
+
+	fis, err := ioutil.ReadDir(d.BasePath)
+
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return internalError("ApplyLifecycle", err)
+	}
+
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			continue
+		}
+
+		b, awserr := d.readBucket(fi.Name())
+
+		if awserr != nil {
+			return awserr
+		}
+
+		if b.Lifecycle == nil {
+			continue
+		}
+
+		if awserr := d.expireObjects(fi.Name(), b, now); awserr != nil {
+			return awserr
+		}
+
+		if awserr := d.abortUploads(fi.Name(), b.Lifecycle, now); awserr != nil {
+			return awserr
+		}
+	}
+
+	return nil
+}
+
+// expireObjects has to be called with the lock held.
+func (d *Disk) expireObjects(bucketName string, b *bucket, now time.Time) *common.Error {
+	objects, awserr := d.readObjects(bucketName)
+
+	if awserr != nil {
+		return awserr
+	}
+
+	for _, o := range objects {
+		versions := make([]common.LifecycleVersion, len(o.Versions))
+
+		for i, v := range o.Versions {
+			versions[i] = common.LifecycleVersion{VersionId: v.VersionId, DeleteMarker: v.DeleteMarker, LastModified: v.LastModified, Tags: common.TagMap(v.Tags)}
+		}
+
+		expired, remove := b.Lifecycle.Expire(o.Key, versions, now)
+
+		for _, id := range remove {
+			if _, awserr := d.removeVersion(bucketName, o.Key, id); awserr != nil {
+				return awserr
+			}
+		}
+
+		if expired {
+			if _, awserr := d.deleteCurrent(bucketName, o.Key, b, b.Owner, now); awserr != nil {
+				return awserr
+			}
+		}
+	}
+
+	return nil
+}
+
+// abortUploads has to be called with the lock held.
+func (d *Disk) abortUploads(bucketName string, lifecycle *common.LifecycleConfiguration, now time.Time) *common.Error {
+	fis, err := ioutil.ReadDir(filepath.Join(d.getBucketPath(bucketName), "uploads"))
+
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return internalError("abortUploads", err)
+	}
+
+	for _, fi := range fis {
+		u := upload{}
+
+		if err := readJSON(filepath.Join(d.getUploadPath(bucketName, fi.Name()), "upload.json"), &u); err != nil {
+			return internalError("abortUploads", err)
+		}
+
+		if !lifecycle.AbortsUpload(u.Key, u.Initiated, now) {
+			continue
+		}
+
+		if err := os.RemoveAll(d.getUploadPath(bucketName, fi.Name())); err != nil {
+			return internalError("abortUploads", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,372 @@
+package s3disk
+
+import (
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+// upload is the record of a multipart upload, stored as upload.json in the
+// directory of the upload next to the contents of the parts.
+type upload struct {
+	Key       string
+	Info      common.ObjectInfo // metadata of the object
+	Initiator common.Owner
+	Grants    []common.Grant
+	Tags      []common.Tag `json:",omitempty"`
+	Initiated time.Time
+	Parts     map[int]part
+}
+
+type part struct {
+	common.Part
+	Data string // name of the contents file in the upload directory
+}
+
+func (u *upload) commonParts() map[int]common.Part {
+	parts := make(map[int]common.Part, len(u.Parts))
+
+	for n, p := range u.Parts {
+		parts[n] = p.Part
+	}
+
+	return parts
+}
+
+func (d *Disk) getUploadPath(bucketName string, uploadID string) string {
+	return filepath.Join(d.BasePath, bucketName, "uploads", uploadID)
+}
+
+// readUpload has to be called with the lock held. Upload IDs are used as
+// directory names, so anything which is not an ID generated by
+// common.NewUploadID is rejected.
+func (d *Disk) readUpload(bucketName string, objectName string, uploadID string) (*upload, *common.Error) {
+	if !existsBool(d.getBucketPath(bucketName)) {
+		return nil, &common.ErrNoSuchBucket
+	}
+
+	if _, err := hex.DecodeString(uploadID); err != nil || uploadID == "" {
+		return nil, &common.ErrNoSuchUpload
+	}
+
+	u := &upload{}
+	err := readJSON(filepath.Join(d.getUploadPath(bucketName, uploadID), "upload.json"), u)
+
+	if os.IsNotExist(err) {
+		return nil, &common.ErrNoSuchUpload
+	} else if err != nil {
+		return nil, internalError("readUpload", err)
+	}
+
+	if u.Key != objectName {
+		return nil, &common.ErrNoSuchUpload
+	}
+
+	return u, nil
+}
+
+func (d *Disk) CreateMultipartUpload(bucketName string, objectName string, info *common.ObjectInfo, grants []common.Grant, tags []common.Tag, principal *common.Principal) (string, *common.Error) {
+	if awserr := common.ValidateObjectTags(tags); awserr != nil {
+		return "", awserr
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if !existsBool(d.getBucketPath(bucketName)) {
+		return "", &common.ErrNoSuchBucket
+	}
+
+	id := common.NewUploadID()
+	path := d.getUploadPath(bucketName, id)
+
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return "", internalError("CreateMultipartUpload", err)
+	}
+
+	u := &upload{
+		Key:       objectName,
+		Info:      info.Metadata(),
+		Initiator: principal.Owner(),
+		Grants:    grants,
+		Tags:      tags,
+		Initiated: time.Now(),
+		Parts:     make(map[int]part),
+	}
+
+	if err := writeJSON(filepath.Join(path, "upload.json"), u); err != nil {
+		os.RemoveAll(path)
+		return "", internalError("CreateMultipartUpload", err)
+	}
+
+	return id, nil
+}
+
+func (d *Disk) UploadPart(bucketName string, objectName string, uploadID string, partNumber int, r io.Reader, size int64, principal *common.Principal) (string, *common.Error) {
+	p, awserr := d.uploadPart(bucketName, objectName, uploadID, partNumber, r, size)
+
+	if awserr != nil {
+		return "", awserr
+	}
+
+	return p.ETag, nil
+}
+
+// UploadPartCopy streams the source from its contents file into the part.
+func (d *Disk) UploadPartCopy(bucketName string, objectName string, versionID string, targetBucket string, targetObject string, uploadID string, partNumber int, br *common.ByteRange, cond *common.Conditions, principal *common.Principal) (*common.Part, string, *common.Error) {
+	data, info, awserr := d.GetObject(bucketName, objectName, versionID, principal)
+
+	if awserr != nil {
+		return nil, "", awserr
+	}
+
+	defer data.Close()
+
+	if awserr := cond.CheckCopySource(info); awserr != nil {
+		return nil, "", awserr
+	}
+
+	var r io.Reader = data
+	size := info.Size
+
+	if br != nil {
+		if awserr := br.CheckSize(info.Size); awserr != nil {
+			return nil, "", awserr
+		}
+
+		if _, err := data.Seek(br.First, io.SeekStart); err != nil {
+			return nil, "", internalError("UploadPartCopy", err)
+		}
+
+		size = br.Length()
+		r = io.LimitReader(data, size)
+	}
+
+	p, awserr := d.uploadPart(targetBucket, targetObject, uploadID, partNumber, r, size)
+
+	if awserr != nil {
+		return nil, "", awserr
+	}
+
+	return p, info.VersionId, nil
+}
+
+// uploadPart receives the contents of part partNumber and records it in the
+// upload, replacing any earlier one.
+func (d *Disk) uploadPart(bucketName string, objectName string, uploadID string, partNumber int, r io.Reader, size int64) (*common.Part, *common.Error) {
+	tmp, n, sum, awserr := d.receive(bucketName, r, size)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	u, awserr := d.readUpload(bucketName, objectName, uploadID)
+
+	if awserr != nil {
+		os.Remove(tmp)
+		return nil, awserr
+	}
+
+	path := d.getUploadPath(bucketName, uploadID)
+	p := part{
+		Part: common.Part{PartNumber: partNumber, LastModified: common.FormatTime(time.Now()), ETag: common.ETag(sum), Size: n},
+		Data: filepath.Base(tmp),
+	}
+
+	if err := os.Rename(tmp, filepath.Join(path, p.Data)); err != nil {
+		os.Remove(tmp)
+		return nil, internalError("UploadPart", err)
+	}
+
+	old, replaced := u.Parts[partNumber]
+	u.Parts[partNumber] = p
+
+	if err := writeJSON(filepath.Join(path, "upload.json"), u); err != nil {
+		os.Remove(filepath.Join(path, p.Data))
+		return nil, internalError("UploadPart", err)
+	}
+
+	if replaced {
+		os.Remove(filepath.Join(path, old.Data))
+	}
+
+	return &p.Part, nil
+}
+
+// openParts checks the requested parts and the conditions and opens the
+// contents files of the parts.
+func (d *Disk) openParts(bucketName string, objectName string, uploadID string, parts []common.CompletedPart, cond *common.Conditions) (*upload, []common.Part, []*os.File, string, *common.Error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	u, awserr := d.readUpload(bucketName, objectName, uploadID)
+
+	if awserr != nil {
+		return nil, nil, nil, "", awserr
+	}
+
+	ps, etag, awserr := common.CompleteParts(parts, u.commonParts())
+
+	if awserr != nil {
+		return nil, nil, nil, "", awserr
+	}
+
+	if awserr := d.checkWrite(bucketName, objectName, cond); awserr != nil {
+		return nil, nil, nil, "", awserr
+	}
+
+	files := make([]*os.File, 0, len(ps))
+
+	for _, p := range ps {
+		f, err := os.Open(filepath.Join(d.getUploadPath(bucketName, uploadID), u.Parts[p.PartNumber].Data))
+
+		if err != nil {
+			closeAll(files)
+			return nil, nil, nil, "", internalError("openParts", err)
+		}
+
+		files = append(files, f)
+	}
+
+	return u, ps, files, etag, nil
+}
+
+func closeAll(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// CompleteMultipartUpload concatenates the parts without holding the lock.
+// The conditions are checked before and after, like in PutObject.
+func (d *Disk) CompleteMultipartUpload(bucketName string, objectName string, uploadID string, parts []common.CompletedPart, cond *common.Conditions, principal *common.Principal) (string, string, *common.Error) {
+	u, ps, files, etag, awserr := d.openParts(bucketName, objectName, uploadID, parts, cond)
+
+	if awserr != nil {
+		return "", "", awserr
+	}
+
+	readers := make([]io.Reader, len(files))
+
+	for i, f := range files {
+		readers[i] = f
+	}
+
+	tmp, n, _, awserr := d.receive(bucketName, io.MultiReader(readers...), -1)
+	closeAll(files)
+
+	if awserr != nil {
+		return "", "", awserr
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if _, awserr := d.readUpload(bucketName, objectName, uploadID); awserr != nil {
+		os.Remove(tmp)
+		return "", "", awserr
+	}
+
+	if d.checkWrite(bucketName, objectName, cond) != nil {
+		os.Remove(tmp)
+		return "", "", &common.ErrConditionalRequestConflict
+	}
+
+	v := version{ObjectInfo: u.Info.NewVersion(objectName, n, etag, u.Initiator), Grants: u.Grants, Tags: u.Tags}
+	v.PartSizes = common.PartSizes(ps)
+	id, awserr := d.commit(bucketName, objectName, tmp, v)
+
+	if awserr != nil {
+		return "", "", awserr
+	}
+
+	os.RemoveAll(d.getUploadPath(bucketName, uploadID))
+
+	return etag, id, nil
+}
+
+func (d *Disk) AbortMultipartUpload(bucketName string, objectName string, uploadID string, principal *common.Principal) *common.Error {
+	d.Lock()
+	defer d.Unlock()
+
+	if _, awserr := d.readUpload(bucketName, objectName, uploadID); awserr != nil {
+		return awserr
+	}
+
+	if err := os.RemoveAll(d.getUploadPath(bucketName, uploadID)); err != nil {
+		return internalError("AbortMultipartUpload", err)
+	}
+
+	return nil
+}
+
+func (d *Disk) ListParts(bucketName string, objectName string, uploadID string, partNumberMarker int, maxParts int, principal *common.Principal) (*common.ListPartsResult, *common.Error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	u, awserr := d.readUpload(bucketName, objectName, uploadID)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	res := &common.ListPartsResult{
+		Bucket:       bucketName,
+		Key:          objectName,
+		UploadId:     uploadID,
+		Initiator:    u.Initiator,
+		Owner:        u.Initiator,
+		StorageClass: u.Info.StorageClass,
+	}
+
+	common.PageParts(res, u.commonParts(), partNumberMarker, maxParts)
+
+	return res, nil
+}
+
+func (d *Disk) ListMultipartUploads(bucketName string, prefix string, keyMarker string, uploadIDMarker string, maxUploads int, principal *common.Principal) (*common.ListMultipartUploadsResult, *common.Error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	if !existsBool(d.getBucketPath(bucketName)) {
+		return nil, &common.ErrNoSuchBucket
+	}
+
+	fis, err := ioutil.ReadDir(filepath.Join(d.getBucketPath(bucketName), "uploads"))
+
+	if err != nil && !os.IsNotExist(err) {
+		return nil, internalError("ListMultipartUploads", err)
+	}
+
+	uploads := make([]common.Upload, 0, len(fis))
+
+	for _, fi := range fis {
+		u := upload{}
+
+		if err := readJSON(filepath.Join(d.getUploadPath(bucketName, fi.Name()), "upload.json"), &u); err != nil {
+			return nil, internalError("ListMultipartUploads", err)
+		}
+
+		uploads = append(uploads, common.Upload{
+			Key:          u.Key,
+			UploadId:     fi.Name(),
+			Initiator:    u.Initiator,
+			Owner:        u.Initiator,
+			StorageClass: u.Info.StorageClass,
+			Initiated:    common.FormatTime(u.Initiated),
+		})
+	}
+
+	res := &common.ListMultipartUploadsResult{Bucket: bucketName}
+	common.PageUploads(res, uploads, prefix, keyMarker, uploadIDMarker, maxUploads)
+
+	return res, nil
+}
@@ -0,0 +1,49 @@
+package s3disk
+
+import (
+	"github.com/0x434D53/s3server/common"
+)
+
+func (d *Disk) GetBucketPolicy(bucketName string, principal *common.Principal) ([]byte, *common.Error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	b, awserr := d.readBucket(bucketName)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	if b.Policy == "" {
+		return nil, &common.ErrNoSuchBucketPolicy
+	}
+
+	return []byte(b.Policy), nil
+}
+
+func (d *Disk) PutBucketPolicy(bucketName string, policy []byte, principal *common.Principal) *common.Error {
+	return d.setBucketPolicy(bucketName, string(policy))
+}
+
+func (d *Disk) DeleteBucketPolicy(bucketName string, principal *common.Principal) *common.Error {
+	return d.setBucketPolicy(bucketName, "")
+}
+
+func (d *Disk) setBucketPolicy(bucketName string, policy string) *common.Error {
+	d.Lock()
+	defer d.Unlock()
+
+	b, awserr := d.readBucket(bucketName)
+
+	if awserr != nil {
+		return awserr
+	}
+
+	b.Policy = policy
+
+	if err := writeJSON(d.getBucketRecordPath(bucketName), b); err != nil {
+		return internalError("setBucketPolicy", err)
+	}
+
+	return nil
+}
@@ -1,9 +1,16 @@
 package s3disk
 
 import (
-	"fmt"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/0x434D53/s3server/common"
@@ -14,21 +21,73 @@ type Options struct {
 	CacheSize uint64
 }
 
+// Disk stores every bucket as a directory below BasePath. Inside a bucket
+// directory the object records (JSON) live in objects/, the contents in data/,
+// multipart uploads in uploads/ and transfers in progress in tmp/. Contents are
+// streamed into tmp/ without holding the lock and moved into place when the
+// transfer is complete.
 type Disk struct {
 	sync.RWMutex
 	Options
 }
 
-func (d *Disk) getBucketPath(bucketName string, auth string) string {
-	return filepath.Join(d.BasePath, auth, bucketName)
+// object is the record of a key as stored in the objects directory. It holds
+// all versions of the key, the current one first.
+type object struct {
+	Key      string
+	Versions []version
 }
 
-func (d *Disk) getFilePath(bucketName string, objectName string, auth string) string {
-	path := filepath.Join(d.BasePath, auth, bucketName, objectName)
+// version is a version of an object. Delete markers have no contents.
+type version struct {
+	common.ObjectInfo
+	Grants []common.Grant // nil for the private canned ACL
+	Tags   []common.Tag   `json:",omitempty"`
+	Data   string         // name of the contents file in the data directory
+}
+
+// info returns a copy of the ObjectInfo of the version.
+func (v *version) info() *common.ObjectInfo {
+	info := v.ObjectInfo
+
+	return &info
+}
+
+// bucket is the record of a bucket, stored as bucket.json in its directory.
+type bucket struct {
+	Owner      common.Owner
+	Grants     []common.Grant
+	Versioning string
+	Policy     string                         `json:",omitempty"`
+	CORS       *common.CORSConfiguration      `json:",omitempty"`
+	Lifecycle  *common.LifecycleConfiguration `json:",omitempty"`
+	Tags       []common.Tag                   `json:",omitempty"`
+}
+
+var _ common.S3Backend = &Disk{}
+
+func NewS3Backend(opts Options) common.S3Backend {
+	return &Disk{Options: opts}
+}
+
+func (d *Disk) getBucketPath(bucketName string) string {
+	return filepath.Join(d.BasePath, bucketName)
+}
+
+// getFilePath maps an object key to the file holding its record. Keys may
+// contain slashes, dots and arbitrary characters, so the file is named after
+// the hash of the key instead of the key itself.
+func (d *Disk) getFilePath(bucketName string, objectName string) string {
+	sum := sha1.Sum([]byte(objectName))
+	path := filepath.Join(d.BasePath, bucketName, "objects", hex.EncodeToString(sum[:])+".json")
 
 	return path
 }
 
+func (d *Disk) getDataPath(bucketName string, data string) string {
+	return filepath.Join(d.BasePath, bucketName, "data", data)
+}
+
 func exists(path string) (bool, error) {
 	_, err := os.Stat(path)
 
@@ -49,90 +108,427 @@ func existsBool(path string) bool {
 	return b
 }
 
-func (d *Disk) GetService(auth string) (*common.ListAllMyBucketsResult, error) {
-	p := d.BasePath
+// internalError logs the underlying error, which is not passed to clients.
+func internalError(op string, err error) *common.Error {
+	log.Printf("[disk] %s: %v", op, err)
+
+	return &common.ErrInternalError
+}
+
+// writeJSON atomically replaces the file at path.
+func writeJSON(path string, v interface{}) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp")
+
+	if err != nil {
+		return err
+	}
+
+	err = json.NewEncoder(f).Encode(v)
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
 
-	f, err := os.Open(p)
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
 
 	if err != nil {
-		return nil, err
+		os.Remove(f.Name())
+	}
+
+	return err
+}
+
+func readJSON(path string, v interface{}) error {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return err
 	}
 
 	defer f.Close()
 
-	fis, err := f.Readdir(0)
+	return json.NewDecoder(f).Decode(v)
+}
+
+// readObject has to be called with the lock held.
+func (d *Disk) readObject(bucketName string, objectName string) (*object, *common.Error) {
+	if !existsBool(d.getBucketPath(bucketName)) {
+		return nil, &common.ErrNoSuchBucket
+	}
+
+	o := &object{}
+	err := readJSON(d.getFilePath(bucketName, objectName), o)
+
+	if os.IsNotExist(err) {
+		return nil, &common.ErrNoSuchKey
+	} else if err != nil {
+		return nil, internalError("readObject", err)
+	}
+
+	return o, nil
+}
+
+func (d *Disk) Reset() {
+	d.Lock()
+	defer d.Unlock()
 
-	var filtered []os.FileInfo
+	fis, err := ioutil.ReadDir(d.BasePath)
+
+	if err != nil {
+		return
+	}
 
 	for _, fi := range fis {
-		if fi.IsDir() {
-			filtered = append(filtered, fi)
-		}
+		os.RemoveAll(filepath.Join(d.BasePath, fi.Name()))
+	}
+}
+
+func (d *Disk) GetService(principal *common.Principal) (*common.ListAllMyBucketsResult, *common.Error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	res := &common.ListAllMyBucketsResult{Owner: principal.Owner(), Buckets: make([]*common.Bucket, 0)}
 
-		fmt.Println(fi.Name())
+	fis, err := ioutil.ReadDir(d.BasePath)
+
+	if os.IsNotExist(err) {
+		return res, nil
+	} else if err != nil {
+		return nil, internalError("GetService", err)
 	}
 
-	res := &common.ListAllMyBucketsResult{Buckets: make([]*common.Bucket, 0)}
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			continue
+		}
 
-	for _, f := range filtered {
-		name := f.Name()
-		ctime := GetCTime(f)
+		b, awserr := d.readBucket(fi.Name())
 
-		res.Buckets = append(res.Buckets, &common.Bucket{Name: name, CreationDate: ctime})
+		if awserr != nil {
+			return nil, awserr
+		}
+
+		if principal.Owns(b.Owner) {
+			res.Buckets = append(res.Buckets, &common.Bucket{Name: fi.Name(), CreationDate: GetCTime(fi)})
+		}
 	}
 
 	return res, nil
 }
 
-func (d *Disk) DeleteBucket(bucketName string, auth string) error {
-	path := d.getBucketPath(bucketName, auth)
+func (d *Disk) DeleteBucket(bucketName string, principal *common.Principal) *common.Error {
+	d.Lock()
+	defer d.Unlock()
+
+	path := d.getBucketPath(bucketName)
 
 	if !existsBool(path) {
-		return common.ErrNotFound
+		return &common.ErrNoSuchBucket
+	}
+
+	fis, err := ioutil.ReadDir(filepath.Join(path, "objects"))
+
+	if err != nil {
+		return internalError("DeleteBucket", err)
 	}
 
-	return os.Remove(path)
+	if len(fis) > 0 {
+		return &common.ErrBucketNotEmpty
+	}
+
+	if err := os.RemoveAll(path); err != nil {
+		return internalError("DeleteBucket", err)
+	}
+
+	return nil
 }
 
-func (d *Disk) GetBucketObjects(bucketName string, auth string) (*common.ListBucketResult, error) {
-	return nil, fmt.Errorf("Not implemented")
+// readObjects returns the records of all keys in the bucket. It has to be
+// called with the lock held.
+func (d *Disk) readObjects(bucketName string) ([]object, *common.Error) {
+	path := d.getBucketPath(bucketName)
+
+	if !existsBool(path) {
+		return nil, &common.ErrNoSuchBucket
+	}
+
+	fis, err := ioutil.ReadDir(filepath.Join(path, "objects"))
+
+	if err != nil {
+		return nil, internalError("readObjects", err)
+	}
+
+	objects := make([]object, 0, len(fis))
+
+	for _, fi := range fis {
+		if !strings.HasSuffix(fi.Name(), ".json") || strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+
+		o := object{}
+
+		if err := readJSON(filepath.Join(path, "objects", fi.Name()), &o); err != nil {
+			return nil, internalError("readObjects", err)
+		}
+
+		objects = append(objects, o)
+	}
+
+	return objects, nil
 }
 
-func (d *Disk) HeadBucket(bucketName string, auth string) error {
-	return fmt.Errorf("Not implemented")
+// keys returns the listing entries of all objects in the bucket.
+func (d *Disk) keys(bucketName string) ([]common.Key, *common.Error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	objects, awserr := d.readObjects(bucketName)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	keys := make([]common.Key, 0, len(objects))
+
+	for _, o := range objects {
+		v := o.Versions[0]
+
+		if v.DeleteMarker {
+			continue
+		}
+
+		keys = append(keys, v.info().ListKey())
+	}
+
+	return keys, nil
 }
 
-func (d *Disk) PutBucket(bucketName string, auth string) error {
-	return fmt.Errorf("Not implemented")
+func (d *Disk) GetBucketObjects(bucketName string, params common.ListParams, principal *common.Principal) (*common.ListResp, *common.Error) {
+	keys, awserr := d.keys(bucketName)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	return common.ListObjects(bucketName, keys, params), nil
 }
 
-func (d *Disk) DeleteObject(bucketName string, objectName string, auth string) error {
-	return fmt.Errorf("Not implemented")
+func (d *Disk) ListObjectsV2(bucketName string, params common.ListV2Params, principal *common.Principal) (*common.ListV2Resp, *common.Error) {
+	keys, awserr := d.keys(bucketName)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	return common.ListObjectsV2(bucketName, keys, params)
 }
 
-func (d *Disk) GetObject(bucketName string, objectName string, auth string) ([]byte, error) {
-	return nil, fmt.Errorf("Not implemented")
+func (d *Disk) HeadBucket(bucketName string, principal *common.Principal) *common.Error {
+	d.RLock()
+	defer d.RUnlock()
+
+	if !existsBool(d.getBucketPath(bucketName)) {
+		return &common.ErrNoSuchBucket
+	}
+
+	return nil
 }
 
-func (d *Disk) HeadObject(bucketName string, objectName string, auth string) error {
-	return fmt.Errorf("Not implemented")
+func (d *Disk) PutBucket(bucketName string, grants []common.Grant, principal *common.Principal) *common.Error {
+	d.Lock()
+	defer d.Unlock()
+
+	path := d.getBucketPath(bucketName)
+
+	if b, awserr := d.readBucket(bucketName); awserr == nil && principal.Owns(b.Owner) {
+		return &common.ErrBucketAlreadyOwnedByYou
+	} else if awserr == nil {
+		return &common.ErrBucketAlreadyExists
+	} else if awserr != &common.ErrNoSuchBucket {
+		return awserr
+	}
+
+	for _, dir := range []string{"objects", "data", "tmp", "uploads"} {
+		if err := os.MkdirAll(filepath.Join(path, dir), 0755); err != nil {
+			return internalError("PutBucket", err)
+		}
+	}
+
+	if err := writeJSON(d.getBucketRecordPath(bucketName), &bucket{Owner: principal.Owner(), Grants: grants}); err != nil {
+		os.RemoveAll(path)
+		return internalError("PutBucket", err)
+	}
+
+	return nil
 }
 
-func (d *Disk) PutObject(bucketName string, objectName string, data []byte, auth string) error {
-	return fmt.Errorf("Not implemented")
+// GetObject returns the opened contents file. It stays readable even if the
+// object is overwritten or deleted before it is closed.
+func (d *Disk) GetObject(bucketName string, objectName string, versionID string, principal *common.Principal) (common.ObjectReader, *common.ObjectInfo, *common.Error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	v, awserr := d.readVersion(bucketName, objectName, versionID)
+
+	if awserr != nil {
+		return nil, nil, awserr
+	}
+
+	if awserr := deleteMarkerError(v, versionID); awserr != nil {
+		return nil, v.info(), awserr
+	}
+
+	f, err := os.Open(d.getDataPath(bucketName, v.Data))
+
+	if err != nil {
+		return nil, nil, internalError("GetObject", err)
+	}
+
+	return f, v.info(), nil
 }
 
-func (d *Disk) PutObjectCopy(bucketName string, objectName string, targetBucket string, targetObject string, auth string) error {
-	return fmt.Errorf("Not implemented")
+func (d *Disk) HeadObject(bucketName string, objectName string, versionID string, principal *common.Principal) (*common.ObjectInfo, *common.Error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	v, awserr := d.readVersion(bucketName, objectName, versionID)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	if awserr := deleteMarkerError(v, versionID); awserr != nil {
+		return v.info(), awserr
+	}
+
+	return v.info(), nil
 }
 
-func (d *Disk) PostObject(bucketName string, objectName string, data []byte, auth string) error {
-	return fmt.Errorf("Not implemented")
+// receive streams r into a new file in the tmp directory of the bucket and
+// returns its name, size and MD5 sum. Bodies shorter than size are rejected.
+func (d *Disk) receive(bucketName string, r io.Reader, size int64) (string, int64, []byte, *common.Error) {
+	if !existsBool(d.getBucketPath(bucketName)) {
+		return "", 0, nil, &common.ErrNoSuchBucket
+	}
+
+	f, err := ioutil.TempFile(filepath.Join(d.getBucketPath(bucketName), "tmp"), "")
+
+	if err != nil {
+		return "", 0, nil, internalError("receive", err)
+	}
+
+	h := md5.New()
+	n, err := io.Copy(io.MultiWriter(f, h), r)
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return "", 0, nil, common.BodyError(err)
+	}
+
+	if awserr := common.CheckBodySize(n, size); awserr != nil {
+		os.Remove(f.Name())
+		return "", 0, nil, awserr
+	}
+
+	return f.Name(), n, h.Sum(nil), nil
+}
+
+// commit moves a received file into the data directory and makes v the
+// current version of the key. It has to be called with the lock held.
+func (d *Disk) commit(bucketName string, objectName string, tmp string, v version) (string, *common.Error) {
+	v.Data = filepath.Base(tmp)
+
+	if err := os.Rename(tmp, d.getDataPath(bucketName, v.Data)); err != nil {
+		os.Remove(tmp)
+		return "", internalError("commit", err)
+	}
+
+	id, awserr := d.putVersion(bucketName, objectName, v)
+
+	if awserr != nil {
+		os.Remove(d.getDataPath(bucketName, v.Data))
+		return "", awserr
+	}
+
+	return id, nil
 }
 
-func main() {
-	var be common.S3Backend
+// PutObject receives the contents without holding the lock. The conditions
+// are checked before and after.
+func (d *Disk) PutObject(bucketName string, objectName string, r io.Reader, size int64, info *common.ObjectInfo, grants []common.Grant, tags []common.Tag, cond *common.Conditions, principal *common.Principal) (*common.ObjectInfo, *common.Error) {
+	if awserr := common.ValidateObjectTags(tags); awserr != nil {
+		return nil, awserr
+	}
+
+	d.RLock()
+	awserr := d.checkWrite(bucketName, objectName, cond)
+	d.RUnlock()
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	tmp, n, sum, awserr := d.receive(bucketName, r, size)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.checkWrite(bucketName, objectName, cond) != nil {
+		os.Remove(tmp)
+		return nil, &common.ErrConditionalRequestConflict
+	}
+
+	v := version{ObjectInfo: info.NewVersion(objectName, n, common.ETag(sum), principal.Owner()), Grants: grants, Tags: tags}
+
+	if v.VersionId, awserr = d.commit(bucketName, objectName, tmp, v); awserr != nil {
+		return nil, awserr
+	}
+
+	return v.info(), nil
+}
+
+// PutObjectCopy streams the contents of the source into the copy. The open
+// file stays readable if the source is removed meanwhile.
+func (d *Disk) PutObjectCopy(bucketName string, objectName string, versionID string, targetBucket string, targetObject string, opts *common.CopyOptions, principal *common.Principal) (*common.ObjectInfo, string, *common.Error) {
+	data, info, awserr := d.GetObject(bucketName, objectName, versionID, principal)
+
+	if awserr != nil {
+		return nil, "", awserr
+	}
+
+	defer data.Close()
+
+	if awserr := opts.SourceConditions.CheckCopySource(info); awserr != nil {
+		return nil, "", awserr
+	}
+
+	tags := opts.Tags
+
+	if tags == nil {
+		if tags, _, awserr = d.GetObjectTagging(bucketName, objectName, info.VersionId, principal); awserr != nil {
+			return nil, "", awserr
+		}
+	}
+
+	res, awserr := d.PutObject(targetBucket, targetObject, data, info.Size, opts.Metadata(info), opts.Grants, tags, nil, principal)
+
+	if awserr != nil {
+		return nil, "", awserr
+	}
+
+	return res, info.VersionId, nil
+}
 
-	be = &Disk{}
-	fmt.Println(be)
+func (d *Disk) PostObject(bucketName string, objectName string, r io.Reader, size int64, info *common.ObjectInfo, grants []common.Grant, tags []common.Tag, principal *common.Principal) (*common.ObjectInfo, *common.Error) {
+	return d.PutObject(bucketName, objectName, r, size, info, grants, tags, nil, principal)
 }
@@ -0,0 +1,83 @@
+package s3disk
+
+import (
+	"github.com/0x434D53/s3server/common"
+)
+
+func (d *Disk) GetBucketTagging(bucketName string, principal *common.Principal) ([]common.Tag, *common.Error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	b, awserr := d.readBucket(bucketName)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	if len(b.Tags) == 0 {
+		return nil, &common.ErrNoSuchTagSet
+	}
+
+	return b.Tags, nil
+}
+
+func (d *Disk) PutBucketTagging(bucketName string, tags []common.Tag, principal *common.Principal) *common.Error {
+	if awserr := common.ValidateBucketTags(tags); awserr != nil {
+		return awserr
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	b, awserr := d.readBucket(bucketName)
+
+	if awserr != nil {
+		return awserr
+	}
+
+	b.Tags = tags
+
+	if err := writeJSON(d.getBucketRecordPath(bucketName), b); err != nil {
+		return internalError("PutBucketTagging", err)
+	}
+
+	return nil
+}
+
+func (d *Disk) DeleteBucketTagging(bucketName string, principal *common.Principal) *common.Error {
+	return d.PutBucketTagging(bucketName, nil, principal)
+}
+
+func (d *Disk) GetObjectTagging(bucketName string, objectName string, versionID string, principal *common.Principal) ([]common.Tag, string, *common.Error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	v, awserr := d.readVersion(bucketName, objectName, versionID)
+
+	if awserr != nil {
+		return nil, "", awserr
+	}
+
+	if awserr := deleteMarkerError(v, versionID); awserr != nil {
+		return nil, "", awserr
+	}
+
+	return v.Tags, v.VersionId, nil
+}
+
+func (d *Disk) PutObjectTagging(bucketName string, objectName string, versionID string, tags []common.Tag, principal *common.Principal) (string, *common.Error) {
+	if awserr := common.ValidateObjectTags(tags); awserr != nil {
+		return "", awserr
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	return d.updateVersion(bucketName, objectName, versionID, func(v *version) {
+		v.Tags = tags
+	})
+}
+
+func (d *Disk) DeleteObjectTagging(bucketName string, objectName string, versionID string, principal *common.Principal) (string, *common.Error) {
+	return d.PutObjectTagging(bucketName, objectName, versionID, nil, principal)
+}
@@ -0,0 +1,352 @@
+package s3disk
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+// find returns the version versionID of the object, or nil.
+func (o *object) find(versionID string) *version {
+	for i := range o.Versions {
+		if o.Versions[i].VersionId == versionID {
+			return &o.Versions[i]
+		}
+	}
+
+	return nil
+}
+
+// remove deletes the version versionID from the record and returns it.
+func (o *object) remove(versionID string) *version {
+	for i, v := range o.Versions {
+		if v.VersionId == versionID {
+			o.Versions = append(o.Versions[:i:i], o.Versions[i+1:]...)
+			return &v
+		}
+	}
+
+	return nil
+}
+
+func (d *Disk) getBucketRecordPath(bucketName string) string {
+	return filepath.Join(d.getBucketPath(bucketName), "bucket.json")
+}
+
+// readBucket has to be called with the lock held. Buckets without a record
+// have the default configuration.
+func (d *Disk) readBucket(bucketName string) (*bucket, *common.Error) {
+	if !existsBool(d.getBucketPath(bucketName)) {
+		return nil, &common.ErrNoSuchBucket
+	}
+
+	b := &bucket{}
+
+	if err := readJSON(d.getBucketRecordPath(bucketName), b); err != nil && !os.IsNotExist(err) {
+		return nil, internalError("readBucket", err)
+	}
+
+	return b, nil
+}
+
+// readVersion returns the version versionID of the key, or the current
+// version for an empty version ID. It has to be called with the lock held.
+func (d *Disk) readVersion(bucketName string, objectName string, versionID string) (*version, *common.Error) {
+	o, awserr := d.readObject(bucketName, objectName)
+
+	if awserr == &common.ErrNoSuchKey && versionID != "" {
+		return nil, &common.ErrNoSuchVersion
+	} else if awserr != nil {
+		return nil, awserr
+	}
+
+	if versionID == "" {
+		return &o.Versions[0], nil
+	}
+
+	if v := o.find(versionID); v != nil {
+		return v, nil
+	}
+
+	return nil, &common.ErrNoSuchVersion
+}
+
+// checkWrite evaluates the conditions of a write of the key against its
+// current version. It has to be called with the lock held.
+func (d *Disk) checkWrite(bucketName string, objectName string, cond *common.Conditions) *common.Error {
+	if cond.IsZero() {
+		return nil
+	}
+
+	v, awserr := d.readVersion(bucketName, objectName, "")
+
+	if awserr == &common.ErrNoSuchKey {
+		return cond.CheckWrite(nil)
+	} else if awserr != nil {
+		return awserr
+	}
+
+	if v.DeleteMarker {
+		return cond.CheckWrite(nil)
+	}
+
+	return cond.CheckWrite(v.info())
+}
+
+// updateVersion applies update to a version which is not a delete marker and
+// writes the record. It returns the version ID of the version. It has to be
+// called with the lock held.
+func (d *Disk) updateVersion(bucketName string, objectName string, versionID string, update func(v *version)) (string, *common.Error) {
+	o, awserr := d.readObject(bucketName, objectName)
+
+	if awserr == &common.ErrNoSuchKey && versionID != "" {
+		return "", &common.ErrNoSuchVersion
+	} else if awserr != nil {
+		return "", awserr
+	}
+
+	v := &o.Versions[0]
+
+	if versionID != "" {
+		v = o.find(versionID)
+	}
+
+	if v == nil {
+		return "", &common.ErrNoSuchVersion
+	}
+
+	if awserr := deleteMarkerError(v, versionID); awserr != nil {
+		return "", awserr
+	}
+
+	update(v)
+
+	if err := d.writeObject(bucketName, o); err != nil {
+		return "", internalError("updateVersion", err)
+	}
+
+	return v.VersionId, nil
+}
+
+// deleteMarkerError returns the error for reading the delete marker v.
+func deleteMarkerError(v *version, versionID string) *common.Error {
+	if !v.DeleteMarker {
+		return nil
+	} else if versionID == "" {
+		return &common.ErrNoSuchKey
+	}
+
+	return &common.ErrMethodNotAllowed
+}
+
+// writeObject writes the record of the key, or removes it if no versions are
+// left. It has to be called with the lock held.
+func (d *Disk) writeObject(bucketName string, o *object) error {
+	path := d.getFilePath(bucketName, o.Key)
+
+	if len(o.Versions) == 0 {
+		return os.Remove(path)
+	}
+
+	return writeJSON(path, o)
+}
+
+// removeData removes the contents of a version which is no longer
+// referenced.
+func (d *Disk) removeData(bucketName string, v *version) {
+	if v != nil && v.Data != "" {
+		os.Remove(d.getDataPath(bucketName, v.Data))
+	}
+}
+
+// putVersion makes v the current version of the key. Unless versioning is
+// enabled it replaces the null version. It has to be called with the lock
+// held.
+func (d *Disk) putVersion(bucketName string, objectName string, v version) (string, *common.Error) {
+	b, awserr := d.readBucket(bucketName)
+
+	if awserr != nil {
+		return "", awserr
+	}
+
+	o, awserr := d.readObject(bucketName, objectName)
+
+	if awserr == &common.ErrNoSuchKey {
+		o = &object{Key: objectName}
+	} else if awserr != nil {
+		return "", awserr
+	}
+
+	var replaced *version
+
+	if b.Versioning == common.VersioningEnabled {
+		v.VersionId = common.NewVersionID()
+	} else {
+		v.VersionId = common.NullVersionID
+		replaced = o.remove(common.NullVersionID)
+	}
+
+	o.Versions = append([]version{v}, o.Versions...)
+
+	if err := d.writeObject(bucketName, o); err != nil {
+		return "", internalError("putVersion", err)
+	}
+
+	d.removeData(bucketName, replaced)
+
+	return v.VersionId, nil
+}
+
+func (d *Disk) GetBucketVersioning(bucketName string, principal *common.Principal) (string, *common.Error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	b, awserr := d.readBucket(bucketName)
+
+	if awserr != nil {
+		return "", awserr
+	}
+
+	return b.Versioning, nil
+}
+
+func (d *Disk) PutBucketVersioning(bucketName string, status string, principal *common.Principal) *common.Error {
+	d.Lock()
+	defer d.Unlock()
+
+	b, awserr := d.readBucket(bucketName)
+
+	if awserr != nil {
+		return awserr
+	}
+
+	b.Versioning = status
+
+	if err := writeJSON(d.getBucketRecordPath(bucketName), b); err != nil {
+		return internalError("PutBucketVersioning", err)
+	}
+
+	return nil
+}
+
+func (d *Disk) ListObjectVersions(bucketName string, params common.VersionsParams, principal *common.Principal) (*common.VersionsResp, *common.Error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	objects, awserr := d.readObjects(bucketName)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	versions := make([]common.Version, 0, len(objects))
+
+	for _, o := range objects {
+		for i, v := range o.Versions {
+			versions = append(versions, v.info().ListVersion(i == 0))
+		}
+	}
+
+	return common.ListObjectVersions(bucketName, versions, params), nil
+}
+
+// DeleteObject removes the given version permanently. Without a version ID
+// the object is removed if versioning was never enabled, otherwise a delete
+// marker becomes its current version.
+func (d *Disk) DeleteObject(bucketName string, objectName string, versionID string, principal *common.Principal) (string, bool, *common.Error) {
+	d.Lock()
+	defer d.Unlock()
+
+	b, awserr := d.readBucket(bucketName)
+
+	if awserr != nil {
+		return "", false, awserr
+	}
+
+	return d.deleteObject(bucketName, objectName, versionID, b, principal.Owner())
+}
+
+func (d *Disk) DeleteObjects(bucketName string, objects []common.Object, principal *common.Principal) (*common.DeleteResult, *common.Error) {
+	d.Lock()
+	defer d.Unlock()
+
+	b, awserr := d.readBucket(bucketName)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	res := &common.DeleteResult{}
+
+	for _, o := range objects {
+		id, deleteMarker, awserr := d.deleteObject(bucketName, o.Key, o.VersionId, b, principal.Owner())
+		res.Add(o, id, deleteMarker, awserr)
+	}
+
+	return res, nil
+}
+
+// deleteObject deletes the version versionID of the key, or the current one
+// for an empty version ID, like DeleteObject. It has to be called with the
+// lock held.
+func (d *Disk) deleteObject(bucketName string, objectName string, versionID string, b *bucket, owner common.Owner) (string, bool, *common.Error) {
+	if versionID == "" {
+		id, awserr := d.deleteCurrent(bucketName, objectName, b, owner, time.Now())
+
+		return id, awserr == nil && id != "", awserr
+	}
+
+	v, awserr := d.removeVersion(bucketName, objectName, versionID)
+
+	if awserr == &common.ErrNoSuchKey {
+		return "", false, &common.ErrNoSuchVersion
+	} else if awserr != nil {
+		return "", false, awserr
+	}
+
+	return v.VersionId, v.DeleteMarker, nil
+}
+
+// deleteCurrent deletes the current version of the key like a DELETE without
+// version ID. It returns the version ID of the delete marker, or "" if the key
+// was removed. Like S3, deleting a missing key succeeds. It has to be called
+// with the lock held.
+func (d *Disk) deleteCurrent(bucketName string, objectName string, b *bucket, owner common.Owner, now time.Time) (string, *common.Error) {
+	if b.Versioning != "" {
+		return d.putVersion(bucketName, objectName, version{ObjectInfo: common.ObjectInfo{Key: objectName, DeleteMarker: true, LastModified: now, Owner: owner}})
+	}
+
+	_, awserr := d.removeVersion(bucketName, objectName, common.NullVersionID)
+
+	if awserr != nil && awserr != &common.ErrNoSuchKey && awserr != &common.ErrNoSuchVersion {
+		return "", awserr
+	}
+
+	return "", nil
+}
+
+// removeVersion deletes the version versionID of the key permanently. It has
+// to be called with the lock held.
+func (d *Disk) removeVersion(bucketName string, objectName string, versionID string) (*version, *common.Error) {
+	o, awserr := d.readObject(bucketName, objectName)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	v := o.remove(versionID)
+
+	if v == nil {
+		return nil, &common.ErrNoSuchVersion
+	}
+
+	if err := d.writeObject(bucketName, o); err != nil {
+		return nil, internalError("removeVersion", err)
+	}
+
+	d.removeData(bucketName, v)
+
+	return v, nil
+}
@@ -0,0 +1,87 @@
+package inMemory
+
+import (
+	. "github.com/0x434D53/s3server/common"
+)
+
+func (s3 *S3InMemory) GetBucketACL(bucketName string, principal *Principal) (*AccessControlPolicy, *Error) {
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return nil, &ErrNoSuchBucket
+	}
+
+	return NewAccessControlPolicy(b.owner, b.grants), nil
+}
+
+func (s3 *S3InMemory) PutBucketACL(bucketName string, grants []Grant, principal *Principal) *Error {
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return &ErrNoSuchBucket
+	}
+
+	b.grants = grants
+
+	return nil
+}
+
+// objectVersion returns a version of an object which is not a delete marker.
+// It has to be called with the lock held.
+func (s3 *S3InMemory) objectVersion(bucketName string, objectName string, versionID string) (*object, *Error) {
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return nil, &ErrNoSuchBucket
+	}
+
+	o, awserr := b.version(objectName, versionID)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	if o.DeleteMarker && versionID == "" {
+		return nil, &ErrNoSuchKey
+	} else if o.DeleteMarker {
+		return nil, &ErrMethodNotAllowed
+	}
+
+	return o, nil
+}
+
+func (s3 *S3InMemory) GetObjectACL(bucketName string, objectName string, versionID string, principal *Principal) (*AccessControlPolicy, *Error) {
+	s3.Lock()
+	defer s3.Unlock()
+
+	o, awserr := s3.objectVersion(bucketName, objectName, versionID)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	return NewAccessControlPolicy(o.Owner, o.grants), nil
+}
+
+// PutObjectACL replaces the grants of a version in place. Open readers only
+// refer to the contents, which are never modified.
+func (s3 *S3InMemory) PutObjectACL(bucketName string, objectName string, versionID string, grants []Grant, principal *Principal) *Error {
+	s3.Lock()
+	defer s3.Unlock()
+
+	o, awserr := s3.objectVersion(bucketName, objectName, versionID)
+
+	if awserr != nil {
+		return awserr
+	}
+
+	o.grants = grants
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package inMemory
+
+import (
+	. "github.com/0x434D53/s3server/common"
+)
+
+func (s3 *S3InMemory) GetBucketCORS(bucketName string, principal *Principal) (*CORSConfiguration, *Error) {
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return nil, &ErrNoSuchBucket
+	}
+
+	if b.cors == nil {
+		return nil, &ErrNoSuchCORSConfiguration
+	}
+
+	return b.cors, nil
+}
+
+// PutBucketCORS replaces the configuration. Configurations are never modified,
+// so they can be returned without copying.
+func (s3 *S3InMemory) PutBucketCORS(bucketName string, cors *CORSConfiguration, principal *Principal) *Error {
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return &ErrNoSuchBucket
+	}
+
+	b.cors = cors
+
+	return nil
+}
+
+func (s3 *S3InMemory) DeleteBucketCORS(bucketName string, principal *Principal) *Error {
+	return s3.PutBucketCORS(bucketName, nil, principal)
+}
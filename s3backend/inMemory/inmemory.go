@@ -1,7 +1,11 @@
 package inMemory
 
 import (
+	"bytes"
+	"crypto/md5"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"sync"
 	"time"
@@ -13,24 +17,44 @@ func Log(method, bucket, object string) {
 	log.Printf("[%s] %s %s", method, bucket, object)
 }
 
+// object is a version of an object. Its size is the length of the contents.
 type object struct {
-	name        string
-	contentType string
-	contents    []byte
+	ObjectInfo
+	contents []byte
+	grants   []Grant
+	tags     []Tag
 }
 
 func (o object) String() string {
-	return o.name
+	return o.Key
+}
+
+// objectReader serves the contents of an in-memory object. Contents are never
+// modified in place, so readers stay valid while the object is overwritten.
+type objectReader struct {
+	*bytes.Reader
+}
+
+func (objectReader) Close() error {
+	return nil
 }
 
 type bucket struct {
-	objects      map[string]*object
+	objects      map[string][]*object // versions of each key, newest first
+	uploads      map[string]*upload
 	name         string
 	creationDate time.Time
+	owner        Owner
+	grants       []Grant
+	versioning   string
+	policy       []byte
+	cors         *CORSConfiguration
+	lifecycle    *LifecycleConfiguration
+	tags         []Tag
 	sync.Mutex
 }
 
-func (b bucket) String() string {
+func (b *bucket) String() string {
 	return fmt.Sprintf("%v [ %v ] ", b.name, b.objects)
 }
 
@@ -54,15 +78,19 @@ func (s3 *S3InMemory) Reset() {
 	s3.buckets = make(map[string]*bucket)
 }
 
-func (s3 *S3InMemory) GetService(auth string) (*ListAllMyBucketsResult, *Error) {
+func (s3 *S3InMemory) GetService(principal *Principal) (*ListAllMyBucketsResult, *Error) {
 	s3.Lock()
 	defer s3.Unlock()
 	res := ListAllMyBucketsResult{}
-	res.Owner = Owner{ID: auth}
+	res.Owner = principal.Owner()
 
 	var buckets []*Bucket
 
 	for k, v := range s3.buckets {
+		if !principal.Owns(v.owner) {
+			continue
+		}
+
 		bucket := Bucket{Name: k, CreationDate: v.creationDate}
 
 		buckets = append(buckets, &bucket)
@@ -73,55 +101,118 @@ func (s3 *S3InMemory) GetService(auth string) (*ListAllMyBucketsResult, *Error)
 	return &res, nil
 }
 
-func (s3 *S3InMemory) PostObject(bucketName string, objectName string, data []byte, contentType string, auth string) *Error {
+func (s3 *S3InMemory) PostObject(bucketName string, objectName string, r io.Reader, size int64, info *ObjectInfo, grants []Grant, tags []Tag, principal *Principal) (*ObjectInfo, *Error) {
+	return s3.PutObject(bucketName, objectName, r, size, info, grants, tags, nil, principal)
+}
+
+// PutObjectCopy shares the contents of the source, which are never modified
+// in place. The copy is a single part, so its ETag is the MD5 of the
+// contents.
+func (s3 *S3InMemory) PutObjectCopy(bucketName string, objectName string, versionID string, targetBucketName string, targetObjectName string, opts *CopyOptions, principal *Principal) (*ObjectInfo, string, *Error) {
+	if awserr := ValidateObjectTags(opts.Tags); awserr != nil {
+		return nil, "", awserr
+	}
+
 	s3.Lock()
 	defer s3.Unlock()
 
-	b, ok := s3.buckets[bucketName]
+	src, awserr := s3.objectVersion(bucketName, objectName, versionID)
+
+	if awserr != nil {
+		return nil, "", awserr
+	}
+
+	if awserr := opts.SourceConditions.CheckCopySource(&src.ObjectInfo); awserr != nil {
+		return nil, "", awserr
+	}
+
+	b, ok := s3.buckets[targetBucketName]
 
 	if !ok {
-		return &ErrNoSuchKey
+		return nil, "", &ErrNoSuchBucket
 	}
 
-	b.objects[objectName] = &object{name: objectName, contentType: contentType, contents: data}
+	tags := opts.Tags
 
-	return nil
+	if tags == nil {
+		tags = src.tags
+	}
+
+	sum := md5.Sum(src.contents)
+	o := &object{
+		ObjectInfo: opts.Metadata(&src.ObjectInfo).NewVersion(targetObjectName, src.Size, ETag(sum[:]), principal.Owner()),
+		contents:   src.contents,
+		grants:     opts.Grants,
+		tags:       tags,
+	}
+
+	b.put(o)
+	res := o.ObjectInfo
+
+	return &res, src.VersionId, nil
 }
 
-func (s3 *S3InMemory) PutObjectCopy(bucketName string, objectName string, targetBucketName string, targetObjectName string, auth string) *Error {
-	s3.Lock()
-	defer s3.Unlock()
+// PutObject reads the contents before taking the lock, so slow uploads don't
+// block other requests. The conditions are checked before and after.
+func (s3 *S3InMemory) PutObject(bucketName string, objectName string, r io.Reader, size int64, info *ObjectInfo, grants []Grant, tags []Tag, cond *Conditions, principal *Principal) (*ObjectInfo, *Error) {
+	if awserr := ValidateObjectTags(tags); awserr != nil {
+		return nil, awserr
+	}
 
-	b, ct, err := s3.GetObject(bucketName, objectName, auth)
+	if awserr := s3.checkWrite(bucketName, objectName, cond); awserr != nil {
+		return nil, awserr
+	}
+
+	data, err := ioutil.ReadAll(r)
 
 	if err != nil {
-		return err
+		return nil, BodyError(err)
 	}
 
-	err = s3.PutObject(targetBucketName, targetObjectName, b, ct, auth)
+	if awserr := CheckBodySize(int64(len(data)), size); awserr != nil {
+		return nil, awserr
+	}
 
-	if err != nil {
-		return err
+	sum := md5.Sum(data)
+
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+	if !ok {
+		return nil, &ErrNoSuchBucket
 	}
 
-	return nil
+	if b.checkWrite(objectName, cond) != nil {
+		return nil, &ErrConditionalRequestConflict
+	}
+
+	o := &object{ObjectInfo: info.NewVersion(objectName, int64(len(data)), ETag(sum[:]), principal.Owner()), contents: data, grants: grants, tags: tags}
+	b.put(o)
+	res := o.ObjectInfo
+
+	return &res, nil
 }
 
-func (s3 *S3InMemory) PutObject(bucketName string, objectName string, data []byte, contentType string, auth string) *Error {
+// checkWrite evaluates the conditions of a write of the key.
+func (s3 *S3InMemory) checkWrite(bucketName string, objectName string, cond *Conditions) *Error {
+	if cond.IsZero() {
+		return nil
+	}
+
 	s3.Lock()
 	defer s3.Unlock()
 
 	b, ok := s3.buckets[bucketName]
+
 	if !ok {
 		return &ErrNoSuchBucket
 	}
 
-	b.objects[objectName] = &object{name: objectName, contents: data}
-
-	return nil
+	return b.checkWrite(objectName, cond)
 }
 
-func (s3 *S3InMemory) DeleteBucket(bucketName string, auth string) *Error {
+func (s3 *S3InMemory) DeleteBucket(bucketName string, principal *Principal) *Error {
 	s3.Lock()
 	defer s3.Unlock()
 
@@ -130,103 +221,121 @@ func (s3 *S3InMemory) DeleteBucket(bucketName string, auth string) *Error {
 	return nil
 }
 
-func (s3 *S3InMemory) PutBucket(bucketName string, auth string) *Error {
+func (s3 *S3InMemory) PutBucket(bucketName string, grants []Grant, principal *Principal) *Error {
 	s3.Lock()
 	defer s3.Unlock()
 
-	if _, ok := s3.buckets[bucketName]; !ok {
-		s3.buckets[bucketName] = &bucket{objects: make(map[string]*object, 0)}
-		return nil
+	if b, ok := s3.buckets[bucketName]; ok && principal.Owns(b.owner) {
+		return &ErrBucketAlreadyOwnedByYou
+	} else if ok {
+		return &ErrBucketAlreadyExists
+	}
+
+	s3.buckets[bucketName] = &bucket{
+		objects:      make(map[string][]*object, 0),
+		uploads:      make(map[string]*upload),
+		name:         bucketName,
+		creationDate: time.Now(),
+		owner:        principal.Owner(),
+		grants:       grants,
 	}
 
-	return &ErrBucketAlreadyExists
+	return nil
 }
 
-func (s3 *S3InMemory) GetBucketObjects(bucketName string, auth string) (*ListBucketResult, *Error) {
+// keys returns the listing entries of all objects in the bucket.
+func (s3 *S3InMemory) keys(bucketName string) ([]Key, *Error) {
 	s3.Lock()
+	defer s3.Unlock()
+
 	b, ok := s3.buckets[bucketName]
-	s3.Unlock()
 
 	if !ok {
 		return nil, &ErrNoSuchBucket
 	}
 
-	b.Lock()
-	defer b.Unlock()
+	keys := make([]Key, 0, len(b.objects))
+
+	for name := range b.objects {
+		v := b.current(name)
 
-	lbr := ListBucketResult{}
+		if v.DeleteMarker {
+			continue
+		}
 
-	lbr.Name = b.name
-	lbr.Contents = make([]Contents, 0, len(b.objects))
+		keys = append(keys, v.ListKey())
+	}
 
-	for _, v := range b.objects {
-		o := Contents{}
+	return keys, nil
+}
 
-		o.Key = v.name
-		o.Size = len(v.contents)
+func (s3 *S3InMemory) GetBucketObjects(bucketName string, params ListParams, principal *Principal) (*ListResp, *Error) {
+	keys, awserr := s3.keys(bucketName)
 
-		lbr.Contents = append(lbr.Contents, o)
+	if awserr != nil {
+		return nil, awserr
 	}
 
-	return &lbr, nil
+	return ListObjects(bucketName, keys, params), nil
 }
 
-func (s3 *S3InMemory) HeadBucket(bucket string, auth string) *Error {
-	s3.Lock()
-	defer s3.Unlock()
+func (s3 *S3InMemory) ListObjectsV2(bucketName string, params ListV2Params, principal *Principal) (*ListV2Resp, *Error) {
+	keys, awserr := s3.keys(bucketName)
 
-	if _, ok := s3.buckets[bucket]; ok {
-		return nil
-	} else {
-		return &ErrNoSuchBucket
+	if awserr != nil {
+		return nil, awserr
 	}
+
+	return ListObjectsV2(bucketName, keys, params)
 }
 
-func (s3 *S3InMemory) HeadObject(bucket string, object string, auth string) *Error {
+func (s3 *S3InMemory) HeadBucket(bucket string, principal *Principal) *Error {
 	s3.Lock()
 	defer s3.Unlock()
 
-	if b, ok := s3.buckets[bucket]; !ok {
-		return &ErrNoSuchBucket
+	if _, ok := s3.buckets[bucket]; ok {
+		return nil
 	} else {
-		if _, ok := b.objects[object]; !ok {
-			return &ErrNoSuchKey
-		}
+		return &ErrNoSuchBucket
 	}
-
-	return nil
 }
 
-func (s3 *S3InMemory) DeleteObject(bucket string, object string, auth string) *Error {
-	s3.Lock()
-	defer s3.Unlock()
+func (s3 *S3InMemory) HeadObject(bucket string, object string, versionID string, principal *Principal) (*ObjectInfo, *Error) {
+	data, info, awserr := s3.GetObject(bucket, object, versionID, principal)
 
-	if b, ok := s3.buckets[bucket]; !ok {
-		return &ErrNoSuchBucket
-	} else {
-		if _, ok := b.objects[object]; !ok {
-			return &ErrNoSuchKey
-		}
-		delete(b.objects, object)
+	if awserr != nil {
+		return info, awserr
 	}
 
-	return nil
+	data.Close()
+
+	return info, nil
 }
 
-func (s3 *S3InMemory) GetObject(bucket string, object string, auth string) ([]byte, string, *Error) {
+// GetObject returns a copy of the ObjectInfo, as it is read without the lock.
+func (s3 *S3InMemory) GetObject(bucket string, object string, versionID string, principal *Principal) (ObjectReader, *ObjectInfo, *Error) {
 	s3.Lock()
 	defer s3.Unlock()
 
 	b, ok := s3.buckets[bucket]
 
 	if !ok {
-		return nil, "", &ErrNoSuchBucket
+		return nil, nil, &ErrNoSuchBucket
 	}
 
-	o, ok := b.objects[object]
+	o, awserr := b.version(object, versionID)
 
-	if !ok {
-		return nil, "", &ErrNoSuchKey
+	if awserr != nil {
+		return nil, nil, awserr
 	}
-	return o.contents, o.contentType, nil
+
+	info := o.ObjectInfo
+
+	if o.DeleteMarker && versionID == "" {
+		return nil, &info, &ErrNoSuchKey
+	} else if o.DeleteMarker {
+		return nil, &info, &ErrMethodNotAllowed
+	}
+
+	return objectReader{bytes.NewReader(o.contents)}, &info, nil
 }
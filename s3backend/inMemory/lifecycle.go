@@ -0,0 +1,103 @@
+package inMemory
+
+import (
+	"time"
+
+	. "github.com/0x434D53/s3server/common"
+)
+
+func (s3 *S3InMemory) GetBucketLifecycle(bucketName string, principal *Principal) (*LifecycleConfiguration, *Error) {
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return nil, &ErrNoSuchBucket
+	}
+
+	if b.lifecycle == nil {
+		return nil, &ErrNoSuchLifecycleConfiguration
+	}
+
+	return b.lifecycle, nil
+}
+
+// PutBucketLifecycle replaces the configuration. Configurations are never
+// modified, so they can be returned without copying.
+func (s3 *S3InMemory) PutBucketLifecycle(bucketName string, lifecycle *LifecycleConfiguration, principal *Principal) *Error {
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return &ErrNoSuchBucket
+	}
+
+	b.lifecycle = lifecycle
+
+	return nil
+}
+
+func (s3 *S3InMemory) DeleteBucketLifecycle(bucketName string, principal *Principal) *Error {
+	return s3.PutBucketLifecycle(bucketName, nil, principal)
+}
+
+func (s3 *S3InMemory) ObjectExpiration(bucketName string, objectName string, versionID string, principal *Principal) (time.Time, string, *Error) {
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return time.Time{}, "", &ErrNoSuchBucket
+	}
+
+	o := b.current(objectName)
+
+	if b.lifecycle == nil || o == nil || o.DeleteMarker || (versionID != "" && versionID != o.VersionId) {
+		return time.Time{}, "", nil
+	}
+
+	expires, id := b.lifecycle.Expiration(objectName, TagMap(o.tags), o.LastModified)
+
+	return expires, id, nil
+}
+
+func (s3 *S3InMemory) ApplyLifecycle(now time.Time) *Error {
+	s3.Lock()
+	defer s3.Unlock()
+
+	for _, b := range s3.buckets {
+		if b.lifecycle == nil {
+			continue
+		}
+
+		for name, vs := range b.objects {
+			versions := make([]LifecycleVersion, len(vs))
+
+			for i, o := range vs {
+				versions[i] = LifecycleVersion{VersionId: o.VersionId, DeleteMarker: o.DeleteMarker, LastModified: o.LastModified, Tags: TagMap(o.tags)}
+			}
+
+			expired, remove := b.lifecycle.Expire(name, versions, now)
+
+			for _, id := range remove {
+				b.remove(name, id)
+			}
+
+			if expired {
+				b.deleteCurrent(name, b.owner, now)
+			}
+		}
+
+		for id, u := range b.uploads {
+			if b.lifecycle.AbortsUpload(u.key, u.initiated, now) {
+				delete(b.uploads, id)
+			}
+		}
+	}
+
+	return nil
+}
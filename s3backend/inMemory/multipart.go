@@ -0,0 +1,248 @@
+package inMemory
+
+import (
+	"crypto/md5"
+	"io"
+	"io/ioutil"
+	"time"
+
+	. "github.com/0x434D53/s3server/common"
+)
+
+type upload struct {
+	key       string
+	info      ObjectInfo // metadata of the object
+	initiator Owner
+	grants    []Grant
+	tags      []Tag
+	initiated time.Time
+	parts     map[int]Part
+	contents  map[int][]byte
+}
+
+// getUpload has to be called with the lock held.
+func (s3 *S3InMemory) getUpload(bucketName string, objectName string, uploadID string) (*bucket, *upload, *Error) {
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return nil, nil, &ErrNoSuchBucket
+	}
+
+	u, ok := b.uploads[uploadID]
+
+	if !ok || u.key != objectName {
+		return nil, nil, &ErrNoSuchUpload
+	}
+
+	return b, u, nil
+}
+
+func (s3 *S3InMemory) CreateMultipartUpload(bucketName string, objectName string, info *ObjectInfo, grants []Grant, tags []Tag, principal *Principal) (string, *Error) {
+	if awserr := ValidateObjectTags(tags); awserr != nil {
+		return "", awserr
+	}
+
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return "", &ErrNoSuchBucket
+	}
+
+	id := NewUploadID()
+
+	b.uploads[id] = &upload{
+		key:       objectName,
+		info:      info.Metadata(),
+		initiator: principal.Owner(),
+		grants:    grants,
+		tags:      tags,
+		initiated: time.Now(),
+		parts:     make(map[int]Part),
+		contents:  make(map[int][]byte),
+	}
+
+	return id, nil
+}
+
+// UploadPart reads the contents before taking the lock, like PutObject.
+func (s3 *S3InMemory) UploadPart(bucketName string, objectName string, uploadID string, partNumber int, r io.Reader, size int64, principal *Principal) (string, *Error) {
+	data, err := ioutil.ReadAll(r)
+
+	if err != nil {
+		return "", BodyError(err)
+	}
+
+	if awserr := CheckBodySize(int64(len(data)), size); awserr != nil {
+		return "", awserr
+	}
+
+	s3.Lock()
+	defer s3.Unlock()
+
+	_, u, awserr := s3.getUpload(bucketName, objectName, uploadID)
+
+	if awserr != nil {
+		return "", awserr
+	}
+
+	return u.putPart(partNumber, data).ETag, nil
+}
+
+// UploadPartCopy shares the contents of the source, like PutObjectCopy.
+func (s3 *S3InMemory) UploadPartCopy(bucketName string, objectName string, versionID string, targetBucketName string, targetObjectName string, uploadID string, partNumber int, br *ByteRange, cond *Conditions, principal *Principal) (*Part, string, *Error) {
+	s3.Lock()
+	defer s3.Unlock()
+
+	src, awserr := s3.objectVersion(bucketName, objectName, versionID)
+
+	if awserr != nil {
+		return nil, "", awserr
+	}
+
+	if awserr := cond.CheckCopySource(&src.ObjectInfo); awserr != nil {
+		return nil, "", awserr
+	}
+
+	data := src.contents
+
+	if br != nil {
+		if awserr := br.CheckSize(src.Size); awserr != nil {
+			return nil, "", awserr
+		}
+
+		data = data[br.First : br.Last+1]
+	}
+
+	_, u, awserr := s3.getUpload(targetBucketName, targetObjectName, uploadID)
+
+	if awserr != nil {
+		return nil, "", awserr
+	}
+
+	p := u.putPart(partNumber, data)
+
+	return &p, src.VersionId, nil
+}
+
+// putPart stores data as part n of the upload, replacing any earlier one.
+func (u *upload) putPart(n int, data []byte) Part {
+	sum := md5.Sum(data)
+	p := Part{PartNumber: n, LastModified: FormatTime(time.Now()), ETag: ETag(sum[:]), Size: int64(len(data))}
+	u.parts[n] = p
+	u.contents[n] = data
+
+	return p
+}
+
+// CompleteMultipartUpload holds the lock throughout, so the conditions are
+// only checked once.
+func (s3 *S3InMemory) CompleteMultipartUpload(bucketName string, objectName string, uploadID string, parts []CompletedPart, cond *Conditions, principal *Principal) (string, string, *Error) {
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, u, awserr := s3.getUpload(bucketName, objectName, uploadID)
+
+	if awserr != nil {
+		return "", "", awserr
+	}
+
+	ps, etag, awserr := CompleteParts(parts, u.parts)
+
+	if awserr != nil {
+		return "", "", awserr
+	}
+
+	if awserr := b.checkWrite(objectName, cond); awserr != nil {
+		return "", "", awserr
+	}
+
+	var size int64
+
+	for _, p := range ps {
+		size += p.Size
+	}
+
+	data := make([]byte, 0, size)
+
+	for _, p := range ps {
+		data = append(data, u.contents[p.PartNumber]...)
+	}
+
+	o := &object{ObjectInfo: u.info.NewVersion(objectName, size, etag, u.initiator), contents: data, grants: u.grants, tags: u.tags}
+	o.PartSizes = PartSizes(ps)
+	id := b.put(o)
+	delete(b.uploads, uploadID)
+
+	return etag, id, nil
+}
+
+func (s3 *S3InMemory) AbortMultipartUpload(bucketName string, objectName string, uploadID string, principal *Principal) *Error {
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, _, awserr := s3.getUpload(bucketName, objectName, uploadID)
+
+	if awserr != nil {
+		return awserr
+	}
+
+	delete(b.uploads, uploadID)
+
+	return nil
+}
+
+func (s3 *S3InMemory) ListParts(bucketName string, objectName string, uploadID string, partNumberMarker int, maxParts int, principal *Principal) (*ListPartsResult, *Error) {
+	s3.Lock()
+	defer s3.Unlock()
+
+	_, u, awserr := s3.getUpload(bucketName, objectName, uploadID)
+
+	if awserr != nil {
+		return nil, awserr
+	}
+
+	res := &ListPartsResult{
+		Bucket:       bucketName,
+		Key:          objectName,
+		UploadId:     uploadID,
+		Initiator:    u.initiator,
+		Owner:        u.initiator,
+		StorageClass: u.info.StorageClass,
+	}
+
+	PageParts(res, u.parts, partNumberMarker, maxParts)
+
+	return res, nil
+}
+
+func (s3 *S3InMemory) ListMultipartUploads(bucketName string, prefix string, keyMarker string, uploadIDMarker string, maxUploads int, principal *Principal) (*ListMultipartUploadsResult, *Error) {
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return nil, &ErrNoSuchBucket
+	}
+
+	uploads := make([]Upload, 0, len(b.uploads))
+
+	for id, u := range b.uploads {
+		uploads = append(uploads, Upload{
+			Key:          u.key,
+			UploadId:     id,
+			Initiator:    u.initiator,
+			Owner:        u.initiator,
+			StorageClass: u.info.StorageClass,
+			Initiated:    FormatTime(u.initiated),
+		})
+	}
+
+	res := &ListMultipartUploadsResult{Bucket: bucketName}
+	PageUploads(res, uploads, prefix, keyMarker, uploadIDMarker, maxUploads)
+
+	return res, nil
+}
@@ -0,0 +1,52 @@
+package inMemory
+
+import (
+	. "github.com/0x434D53/s3server/common"
+)
+
+func (s3 *S3InMemory) GetBucketPolicy(bucketName string, principal *Principal) ([]byte, *Error) {
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return nil, &ErrNoSuchBucket
+	}
+
+	if b.policy == nil {
+		return nil, &ErrNoSuchBucketPolicy
+	}
+
+	return b.policy, nil
+}
+
+func (s3 *S3InMemory) PutBucketPolicy(bucketName string, policy []byte, principal *Principal) *Error {
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return &ErrNoSuchBucket
+	}
+
+	b.policy = append([]byte(nil), policy...)
+
+	return nil
+}
+
+func (s3 *S3InMemory) DeleteBucketPolicy(bucketName string, principal *Principal) *Error {
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return &ErrNoSuchBucket
+	}
+
+	b.policy = nil
+
+	return nil
+}
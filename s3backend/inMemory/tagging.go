@@ -0,0 +1,85 @@
+package inMemory
+
+import (
+	. "github.com/0x434D53/s3server/common"
+)
+
+func (s3 *S3InMemory) GetBucketTagging(bucketName string, principal *Principal) ([]Tag, *Error) {
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return nil, &ErrNoSuchBucket
+	}
+
+	if len(b.tags) == 0 {
+		return nil, &ErrNoSuchTagSet
+	}
+
+	return b.tags, nil
+}
+
+// PutBucketTagging replaces the tag set. Tag sets are never modified, so they
+// can be returned without copying.
+func (s3 *S3InMemory) PutBucketTagging(bucketName string, tags []Tag, principal *Principal) *Error {
+	if awserr := ValidateBucketTags(tags); awserr != nil {
+		return awserr
+	}
+
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return &ErrNoSuchBucket
+	}
+
+	b.tags = tags
+
+	return nil
+}
+
+func (s3 *S3InMemory) DeleteBucketTagging(bucketName string, principal *Principal) *Error {
+	return s3.PutBucketTagging(bucketName, nil, principal)
+}
+
+func (s3 *S3InMemory) GetObjectTagging(bucketName string, objectName string, versionID string, principal *Principal) ([]Tag, string, *Error) {
+	s3.Lock()
+	defer s3.Unlock()
+
+	o, awserr := s3.objectVersion(bucketName, objectName, versionID)
+
+	if awserr != nil {
+		return nil, "", awserr
+	}
+
+	return o.tags, o.VersionId, nil
+}
+
+// PutObjectTagging replaces the tags of a version in place, like
+// PutObjectACL.
+func (s3 *S3InMemory) PutObjectTagging(bucketName string, objectName string, versionID string, tags []Tag, principal *Principal) (string, *Error) {
+	if awserr := ValidateObjectTags(tags); awserr != nil {
+		return "", awserr
+	}
+
+	s3.Lock()
+	defer s3.Unlock()
+
+	o, awserr := s3.objectVersion(bucketName, objectName, versionID)
+
+	if awserr != nil {
+		return "", awserr
+	}
+
+	o.tags = tags
+
+	return o.VersionId, nil
+}
+
+func (s3 *S3InMemory) DeleteObjectTagging(bucketName string, objectName string, versionID string, principal *Principal) (string, *Error) {
+	return s3.PutObjectTagging(bucketName, objectName, versionID, nil, principal)
+}
@@ -0,0 +1,200 @@
+package inMemory
+
+import (
+	"time"
+
+	. "github.com/0x434D53/s3server/common"
+)
+
+// current returns the current version of the key, which may be a delete
+// marker, or nil if the key has no versions.
+func (b *bucket) current(name string) *object {
+	if vs := b.objects[name]; len(vs) > 0 {
+		return vs[0]
+	}
+
+	return nil
+}
+
+// checkWrite evaluates the conditions of a write of the key against its
+// current version.
+func (b *bucket) checkWrite(name string, cond *Conditions) *Error {
+	if o := b.current(name); o != nil && !o.DeleteMarker {
+		return cond.CheckWrite(&o.ObjectInfo)
+	}
+
+	return cond.CheckWrite(nil)
+}
+
+// version returns the version versionID of the key, or the current version
+// for an empty version ID.
+func (b *bucket) version(name string, versionID string) (*object, *Error) {
+	if versionID == "" {
+		o := b.current(name)
+
+		if o == nil {
+			return nil, &ErrNoSuchKey
+		}
+
+		return o, nil
+	}
+
+	for _, o := range b.objects[name] {
+		if o.VersionId == versionID {
+			return o, nil
+		}
+	}
+
+	return nil, &ErrNoSuchVersion
+}
+
+// put makes o the current version of its key. Unless versioning is enabled
+// it replaces the null version.
+func (b *bucket) put(o *object) string {
+	if b.versioning == VersioningEnabled {
+		o.VersionId = NewVersionID()
+	} else {
+		o.VersionId = NullVersionID
+		b.remove(o.Key, NullVersionID)
+	}
+
+	b.objects[o.Key] = append([]*object{o}, b.objects[o.Key]...)
+
+	return o.VersionId
+}
+
+// remove deletes the version versionID of the key permanently.
+func (b *bucket) remove(name string, versionID string) *object {
+	vs := b.objects[name]
+
+	for i, o := range vs {
+		if o.VersionId == versionID {
+			vs = append(vs[:i:i], vs[i+1:]...)
+
+			if len(vs) == 0 {
+				delete(b.objects, name)
+			} else {
+				b.objects[name] = vs
+			}
+
+			return o
+		}
+	}
+
+	return nil
+}
+
+// deleteCurrent deletes the current version of the key like a DELETE without
+// version ID. It returns the version ID of the delete marker, or "" if the key
+// was removed. Like S3, deleting a missing key succeeds.
+func (b *bucket) deleteCurrent(name string, owner Owner, now time.Time) (string, *Error) {
+	if b.versioning == "" {
+		b.remove(name, NullVersionID)
+		return "", nil
+	}
+
+	return b.put(&object{ObjectInfo: ObjectInfo{Key: name, DeleteMarker: true, LastModified: now, Owner: owner}}), nil
+}
+
+func (s3 *S3InMemory) GetBucketVersioning(bucketName string, principal *Principal) (string, *Error) {
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return "", &ErrNoSuchBucket
+	}
+
+	return b.versioning, nil
+}
+
+func (s3 *S3InMemory) PutBucketVersioning(bucketName string, status string, principal *Principal) *Error {
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return &ErrNoSuchBucket
+	}
+
+	b.versioning = status
+
+	return nil
+}
+
+func (s3 *S3InMemory) ListObjectVersions(bucketName string, params VersionsParams, principal *Principal) (*VersionsResp, *Error) {
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return nil, &ErrNoSuchBucket
+	}
+
+	versions := make([]Version, 0, len(b.objects))
+
+	for _, vs := range b.objects {
+		for i, o := range vs {
+			versions = append(versions, o.ListVersion(i == 0))
+		}
+	}
+
+	return ListObjectVersions(bucketName, versions, params), nil
+}
+
+// DeleteObject removes the given version permanently. Without a version ID
+// the object is removed if versioning was never enabled, otherwise a delete
+// marker becomes its current version.
+func (s3 *S3InMemory) DeleteObject(bucketName string, objectName string, versionID string, principal *Principal) (string, bool, *Error) {
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return "", false, &ErrNoSuchBucket
+	}
+
+	return b.deleteObject(objectName, versionID, principal.Owner())
+}
+
+func (s3 *S3InMemory) DeleteObjects(bucketName string, objects []Object, principal *Principal) (*DeleteResult, *Error) {
+	s3.Lock()
+	defer s3.Unlock()
+
+	b, ok := s3.buckets[bucketName]
+
+	if !ok {
+		return nil, &ErrNoSuchBucket
+	}
+
+	res := &DeleteResult{}
+
+	for _, o := range objects {
+		id, deleteMarker, awserr := b.deleteObject(o.Key, o.VersionId, principal.Owner())
+		res.Add(o, id, deleteMarker, awserr)
+	}
+
+	return res, nil
+}
+
+// deleteObject deletes the version versionID of the key, or the current one
+// for an empty version ID, like DeleteObject.
+func (b *bucket) deleteObject(objectName string, versionID string, owner Owner) (string, bool, *Error) {
+	if versionID != "" {
+		o := b.remove(objectName, versionID)
+
+		if o == nil {
+			return "", false, &ErrNoSuchVersion
+		}
+
+		return o.VersionId, o.DeleteMarker, nil
+	}
+
+	id, awserr := b.deleteCurrent(objectName, owner, time.Now())
+
+	return id, awserr == nil && id != "", awserr
+}
@@ -1,42 +1,1157 @@
 package s3backend
 
 import (
+	"bytes"
+	"crypto/md5"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
 	"testing"
+	"time"
 
 	. "github.com/0x434D53/s3server/common"
-	. "github.com/0x434D53/s3server/s3backend/inMemory"
+	"github.com/0x434D53/s3server/s3backend/disk"
+	"github.com/0x434D53/s3server/s3backend/inMemory"
 )
 
-var backend S3Backend
+// alice and bob are the principals of the test requests.
+var (
+	alice = &Principal{ID: "alice-id", DisplayName: "alice"}
+	bob   = &Principal{ID: "bob-id", DisplayName: "bob"}
+)
+
+// backends are the backends every test is run against. create returns the
+// backend and a function that cleans up after the test.
+var backends = []struct {
+	name   string
+	create func(t *testing.T) (S3Backend, func())
+}{
+	{"inMemory", func(t *testing.T) (S3Backend, func()) {
+		return inMemory.NewS3Backend(), func() {}
+	}},
+	{"disk", func(t *testing.T) (S3Backend, func()) {
+		dir, err := ioutil.TempDir("", "s3disk")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return s3disk.NewS3Backend(s3disk.Options{BasePath: dir}), func() { os.RemoveAll(dir) }
+	}},
+}
 
-var create func() S3Backend
+func forEachBackend(t *testing.T, test func(t *testing.T, backend S3Backend)) {
+	for _, b := range backends {
+		b := b
+		t.Run(b.name, func(t *testing.T) {
+			backend, cleanup := b.create(t)
+			defer cleanup()
 
-func init() {
-	create = NewS3Backend
+			test(t, backend)
+		})
+	}
 }
 
 func TestPutHeadBucket(t *testing.T) {
-	backend := create()
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		err := backend.PutBucket("TestBucket", nil, alice)
+
+		if err != nil {
+			t.Error("PutBucket gave an Error")
+		}
+
+		err = backend.HeadBucket("TestBucket", alice)
+
+		if err != nil {
+			t.Error("Head for existing Bucket resulted in an error")
+		}
+	})
+}
+
+func TestHeadNonExistBucket(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		err := backend.HeadBucket("TestBucket", alice)
+
+		if err != &ErrNoSuchBucket {
+			t.Errorf("Head for non existing Bucket should give an ErrNoSuchBucket")
+		}
+	})
+}
+
+func TestPutGetObjectStream(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		contents := bytes.Repeat([]byte("0123456789"), 100000)
+
+		if err := backend.PutBucket("bucket", nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := backend.PutObject("bucket", "dir/key", bytes.NewReader(contents), int64(len(contents)), &ObjectInfo{ContentType: "text/plain"}, nil, nil, nil, alice)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, info, err := backend.GetObject("bucket", "dir/key", "", alice)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer data.Close()
+
+		if info.Size != int64(len(contents)) || info.ContentType != "text/plain" {
+			t.Errorf("Expected size %d and content type text/plain, got %d and %s", len(contents), info.Size, info.ContentType)
+		}
+
+		if _, err := data.Seek(500000, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+
+		b, rerr := ioutil.ReadAll(data)
+
+		if rerr != nil {
+			t.Fatal(rerr)
+		}
+
+		if !bytes.Equal(b, contents[500000:]) {
+			t.Errorf("Contents read after seeking differ")
+		}
+	})
+}
+
+func TestOverwriteWhileReading(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		if err := backend.PutBucket("bucket", nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := backend.PutObject("bucket", "key", bytes.NewReader([]byte("old")), 3, nil, nil, nil, nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		data, _, err := backend.GetObject("bucket", "key", "", alice)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer data.Close()
+
+		if _, err := backend.PutObject("bucket", "key", bytes.NewReader([]byte("new")), 3, nil, nil, nil, nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		b, _ := ioutil.ReadAll(data)
+
+		if string(b) != "old" {
+			t.Errorf("Expected open reader to return old contents, got %q", b)
+		}
+	})
+}
+
+func TestPutObjectNoSuchBucket(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		_, err := backend.PutObject("bucket", "key", bytes.NewReader(nil), 0, nil, nil, nil, nil, alice)
+
+		if err != &ErrNoSuchBucket {
+			t.Errorf("Expected ErrNoSuchBucket, got %v", err)
+		}
+	})
+}
+
+func TestIncompleteBody(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		if err := backend.PutBucket("bucket", nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := backend.PutObject("bucket", "key", bytes.NewReader([]byte("abc")), 5, nil, nil, nil, nil, alice); err != &ErrIncompleteBody {
+			t.Errorf("Expected ErrIncompleteBody, got %v", err)
+		}
+
+		if _, err := backend.HeadObject("bucket", "key", "", alice); err != &ErrNoSuchKey {
+			t.Errorf("Incomplete body must not be stored, got %v", err)
+		}
+
+		id, _ := backend.CreateMultipartUpload("bucket", "key", nil, nil, nil, alice)
+
+		if _, err := backend.UploadPart("bucket", "key", id, 1, bytes.NewReader([]byte("abc")), 5, alice); err != &ErrIncompleteBody {
+			t.Errorf("Expected ErrIncompleteBody for the part, got %v", err)
+		}
+
+		if lpr, err := backend.ListParts("bucket", "key", id, 0, 0, alice); err != nil || len(lpr.Parts) != 0 {
+			t.Errorf("Incomplete part must not be stored, got %+v, %v", lpr, err)
+		}
+	})
+}
+
+func TestMultipartUpload(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		if err := backend.PutBucket("bucket", nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		id, err := backend.CreateMultipartUpload("bucket", "key", &ObjectInfo{ContentType: "text/plain"}, nil, nil, alice)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		part1 := bytes.Repeat([]byte("a"), MinPartSize)
+		part2 := []byte("b")
+
+		// Part 2 is uploaded first and part 1 twice; both must not matter.
+		etag2, err := backend.UploadPart("bucket", "key", id, 2, bytes.NewReader(part2), 1, alice)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = backend.UploadPart("bucket", "key", id, 1, bytes.NewReader([]byte("x")), 1, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		etag1, err := backend.UploadPart("bucket", "key", id, 1, bytes.NewReader(part1), int64(len(part1)), alice)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lpr, err := backend.ListParts("bucket", "key", id, 0, 1, alice)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(lpr.Parts) != 1 || !lpr.IsTruncated || lpr.Parts[0].ETag != etag1 || lpr.NextPartNumberMarker != 1 || lpr.StorageClass != StorageClassStandard {
+			t.Errorf("Unexpected first page of parts: %+v", lpr)
+		}
+
+		lmu, err := backend.ListMultipartUploads("bucket", "", "", "", 0, alice)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(lmu.Uploads) != 1 || lmu.Uploads[0].UploadId != id || lmu.Uploads[0].Key != "key" || lmu.Uploads[0].StorageClass != StorageClassStandard {
+			t.Errorf("Expected the upload to be listed, got %+v", lmu.Uploads)
+		}
+
+		parts := []CompletedPart{{PartNumber: 1, ETag: etag1}, {PartNumber: 2, ETag: etag2}}
+
+		if _, _, err = backend.CompleteMultipartUpload("bucket", "key", id, []CompletedPart{parts[1], parts[0]}, nil, alice); err != &ErrInvalidPartOrder {
+			t.Errorf("Expected ErrInvalidPartOrder, got %v", err)
+		}
+
+		if _, _, err = backend.CompleteMultipartUpload("bucket", "key", id, []CompletedPart{{PartNumber: 1, ETag: etag2}}, nil, alice); err != &ErrInvalidPart {
+			t.Errorf("Expected ErrInvalidPart, got %v", err)
+		}
+
+		etag, _, err := backend.CompleteMultipartUpload("bucket", "key", id, parts, nil, alice)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if etag != `"e5a8c5272b26fc10581a21089559b006-2"` {
+			t.Errorf("Unexpected ETag %s", etag)
+		}
+
+		data, info, err := backend.GetObject("bucket", "key", "", alice)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer data.Close()
+
+		b, _ := ioutil.ReadAll(data)
+
+		if info.Size != int64(len(part1)+len(part2)) || info.ContentType != "text/plain" || !bytes.Equal(b, append(part1, part2...)) {
+			t.Errorf("Object does not consist of the uploaded parts")
+		}
+
+		if !reflect.DeepEqual(info.PartSizes, []int64{int64(len(part1)), int64(len(part2))}) {
+			t.Errorf("Expected the sizes of the parts, got %v", info.PartSizes)
+		}
+
+		if _, err = backend.ListParts("bucket", "key", id, 0, 0, alice); err != &ErrNoSuchUpload {
+			t.Errorf("Expected ErrNoSuchUpload after completion, got %v", err)
+		}
+	})
+}
+
+func TestMultipartUploadEntityTooSmall(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		if err := backend.PutBucket("bucket", nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		id, err := backend.CreateMultipartUpload("bucket", "key", nil, nil, nil, alice)
 
-	err := backend.PutBucket("TestBucket", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var parts []CompletedPart
+
+		for i := 1; i <= 2; i++ {
+			etag, err := backend.UploadPart("bucket", "key", id, i, bytes.NewReader([]byte("small")), 5, alice)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parts = append(parts, CompletedPart{PartNumber: i, ETag: etag})
+		}
+
+		if _, _, err = backend.CompleteMultipartUpload("bucket", "key", id, parts, nil, alice); err != &ErrEntityTooSmall {
+			t.Errorf("Expected ErrEntityTooSmall, got %v", err)
+		}
+
+		if err = backend.AbortMultipartUpload("bucket", "key", id, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = backend.AbortMultipartUpload("bucket", "key", id, alice); err != &ErrNoSuchUpload {
+			t.Errorf("Expected ErrNoSuchUpload after abort, got %v", err)
+		}
+
+		if _, err = backend.HeadObject("bucket", "key", "", alice); err != &ErrNoSuchKey {
+			t.Errorf("Aborted upload must not create an object, got %v", err)
+		}
+	})
+}
+
+func TestGetBucketObjectsSorted(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		if err := backend.PutBucket("bucket", nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, key := range []string{"c", "a/b", "b", "a/a"} {
+			if _, err := backend.PutObject("bucket", key, bytes.NewReader([]byte(key)), int64(len(key)), nil, nil, nil, nil, alice); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		l, err := backend.GetBucketObjects("bucket", ListParams{Delimiter: "/", MaxKeys: 2}, alice)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if l.Name != "bucket" || len(l.CommonPrefixes) != 1 || l.CommonPrefixes[0].Prefix != "a/" || len(l.Contents) != 1 || l.Contents[0].Key != "b" || !l.IsTruncated {
+			t.Errorf("Unexpected listing %+v", l)
+		}
+
+		if l.Contents[0].Size != 1 || l.Contents[0].LastModified == "" {
+			t.Errorf("Expected size and last modified time to be set, got %+v", l.Contents[0])
+		}
+	})
+}
+
+func readObject(t *testing.T, backend S3Backend, key string, versionID string) string {
+	data, _, err := backend.GetObject("bucket", key, versionID, alice)
 
 	if err != nil {
-		t.Error("PutBucket gave an Error")
+		t.Fatalf("Reading version %q of %s failed: %v", versionID, key, err)
 	}
 
-	err = backend.HeadBucket("TestBucket", "")
+	defer data.Close()
 
-	if err != nil {
-		t.Error("Head for existing Bucket resulted in an error")
+	b, _ := ioutil.ReadAll(data)
+
+	return string(b)
+}
+
+func TestObjectMetadata(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		if err := backend.PutBucket("bucket", nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		meta := &ObjectInfo{
+			ContentType:        "text/plain",
+			ContentEncoding:    "gzip",
+			CacheControl:       "no-cache",
+			ContentDisposition: "attachment",
+			Expires:            "Thu, 01 Dec 1994 16:00:00 GMT",
+			Meta:               map[string][]string{"color": {"blue"}},
+			StorageClass:       "STANDARD_IA",
+		}
+
+		put, err := backend.PutObject("bucket", "key", bytes.NewReader([]byte("hello")), 5, meta, nil, nil, nil, bob)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if put.ETag != `"5d41402abc4b2a76b9719d911017c592"` || put.Size != 5 || put.VersionId != NullVersionID {
+			t.Errorf("Unexpected result of the write %+v", put)
+		}
+
+		info, err := backend.HeadObject("bucket", "key", "", alice)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(info.Metadata(), meta.Metadata()) || info.ETag != put.ETag || info.Owner != bob.Owner() || info.LastModified.IsZero() {
+			t.Errorf("Expected the metadata to be kept, got %+v", info)
+		}
+
+		l, err := backend.GetBucketObjects("bucket", ListParams{MaxKeys: MaxKeys}, alice)
+
+		if err != nil || len(l.Contents) != 1 || l.Contents[0].ETag != put.ETag || l.Contents[0].StorageClass != "STANDARD_IA" {
+			t.Errorf("Unexpected listing %+v, %v", l, err)
+		}
+
+		vs, err := backend.ListObjectVersions("bucket", VersionsParams{MaxKeys: MaxKeys}, alice)
+
+		if err != nil || len(vs.Versions) != 1 || vs.Versions[0].ETag != put.ETag || vs.Versions[0].Size != 5 {
+			t.Errorf("Unexpected versions %+v, %v", vs, err)
+		}
+
+		id, err := backend.CreateMultipartUpload("bucket", "upload", meta, nil, nil, alice)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if lpr, err := backend.ListParts("bucket", "upload", id, 0, 0, alice); err != nil || lpr.StorageClass != "STANDARD_IA" {
+			t.Errorf("Expected the storage class of the upload, got %+v, %v", lpr, err)
+		}
+
+		if lmu, err := backend.ListMultipartUploads("bucket", "", "", "", 0, alice); err != nil || len(lmu.Uploads) != 1 || lmu.Uploads[0].StorageClass != "STANDARD_IA" {
+			t.Errorf("Expected the upload to be listed with its storage class, got %+v, %v", lmu, err)
+		}
+
+		etag, _ := backend.UploadPart("bucket", "upload", id, 1, bytes.NewReader([]byte("part")), 4, alice)
+
+		if _, _, err := backend.CompleteMultipartUpload("bucket", "upload", id, []CompletedPart{{PartNumber: 1, ETag: etag}}, nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if info, err := backend.HeadObject("bucket", "upload", "", alice); err != nil || !reflect.DeepEqual(info.Metadata(), meta.Metadata()) {
+			t.Errorf("Expected the upload to keep the metadata, got %+v, %v", info, err)
+		}
+
+		if _, _, err := backend.PutObjectCopy("bucket", "key", "", "bucket", "copy", &CopyOptions{}, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if info, err := backend.HeadObject("bucket", "copy", "", alice); err != nil || !reflect.DeepEqual(info.Metadata(), meta.Metadata()) || info.ETag != put.ETag {
+			t.Errorf("Expected the copy to keep the metadata, got %+v, %v", info, err)
+		}
+	})
+}
+
+func TestPutObjectCopy(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		backend.PutBucket("bucket", nil, alice)
+		backend.PutBucket("target", nil, alice)
+		backend.PutBucketVersioning("bucket", VersioningEnabled, alice)
+
+		meta := &ObjectInfo{ContentType: "text/plain", Meta: map[string][]string{"color": {"blue"}}}
+		tags := []Tag{{Key: "state", Value: "old"}}
+		v1, _ := backend.PutObject("bucket", "key", bytes.NewReader([]byte("v1")), 2, meta, nil, tags, nil, alice)
+		putVersion(t, backend, "key", "v2", nil)
+
+		info, id, err := backend.PutObjectCopy("bucket", "key", v1.VersionId, "target", "copy", &CopyOptions{}, bob)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if id != v1.VersionId || info.ETag != v1.ETag || info.Owner != bob.Owner() || info.Meta["color"][0] != "blue" {
+			t.Errorf("Expected a copy of v1, got %+v from %s", info, id)
+		}
+
+		data, _, _ := backend.GetObject("target", "copy", "", alice)
+		b, _ := ioutil.ReadAll(data)
+		data.Close()
+
+		if string(b) != "v1" {
+			t.Errorf("Expected the contents of v1, got %s", b)
+		}
+
+		if got, _, _ := backend.GetObjectTagging("target", "copy", "", alice); !reflect.DeepEqual(got, tags) {
+			t.Errorf("Expected the tags of v1, got %v", got)
+		}
+
+		opts := &CopyOptions{MetadataDirective: DirectiveReplace, Info: &ObjectInfo{ContentType: "application/json"}, Tags: []Tag{}}
+
+		if info, _, err := backend.PutObjectCopy("bucket", "key", v1.VersionId, "target", "copy", opts, alice); err != nil || info.ContentType != "application/json" || info.Meta != nil {
+			t.Errorf("Expected the metadata to be replaced, got %+v, %v", info, err)
+		}
+
+		if got, _, _ := backend.GetObjectTagging("target", "copy", "", alice); len(got) != 0 {
+			t.Errorf("Expected the tags to be replaced, got %v", got)
+		}
+
+		failed := &CopyOptions{SourceConditions: &Conditions{IfNoneMatch: v1.ETag}}
+
+		if _, _, err := backend.PutObjectCopy("bucket", "key", v1.VersionId, "target", "copy", failed, alice); err != &ErrPreconditionFailed {
+			t.Errorf("Expected ErrPreconditionFailed, got %v", err)
+		}
+
+		if _, _, err := backend.PutObjectCopy("bucket", "missing", "", "target", "copy", &CopyOptions{}, alice); err != &ErrNoSuchKey {
+			t.Errorf("Expected ErrNoSuchKey, got %v", err)
+		}
+
+		if _, _, err := backend.PutObjectCopy("bucket", "key", "", "missing", "copy", &CopyOptions{}, alice); err != &ErrNoSuchBucket {
+			t.Errorf("Expected ErrNoSuchBucket, got %v", err)
+		}
+	})
+}
+
+func TestUploadPartCopy(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		backend.PutBucket("bucket", nil, alice)
+		backend.PutBucketVersioning("bucket", VersioningEnabled, alice)
+
+		v1 := putVersion(t, backend, "source", "hello world", nil)
+		putVersion(t, backend, "source", "v2", nil)
+
+		id, _ := backend.CreateMultipartUpload("bucket", "key", nil, nil, nil, alice)
+
+		if _, _, err := backend.UploadPartCopy("bucket", "source", "", "bucket", "key", id, 1, nil, nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		part, version, err := backend.UploadPartCopy("bucket", "source", v1, "bucket", "key", id, 1, &ByteRange{First: 6, Last: 10}, nil, alice)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sum := md5.Sum([]byte("world"))
+
+		if version != v1 || part.PartNumber != 1 || part.Size != 5 || part.ETag != ETag(sum[:]) {
+			t.Errorf("Expected part 1 to be world of v1, got %+v from %s", part, version)
+		}
+
+		if _, _, err := backend.CompleteMultipartUpload("bucket", "key", id, []CompletedPart{{PartNumber: 1, ETag: part.ETag}}, nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := readObject(t, backend, "key", ""); got != "world" {
+			t.Errorf("Expected world, got %s", got)
+		}
+
+		id, _ = backend.CreateMultipartUpload("bucket", "key", nil, nil, nil, alice)
+
+		if _, _, err := backend.UploadPartCopy("bucket", "source", v1, "bucket", "key", id, 1, &ByteRange{First: 6, Last: 11}, nil, alice); err != &ErrInvalidRange {
+			t.Errorf("Expected ErrInvalidRange, got %v", err)
+		}
+
+		cond := &Conditions{IfMatch: "\"other\""}
+
+		if _, _, err := backend.UploadPartCopy("bucket", "source", "", "bucket", "key", id, 1, nil, cond, alice); err != &ErrPreconditionFailed {
+			t.Errorf("Expected ErrPreconditionFailed, got %v", err)
+		}
+
+		if _, _, err := backend.UploadPartCopy("bucket", "missing", "", "bucket", "key", id, 1, nil, nil, alice); err != &ErrNoSuchKey {
+			t.Errorf("Expected ErrNoSuchKey, got %v", err)
+		}
+
+		if _, _, err := backend.UploadPartCopy("bucket", "source", "", "bucket", "key", "missing", 1, nil, nil, alice); err != &ErrNoSuchUpload {
+			t.Errorf("Expected ErrNoSuchUpload, got %v", err)
+		}
+	})
+}
+
+// racingReader writes the key before it returns the contents, like a
+// concurrent writer.
+type racingReader struct {
+	backend S3Backend
+	key     string
+	done    bool
+}
+
+func (r *racingReader) Read(p []byte) (int, error) {
+	if !r.done {
+		r.done = true
+		r.backend.PutObject("bucket", r.key, bytes.NewReader([]byte("other")), 5, nil, nil, nil, nil, alice)
 	}
+
+	return 0, io.EOF
 }
 
-func TestHeadNonExistBucket(t *testing.T) {
-	backend := create()
+func TestConditionalWrite(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		if err := backend.PutBucket("bucket", nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		create := &Conditions{IfNoneMatch: "*"}
+		put := func(data string, cond *Conditions) (*ObjectInfo, *Error) {
+			return backend.PutObject("bucket", "key", bytes.NewReader([]byte(data)), int64(len(data)), nil, nil, nil, cond, alice)
+		}
+
+		v1, err := put("v1", create)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := put("v2", create); err != &ErrPreconditionFailed {
+			t.Errorf("Expected ErrPreconditionFailed for an existing key, got %v", err)
+		}
+
+		if _, err := put("v2", &Conditions{IfMatch: `"other"`}); err != &ErrPreconditionFailed {
+			t.Errorf("Expected ErrPreconditionFailed for another ETag, got %v", err)
+		}
+
+		if _, err := put("v2", &Conditions{IfMatch: v1.ETag}); err != nil {
+			t.Errorf("Expected the write to succeed, got %v", err)
+		}
+
+		if _, err := backend.PutObject("bucket", "missing", bytes.NewReader(nil), 0, nil, nil, nil, &Conditions{IfMatch: v1.ETag}, alice); err != &ErrNoSuchKey {
+			t.Errorf("Expected ErrNoSuchKey, got %v", err)
+		}
 
-	err := backend.HeadBucket("TestBucket", "")
+		r := &racingReader{backend: backend, key: "race"}
 
-	if err != ErrNotFound {
-		t.Errorf("Head for non existing Bucket should give an ErrNotFound")
+		if _, err := backend.PutObject("bucket", "race", r, -1, nil, nil, nil, create, alice); err != &ErrConditionalRequestConflict {
+			t.Errorf("Expected ErrConditionalRequestConflict, got %v", err)
+		}
+
+		if readObject(t, backend, "race", "") != "other" {
+			t.Error("The concurrent write must be kept")
+		}
+
+		id, _ := backend.CreateMultipartUpload("bucket", "key", nil, nil, nil, alice)
+		etag, _ := backend.UploadPart("bucket", "key", id, 1, bytes.NewReader([]byte("part")), 4, alice)
+		parts := []CompletedPart{{PartNumber: 1, ETag: etag}}
+
+		if _, _, err := backend.CompleteMultipartUpload("bucket", "key", id, parts, create, alice); err != &ErrPreconditionFailed {
+			t.Errorf("Expected ErrPreconditionFailed for the upload, got %v", err)
+		}
+
+		if _, _, err := backend.CompleteMultipartUpload("bucket", "key", id, parts, &Conditions{IfMatch: "*"}, alice); err != nil {
+			t.Errorf("Expected the upload to be completed, got %v", err)
+		}
+	})
+}
+
+// putVersion writes data to key in bucket and returns the version ID.
+func putVersion(t *testing.T, backend S3Backend, key string, data string, tags []Tag) string {
+	info, err := backend.PutObject("bucket", key, bytes.NewReader([]byte(data)), int64(len(data)), nil, nil, tags, nil, alice)
+
+	if err != nil {
+		t.Fatalf("Writing %s failed: %v", key, err)
 	}
+
+	return info.VersionId
+}
+
+func TestVersioning(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		if err := backend.PutBucket("bucket", nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := backend.PutBucketVersioning("bucket", VersioningEnabled, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		v1 := putVersion(t, backend, "key", "v1", nil)
+		v2 := putVersion(t, backend, "key", "v2", nil)
+
+		if v1 == v2 || v1 == NullVersionID {
+			t.Fatalf("Expected distinct version IDs, got %q and %q", v1, v2)
+		}
+
+		if readObject(t, backend, "key", "") != "v2" || readObject(t, backend, "key", v1) != "v1" {
+			t.Error("Unexpected contents of the versions")
+		}
+
+		marker, deleteMarker, err := backend.DeleteObject("bucket", "key", "", alice)
+
+		if err != nil || !deleteMarker {
+			t.Fatalf("Expected a delete marker, got %v, %v", deleteMarker, err)
+		}
+
+		if _, info, err := backend.GetObject("bucket", "key", "", alice); err != &ErrNoSuchKey || info == nil || info.VersionId != marker || !info.DeleteMarker {
+			t.Errorf("Expected ErrNoSuchKey with the delete marker, got %v, %+v", err, info)
+		}
+
+		if _, err := backend.HeadObject("bucket", "key", marker, alice); err != &ErrMethodNotAllowed {
+			t.Errorf("Expected ErrMethodNotAllowed for the delete marker, got %v", err)
+		}
+
+		if lbr, err := backend.GetBucketObjects("bucket", ListParams{MaxKeys: MaxKeys}, alice); err != nil || len(lbr.Contents) != 0 {
+			t.Errorf("Deleted object must not be listed, got %+v, %v", lbr, err)
+		}
+
+		vs, err := backend.ListObjectVersions("bucket", VersionsParams{MaxKeys: MaxKeys}, alice)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(vs.Versions) != 2 || vs.Versions[0].VersionId != v2 || vs.Versions[1].VersionId != v1 || vs.Versions[0].IsLatest {
+			t.Errorf("Unexpected versions %+v", vs.Versions)
+		}
+
+		if len(vs.DeleteMarkers) != 1 || vs.DeleteMarkers[0].VersionId != marker || !vs.DeleteMarkers[0].IsLatest {
+			t.Errorf("Unexpected delete markers %+v", vs.DeleteMarkers)
+		}
+
+		if id, deleteMarker, err := backend.DeleteObject("bucket", "key", marker, alice); err != nil || !deleteMarker || id != marker {
+			t.Fatalf("Removing the delete marker failed: %q, %v, %v", id, deleteMarker, err)
+		}
+
+		if readObject(t, backend, "key", "") != "v2" {
+			t.Error("Removing the delete marker must restore the previous version")
+		}
+
+		if _, _, err := backend.DeleteObject("bucket", "key", "unknown", alice); err != &ErrNoSuchVersion {
+			t.Errorf("Expected ErrNoSuchVersion, got %v", err)
+		}
+	})
+}
+
+func TestDeleteObjects(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		backend.PutBucket("bucket", nil, alice)
+		backend.PutBucketVersioning("bucket", VersioningEnabled, alice)
+
+		v1 := putVersion(t, backend, "a", "v1", nil)
+		putVersion(t, backend, "a", "v2", nil)
+		putVersion(t, backend, "b", "b", nil)
+
+		objects := []Object{{Key: "a", VersionId: v1}, {Key: "b"}, {Key: "missing"}, {Key: "a", VersionId: "unknown"}}
+		res, err := backend.DeleteObjects("bucket", objects, alice)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(res.Deleted) != 3 || res.Deleted[0].VersionId != v1 || res.Deleted[0].DeleteMarker || !res.Deleted[1].DeleteMarker || res.Deleted[1].DeleteMarkerVersionId == "" || res.Deleted[2].Key != "missing" {
+			t.Errorf("Unexpected deleted objects %+v", res.Deleted)
+		}
+
+		if len(res.Errors) != 1 || res.Errors[0].Key != "a" || res.Errors[0].Code != ErrNoSuchVersion.Code {
+			t.Errorf("Expected NoSuchVersion for the unknown version, got %+v", res.Errors)
+		}
+
+		if readObject(t, backend, "a", "") != "v2" {
+			t.Error("Deleting a version must keep the current one")
+		}
+
+		if _, _, err := backend.GetObject("bucket", "a", v1, alice); err != &ErrNoSuchVersion {
+			t.Errorf("Expected v1 to be deleted, got %v", err)
+		}
+
+		if _, _, err := backend.GetObject("bucket", "b", "", alice); err != &ErrNoSuchKey {
+			t.Errorf("Expected a delete marker for b, got %v", err)
+		}
+
+		if _, err := backend.DeleteObjects("missing", objects, alice); err != &ErrNoSuchBucket {
+			t.Errorf("Expected ErrNoSuchBucket, got %v", err)
+		}
+	})
+}
+
+func TestDeleteMissingObject(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		backend.PutBucket("bucket", nil, alice)
+		putVersion(t, backend, "key", "data", nil)
+
+		for i := 0; i < 2; i++ {
+			if id, deleteMarker, err := backend.DeleteObject("bucket", "key", "", alice); err != nil || deleteMarker || id != "" {
+				t.Errorf("Expected deleting the key to succeed, got %q, %v, %v", id, deleteMarker, err)
+			}
+		}
+
+		if _, _, err := backend.DeleteObject("missing", "key", "", alice); err != &ErrNoSuchBucket {
+			t.Errorf("Expected ErrNoSuchBucket, got %v", err)
+		}
+	})
+}
+
+func TestVersioningSuspended(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		if err := backend.PutBucket("bucket", nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if status, err := backend.GetBucketVersioning("bucket", alice); err != nil || status != "" {
+			t.Errorf("Expected no versioning status, got %q, %v", status, err)
+		}
+
+		if _, err := backend.PutObject("bucket", "key", bytes.NewReader([]byte("null")), 4, nil, nil, nil, nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		backend.PutBucketVersioning("bucket", VersioningEnabled, alice)
+
+		v1 := putVersion(t, backend, "key", "v1", nil)
+
+		backend.PutBucketVersioning("bucket", VersioningSuspended, alice)
+
+		if id := putVersion(t, backend, "key", "suspended", nil); id != NullVersionID {
+			t.Fatalf("Expected the null version, got %q", id)
+		}
+
+		if readObject(t, backend, "key", NullVersionID) != "suspended" || readObject(t, backend, "key", v1) != "v1" {
+			t.Error("A write while versioning is suspended must only replace the null version")
+		}
+
+		vs, _ := backend.ListObjectVersions("bucket", VersionsParams{MaxKeys: MaxKeys}, alice)
+
+		if len(vs.Versions) != 2 {
+			t.Errorf("Expected 2 versions, got %+v", vs.Versions)
+		}
+	})
+}
+
+func TestOwners(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		if err := backend.PutBucket("bucket", nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := backend.PutBucket("bucket", nil, alice); err != &ErrBucketAlreadyOwnedByYou {
+			t.Errorf("Expected ErrBucketAlreadyOwnedByYou, got %v", err)
+		}
+
+		if err := backend.PutBucket("bucket", nil, bob); err != &ErrBucketAlreadyExists {
+			t.Errorf("Expected ErrBucketAlreadyExists, got %v", err)
+		}
+
+		if s, err := backend.GetService(alice); err != nil || s.Owner != alice.Owner() || len(s.Buckets) != 1 || s.Buckets[0].Name != "bucket" {
+			t.Errorf("Expected alice to own the bucket, got %+v, %v", s, err)
+		}
+
+		if s, err := backend.GetService(bob); err != nil || s.Owner != bob.Owner() || len(s.Buckets) != 0 {
+			t.Errorf("Expected bob to own no buckets, got %+v, %v", s, err)
+		}
+
+		// Owners are identified by ID, the display name may change.
+		renamed := &Principal{ID: alice.ID, DisplayName: "alice smith"}
+
+		if s, err := backend.GetService(renamed); err != nil || len(s.Buckets) != 1 {
+			t.Errorf("Expected the renamed alice to own the bucket, got %+v, %v", s, err)
+		}
+
+		if err := backend.PutBucket("bucket", nil, renamed); err != &ErrBucketAlreadyOwnedByYou {
+			t.Errorf("Expected ErrBucketAlreadyOwnedByYou for the renamed alice, got %v", err)
+		}
+
+		if _, err := backend.PutObject("bucket", "key", bytes.NewReader([]byte("bob")), 3, nil, nil, nil, nil, bob); err != nil {
+			t.Fatal(err)
+		}
+
+		l, err := backend.GetBucketObjects("bucket", ListParams{MaxKeys: MaxKeys}, alice)
+
+		if err != nil || len(l.Contents) != 1 || l.Contents[0].Owner == nil || *l.Contents[0].Owner != bob.Owner() {
+			t.Errorf("Expected bob to own the object, got %+v, %v", l, err)
+		}
+
+		id, _ := backend.CreateMultipartUpload("bucket", "key", nil, nil, nil, alice)
+
+		if lpr, err := backend.ListParts("bucket", "key", id, 0, 0, bob); err != nil || lpr.Initiator != alice.Owner() {
+			t.Errorf("Expected alice to initiate the upload, got %+v, %v", lpr, err)
+		}
+	})
+}
+
+func TestACL(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		if err := backend.PutBucket("bucket", nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		acl, err := backend.GetBucketACL("bucket", alice)
+
+		if err != nil || acl.Owner != alice.Owner() || len(acl.Grants) != 1 || acl.Grants[0].Permission != PermissionFullControl {
+			t.Errorf("Expected a private bucket, got %+v, %v", acl, err)
+		}
+
+		grants, _ := PublicRead.Grants(alice.Owner(), alice.Owner())
+
+		if err := backend.PutBucketACL("bucket", grants, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if acl, _ := backend.GetBucketACL("bucket", alice); !acl.Allows(Anonymous, PermissionRead) {
+			t.Errorf("Expected a public bucket, got %+v", acl)
+		}
+
+		if _, err := backend.PutObject("bucket", "key", bytes.NewReader([]byte("bob")), 3, nil, grants, nil, nil, bob); err != nil {
+			t.Fatal(err)
+		}
+
+		acl, err = backend.GetObjectACL("bucket", "key", "", alice)
+
+		if err != nil || acl.Owner != bob.Owner() || !acl.Allows(Anonymous, PermissionRead) {
+			t.Errorf("Expected a public object owned by bob, got %+v, %v", acl, err)
+		}
+
+		if err := backend.PutObjectACL("bucket", "key", "", []Grant{}, bob); err != nil {
+			t.Fatal(err)
+		}
+
+		if acl, _ := backend.GetObjectACL("bucket", "key", "", alice); len(acl.Grants) != 0 || acl.Allows(bob, PermissionRead) {
+			t.Errorf("Expected an object without grants, got %+v", acl)
+		}
+
+		if _, err := backend.GetObjectACL("bucket", "missing", "", alice); err != &ErrNoSuchKey {
+			t.Errorf("Expected ErrNoSuchKey, got %v", err)
+		}
+	})
+}
+
+func TestBucketPolicy(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		if err := backend.PutBucket("bucket", nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := backend.GetBucketPolicy("bucket", alice); err != &ErrNoSuchBucketPolicy {
+			t.Errorf("Expected ErrNoSuchBucketPolicy, got %v", err)
+		}
+
+		doc := `{"Statement": []}`
+
+		if err := backend.PutBucketPolicy("bucket", []byte(doc), alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if p, err := backend.GetBucketPolicy("bucket", alice); err != nil || string(p) != doc {
+			t.Errorf("Expected %s, got %s, %v", doc, p, err)
+		}
+
+		if err := backend.DeleteBucketPolicy("bucket", alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := backend.GetBucketPolicy("bucket", alice); err != &ErrNoSuchBucketPolicy {
+			t.Errorf("Expected ErrNoSuchBucketPolicy after delete, got %v", err)
+		}
+
+		if err := backend.PutBucketPolicy("missing", []byte(doc), alice); err != &ErrNoSuchBucket {
+			t.Errorf("Expected ErrNoSuchBucket, got %v", err)
+		}
+	})
+}
+
+func TestBucketCORS(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		if err := backend.PutBucket("bucket", nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := backend.GetBucketCORS("bucket", alice); err != &ErrNoSuchCORSConfiguration {
+			t.Errorf("Expected ErrNoSuchCORSConfiguration, got %v", err)
+		}
+
+		cors := &CORSConfiguration{CORSRules: []CORSRule{{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}, MaxAgeSeconds: 60}}}
+
+		if err := backend.PutBucketCORS("bucket", cors, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if c, err := backend.GetBucketCORS("bucket", alice); err != nil || !reflect.DeepEqual(c.CORSRules, cors.CORSRules) {
+			t.Errorf("Expected %+v, got %+v, %v", cors, c, err)
+		}
+
+		if err := backend.DeleteBucketCORS("bucket", alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := backend.GetBucketCORS("bucket", alice); err != &ErrNoSuchCORSConfiguration {
+			t.Errorf("Expected ErrNoSuchCORSConfiguration after delete, got %v", err)
+		}
+	})
+}
+
+func TestLifecycle(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		now := time.Now()
+		worker := &LifecycleWorker{Backend: backend, Now: func() time.Time { return now }}
+		days := func(d int) { now = now.AddDate(0, 0, d) }
+
+		for _, name := range []string{"plain", "versioned"} {
+			if err := backend.PutBucket(name, nil, alice); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		backend.PutBucketVersioning("versioned", VersioningEnabled, alice)
+
+		if _, err := backend.GetBucketLifecycle("plain", alice); err != &ErrNoSuchLifecycleConfiguration {
+			t.Errorf("Expected ErrNoSuchLifecycleConfiguration, got %v", err)
+		}
+
+		backend.PutBucketLifecycle("plain", &LifecycleConfiguration{Rules: []LifecycleRule{
+			{ID: "logs", Status: LifecycleEnabled, Filter: &LifecycleFilter{Prefix: "logs/"}, Expiration: &LifecycleExpiration{Days: 1}},
+			{Status: LifecycleEnabled, AbortIncompleteMultipartUpload: &AbortIncompleteMultipartUpload{DaysAfterInitiation: 2}},
+		}}, alice)
+		backend.PutBucketLifecycle("versioned", &LifecycleConfiguration{Rules: []LifecycleRule{
+			{Status: LifecycleEnabled, Expiration: &LifecycleExpiration{Days: 1}, NoncurrentVersionExpiration: &NoncurrentVersionExpiration{NoncurrentDays: 1}},
+			{Status: LifecycleEnabled, Expiration: &LifecycleExpiration{ExpiredObjectDeleteMarker: true}},
+		}}, alice)
+
+		for _, key := range []string{"logs/a", "keep"} {
+			backend.PutObject("plain", key, bytes.NewReader([]byte(key)), int64(len(key)), nil, nil, nil, nil, alice)
+		}
+
+		backend.PutObject("versioned", "key", bytes.NewReader([]byte("v1")), 2, nil, nil, nil, nil, alice)
+		backend.PutObject("versioned", "key", bytes.NewReader([]byte("v2")), 2, nil, nil, nil, nil, alice)
+		backend.CreateMultipartUpload("plain", "upload", nil, nil, nil, alice)
+
+		if expires, id, err := backend.ObjectExpiration("plain", "logs/a", "", alice); err != nil || id != "logs" || expires.Before(now) || expires.After(now.AddDate(0, 0, 2)) {
+			t.Errorf("Unexpected expiration %v by %q, %v", expires, id, err)
+		}
+
+		if expires, _, _ := backend.ObjectExpiration("plain", "keep", "", alice); !expires.IsZero() {
+			t.Errorf("Expected keep not to expire, got %v", expires)
+		}
+
+		if err := worker.RunOnce(); err != nil {
+			t.Fatal(err)
+		}
+
+		if l, _ := backend.GetBucketObjects("plain", ListParams{MaxKeys: MaxKeys}, alice); len(l.Contents) != 2 {
+			t.Errorf("Expected nothing to expire yet, got %+v", l.Contents)
+		}
+
+		days(2)
+		worker.RunOnce()
+
+		if l, _ := backend.GetBucketObjects("plain", ListParams{MaxKeys: MaxKeys}, alice); len(l.Contents) != 1 || l.Contents[0].Key != "keep" {
+			t.Errorf("Expected logs/a to expire, got %+v", l.Contents)
+		}
+
+		if u, _ := backend.ListMultipartUploads("plain", "", "", "", 1000, alice); len(u.Uploads) != 1 {
+			t.Errorf("Expected the upload to be kept, got %+v", u.Uploads)
+		}
+
+		vs, _ := backend.ListObjectVersions("versioned", VersionsParams{MaxKeys: MaxKeys}, alice)
+
+		if len(vs.DeleteMarkers) != 1 || len(vs.Versions) != 1 || !vs.DeleteMarkers[0].IsLatest {
+			t.Errorf("Expected a delete marker and the noncurrent v2, got %+v", vs)
+		}
+
+		days(2)
+		worker.RunOnce()
+
+		if u, _ := backend.ListMultipartUploads("plain", "", "", "", 1000, alice); len(u.Uploads) != 0 {
+			t.Errorf("Expected the upload to be aborted, got %+v", u.Uploads)
+		}
+
+		if vs, _ := backend.ListObjectVersions("versioned", VersionsParams{MaxKeys: MaxKeys}, alice); len(vs.Versions) != 0 || len(vs.DeleteMarkers) != 0 {
+			t.Errorf("Expected all versions and the delete marker to expire, got %+v", vs)
+		}
+	})
+}
+
+func TestTagging(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend S3Backend) {
+		if err := backend.PutBucket("bucket", nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := backend.GetBucketTagging("bucket", alice); err != &ErrNoSuchTagSet {
+			t.Errorf("Expected ErrNoSuchTagSet, got %v", err)
+		}
+
+		tags := []Tag{{Key: "project", Value: "s3server"}}
+
+		if err := backend.PutBucketTagging("bucket", tags, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, err := backend.GetBucketTagging("bucket", alice); err != nil || !reflect.DeepEqual(got, tags) {
+			t.Errorf("Expected %v, got %v, %v", tags, got, err)
+		}
+
+		if err := backend.PutBucketTagging("bucket", []Tag{{Key: "aws:reserved", Value: ""}}, alice); err != &ErrInvalidTagKey {
+			t.Errorf("Expected ErrInvalidTagKey, got %v", err)
+		}
+
+		if err := backend.DeleteBucketTagging("bucket", alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := backend.GetBucketTagging("bucket", alice); err != &ErrNoSuchTagSet {
+			t.Errorf("Expected the tag set to be deleted, got %v", err)
+		}
+
+		tooMany := make([]Tag, MaxObjectTags+1)
+
+		for i := range tooMany {
+			tooMany[i] = Tag{Key: string(rune('a' + i))}
+		}
+
+		if _, err := backend.PutObject("bucket", "key", bytes.NewReader([]byte("x")), 1, nil, nil, tooMany, nil, alice); err != &ErrObjectTagCount {
+			t.Errorf("Expected ErrObjectTagCount, got %v", err)
+		}
+
+		backend.PutBucketVersioning("bucket", VersioningEnabled, alice)
+		v1 := putVersion(t, backend, "key", "v1", tags)
+		v2 := putVersion(t, backend, "key", "v2", nil)
+
+		if got, id, err := backend.GetObjectTagging("bucket", "key", v1, alice); err != nil || id != v1 || !reflect.DeepEqual(got, tags) {
+			t.Errorf("Expected the tags of v1, got %v, %s, %v", got, id, err)
+		}
+
+		if got, id, err := backend.GetObjectTagging("bucket", "key", "", alice); err != nil || id != v2 || len(got) != 0 {
+			t.Errorf("Expected v2 without tags, got %v, %s, %v", got, id, err)
+		}
+
+		if id, err := backend.PutObjectTagging("bucket", "key", "", []Tag{{Key: "state", Value: "new"}}, alice); err != nil || id != v2 {
+			t.Errorf("Expected the tags of v2 to be set, got %s, %v", id, err)
+		}
+
+		if id, err := backend.DeleteObjectTagging("bucket", "key", v1, alice); err != nil || id != v1 {
+			t.Errorf("Expected the tags of v1 to be deleted, got %s, %v", id, err)
+		}
+
+		if got, _, _ := backend.GetObjectTagging("bucket", "key", v1, alice); len(got) != 0 {
+			t.Errorf("Expected v1 without tags, got %v", got)
+		}
+
+		if _, err := backend.PutObjectTagging("bucket", "missing", "", tags, alice); err != &ErrNoSuchKey {
+			t.Errorf("Expected ErrNoSuchKey, got %v", err)
+		}
+
+		if _, _, err := backend.PutObjectCopy("bucket", "key", "", "bucket", "copy", &CopyOptions{}, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, _, _ := backend.GetObjectTagging("bucket", "copy", "", alice); !reflect.DeepEqual(got, []Tag{{Key: "state", Value: "new"}}) {
+			t.Errorf("Expected the copy to keep the tags, got %v", got)
+		}
+
+		id, _ := backend.CreateMultipartUpload("bucket", "upload", nil, nil, tags, alice)
+		etag, _ := backend.UploadPart("bucket", "upload", id, 1, bytes.NewReader([]byte("part")), 4, alice)
+
+		if _, _, err := backend.CompleteMultipartUpload("bucket", "upload", id, []CompletedPart{{PartNumber: 1, ETag: etag}}, nil, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, _, _ := backend.GetObjectTagging("bucket", "upload", "", alice); !reflect.DeepEqual(got, tags) {
+			t.Errorf("Expected the tags of the upload, got %v", got)
+		}
+
+		backend.PutBucketLifecycle("bucket", &LifecycleConfiguration{Rules: []LifecycleRule{
+			{ID: "new", Status: LifecycleEnabled, Filter: &LifecycleFilter{Tag: &Tag{Key: "state", Value: "new"}}, Expiration: &LifecycleExpiration{Days: 1}},
+		}}, alice)
+
+		if expires, id, _ := backend.ObjectExpiration("bucket", "key", "", alice); expires.IsZero() || id != "new" {
+			t.Errorf("Expected the tagged object to expire, got %v by %q", expires, id)
+		}
+
+		if expires, _, _ := backend.ObjectExpiration("bucket", "upload", "", alice); !expires.IsZero() {
+			t.Errorf("Expected the untagged object not to expire, got %v", expires)
+		}
+	})
 }
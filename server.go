@@ -1,27 +1,50 @@
 package main
 
 import (
+	"crypto/md5"
+	"encoding/base64"
 	"encoding/xml"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"strconv"
+	"time"
 
+	"github.com/0x434D53/s3server/auth"
 	"github.com/0x434D53/s3server/common"
+	"github.com/0x434D53/s3server/s3backend/disk"
 	"github.com/0x434D53/s3server/s3backend/inMemory"
 )
 
 var port = flag.String("port", "10001", "Server will run on this port")
 var hostname = flag.String("host", "test.dev", "Hostname analogous to s3.amazonaws.com")
 var basePath = flag.String("basepath", "s3", "Basepath for S3")
+var backendName = flag.String("backend", "memory", "Storage backend (memory or disk)")
+var credentials = flag.String("credentials", "", "Comma-separated accessKey:secretKey pairs. Requests are authenticated if set")
+var credentialsFile = flag.String("credentials-file", "", "JSON file of accounts. Requests are authenticated if set")
+var region = flag.String("region", "", "Region requests have to be signed for (any if empty)")
+var signature = flag.String("signature", "both", "Accepted signature versions (v2, v4 or both)")
+var lifecycleInterval = flag.Duration("lifecycle-interval", time.Hour, "Interval in which lifecycle rules are applied")
 var host string
 
+// maxKeyLength is the maximum length of an object key in bytes.
+const maxKeyLength = 1024
+
 var backend common.S3Backend
 
+// verifier authenticates requests. It is nil if authentication is disabled.
+var verifier *auth.Verifier
+
+// localPrincipal makes all requests if authentication is disabled.
+var localPrincipal = &common.Principal{ID: auth.CanonicalID("s3server"), DisplayName: "s3server"}
+
 func writeError(w http.ResponseWriter, awserr *common.Error) error {
 	b, err := xml.Marshal(awserr)
 
@@ -39,6 +62,38 @@ func writeError(w http.ResponseWriter, awserr *common.Error) error {
 	return nil
 }
 
+// readXML decodes the XML document in the body of r into v, or returns
+// malformed if it is not valid. The body is read to its end first, so that the
+// readers verifying it report their errors.
+func readXML(r *http.Request, v interface{}, malformed *common.Error) *common.Error {
+	b, err := ioutil.ReadAll(r.Body)
+
+	if err != nil {
+		return common.BodyError(err)
+	}
+
+	if err := xml.Unmarshal(b, v); err != nil {
+		return malformed
+	}
+
+	return nil
+}
+
+// writeXML writes v as XML document with status code 200.
+func writeXML(w http.ResponseWriter, v interface{}) {
+	b, err := xml.Marshal(v)
+
+	if err != nil {
+		log.Print(err)
+		writeError(w, &common.ErrInternalError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+	w.Write(b)
+}
+
 func setCommondResponseHeaders(w http.ResponseWriter, h *common.ResponseHeaders) {
 	hd := w.Header()
 
@@ -50,26 +105,52 @@ func setCommondResponseHeaders(w http.ResponseWriter, h *common.ResponseHeaders)
 		hd.Set("Content-Type", h.ContentType)
 	}
 
-	hd.Set("Date", h.Date)
-	hd.Set("ETag", h.ETag)
+	if h.Date != "" {
+		hd.Set("Date", h.Date)
+	}
+
+	if h.ETag != "" {
+		hd.Set("ETag", h.ETag)
+	}
 
 	if h.Server != "" {
 		hd.Set("Server", h.Server)
 	}
 
-	hd.Set("x-amz-delete-marker", fmt.Sprintf("%v", h.XAmzDeleteMarker))
-	hd.Set("x-amz-id-2", h.XAmzId2)
-	hd.Set("x-amz-request-id", h.XAmzRequestId)
-	hd.Set("x-amz-version-id", h.XAmzVersionId)
+	if h.XAmzDeleteMarker {
+		hd.Set("x-amz-delete-marker", "true")
+	}
+
+	for name, value := range map[string]string{
+		"x-amz-id-2":       h.XAmzId2,
+		"x-amz-request-id": h.XAmzRequestId,
+		"x-amz-version-id": h.XAmzVersionId,
+	} {
+		if value != "" {
+			hd.Set(name, value)
+		}
+	}
 }
 
 func logHandlerCall(handler string, rd *S3Request) {
 	log.Printf("=====Web===== [%s / %s] %s/%s | %v |", handler, rd.s3method, rd.bucket, rd.object, rd.params)
 }
 
+func getServiceHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("getServiceHandler", rd)
+	res, awserr := backend.GetService(rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	writeXML(w, res)
+}
+
 func headBucketHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
 	logHandlerCall("headBucketHandler", rd)
-	err := backend.HeadBucket(rd.bucket, rd.Authorization)
+	err := backend.HeadBucket(rd.bucket, rd.principal)
 
 	log.Printf("HeadBucketHandler: %v", err)
 
@@ -82,7 +163,15 @@ func headBucketHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
 
 func putBucketHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
 	logHandlerCall("putBucketHandler", rd)
-	awserr := backend.PutBucket(rd.bucket, rd.Authorization)
+	owner := rd.principal.Owner()
+	grants, awserr := requestGrants(r, owner, owner)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	awserr = backend.PutBucket(rd.bucket, grants, rd.principal)
 
 	if awserr != nil {
 		writeError(w, awserr)
@@ -93,22 +182,78 @@ func putBucketHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
 
 func getBucketHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
 	logHandlerCall("getBucketHandler", rd)
-	lbr, awserr := backend.GetBucketObjects(rd.bucket, rd.Authorization)
+	maxKeys, awserr := rd.IntParam("max-keys", common.MaxKeys)
 
 	if awserr != nil {
 		writeError(w, awserr)
 		return
 	}
 
-	b, err := xml.Marshal(lbr)
+	encodingType, awserr := rd.EncodingType()
 
-	if err != nil {
-		log.Print(err)
-		http.Error(w, "Error", 500)
+	if awserr != nil {
+		writeError(w, awserr)
 		return
 	}
 
-	w.Write(b)
+	params := common.ListParams{
+		Prefix:    rd.Param("prefix"),
+		Delimiter: rd.Param("delimiter"),
+		Marker:    rd.Param("marker"),
+		MaxKeys:   maxKeys,
+	}
+
+	lbr, awserr := backend.GetBucketObjects(rd.bucket, params, rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	if encodingType == "url" {
+		lbr.URLEncode()
+	}
+
+	writeXML(w, lbr)
+}
+
+func getBucketV2Handler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("getBucketV2Handler", rd)
+	maxKeys, awserr := rd.IntParam("max-keys", common.MaxKeys)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	encodingType, awserr := rd.EncodingType()
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	params := common.ListV2Params{
+		Prefix:            rd.Param("prefix"),
+		Delimiter:         rd.Param("delimiter"),
+		StartAfter:        rd.Param("start-after"),
+		ContinuationToken: rd.Param("continuation-token"),
+		MaxKeys:           maxKeys,
+		FetchOwner:        rd.Param("fetch-owner") == "true",
+	}
+
+	lbr, awserr := backend.ListObjectsV2(rd.bucket, params, rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	if encodingType == "url" {
+		lbr.URLEncode()
+	}
+
+	writeXML(w, lbr)
 }
 
 func getBucketLocationHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
@@ -118,104 +263,157 @@ func getBucketLocationHandler(w http.ResponseWriter, r *http.Request, rd *S3Requ
 
 func deleteBucketHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
 	logHandlerCall("deleteBucketHandler", rd)
-	err := backend.DeleteBucket(rd.bucket, rd.Authorization)
+	err := backend.DeleteBucket(rd.bucket, rd.principal)
 
 	if err != nil {
 	}
 	http.Error(w, "Not Implemented", 500)
 }
 
-func postObjectHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
-	logHandlerCall("postObjectHandler", rd)
-	http.Error(w, "Not Implemented", 500)
+// writeObjectError writes the error of a read of an object. If the object is
+// a delete marker, the backend returns its ObjectInfo along with the error.
+func writeObjectError(w http.ResponseWriter, awserr *common.Error, info *common.ObjectInfo) {
+	if info != nil && info.DeleteMarker {
+		w.Header().Set("x-amz-delete-marker", "true")
+		w.Header().Set("x-amz-version-id", info.VersionId)
+	}
+
+	writeError(w, awserr)
 }
 
 func getObjectHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
 	logHandlerCall("getObjectHandler", rd)
-	data, ct, err := backend.GetObject(rd.bucket, rd.object, rd.Authorization)
+	data, info, err := backend.GetObject(rd.bucket, rd.object, rd.Param("versionId"), rd.principal)
 
 	if err != nil {
-		writeError(w, err)
+		writeObjectError(w, err, info)
+		return
+	}
+
+	defer data.Close()
+
+	if !checkReadConditions(w, r, info) {
+		return
+	}
+
+	br, awserr := objectRange(r, rd, info)
+
+	if awserr != nil {
+		writeRangeError(w, info, awserr)
 		return
 	}
 
-	rh := &common.ResponseHeaders{
-		ContentLength: fmt.Sprintf("%d", len(data)),
-		ContentType:   ct,
+	var body io.Reader = data
+
+	if br != nil {
+		if _, err := data.Seek(br.First, io.SeekStart); err != nil {
+			log.Printf("Seeking in %s/%s failed: %v", rd.bucket, rd.object, err)
+			writeError(w, &common.ErrInternalError)
+			return
+		}
+
+		body = io.LimitReader(data, br.Length())
 	}
 
-	setCommondResponseHeaders(w, rh)
+	setObjectHeaders(w, rd, info)
+	setExpirationHeader(w, rd, info.VersionId)
+	setTaggingCountHeader(w, rd, info.VersionId)
+	writeRangeHeaders(w, rd, info, br)
 
-	w.Write(data)
+	if _, err := io.Copy(w, body); err != nil {
+		log.Printf("Writing %s/%s failed: %v", rd.bucket, rd.object, err)
+	}
 }
 
+// putObjectHandler passes the body on to the backend as it is received. The
+// Content-Length is -1 for chunked requests.
 func putObjectHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
 	logHandlerCall("putObjectHandler", rd)
-	contents, err := ioutil.ReadAll(r.Body)
+	grants, awserr := objectGrants(r, rd)
 
-	if err != nil {
-		writeError(w, &common.ErrInternalError)
+	if awserr != nil {
+		writeError(w, awserr)
 		return
 	}
 
-	awserr := backend.PutObject(rd.bucket, rd.object, contents, rd.ContentType, rd.Authorization)
+	tags, awserr := requestTags(r)
 
 	if awserr != nil {
 		writeError(w, awserr)
 		return
 	}
 
-	w.WriteHeader(200)
-}
+	info, awserr := objectMetadata(r, rd)
 
-func copyObjectHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
-	logHandlerCall("copyObjectHandler", rd)
-	http.Error(w, "Not Implemented", 500)
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	cond, awserr := writeConditions(r)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	info, awserr = backend.PutObject(rd.bucket, rd.object, r.Body, r.ContentLength, info, grants, tags, cond, rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	w.Header().Set("ETag", info.ETag)
+	setVersionHeader(w, "x-amz-version-id", rd, info.VersionId)
+	w.WriteHeader(200)
 }
 
 func headObjectHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
 	logHandlerCall("headObjectHandler", rd)
-	http.Error(w, "Not Implemented", 500)
+	info, err := backend.HeadObject(rd.bucket, rd.object, rd.Param("versionId"), rd.principal)
+
+	if err != nil {
+		writeObjectError(w, err, info)
+		return
+	}
+
+	if !checkReadConditions(w, r, info) {
+		return
+	}
+
+	br, awserr := objectRange(r, rd, info)
+
+	if awserr != nil {
+		writeRangeError(w, info, awserr)
+		return
+	}
+
+	setObjectHeaders(w, rd, info)
+	setExpirationHeader(w, rd, info.VersionId)
+	writeRangeHeaders(w, rd, info, br)
 }
 
 func deleteObjectHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
 	logHandlerCall("deleteObjectHandler", rd)
-	err := backend.DeleteObject(rd.bucket, rd.object, "")
+	versionID, deleteMarker, err := backend.DeleteObject(rd.bucket, rd.object, rd.Param("versionId"), rd.principal)
 
 	if err != nil {
 		writeError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-}
+	if versionID != "" {
+		w.Header().Set("x-amz-version-id", versionID)
+	}
 
-func getBucketObjectVersionHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
-	logHandlerCall("getBucketObjectVersionHandler", rd)
-	http.Error(w, "Not Implemented", 500)
-}
+	if deleteMarker {
+		w.Header().Set("x-amz-delete-marker", "true")
+	}
 
-func getBucketVersioningHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
-	logHandlerCall("getBucketVersioningHandler", rd)
-	http.Error(w, "Not Implemented", 500)
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func getBucketACLHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
-	logHandlerCall("getBucketACLHandler", rd)
-	http.Error(w, "Not Implemented", 500)
-}
-func getBucketCORSHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
-	logHandlerCall("getBucketCORSHandler", rd)
-	http.Error(w, "Not Implemented", 500)
-}
-func getBucketLifecycleHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
-	logHandlerCall("getBucketLifecycleHandler", rd)
-	http.Error(w, "Not Implemented", 500)
-}
-func getBucketPolicyHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
-	logHandlerCall("getBucketPolicyHandler", rd)
-	http.Error(w, "Not Implemented", 500)
-}
 func getBucketLoggingHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
 	logHandlerCall("getBucketLoggingHandler", rd)
 	http.Error(w, "Not Implemented", 500)
@@ -228,10 +426,6 @@ func getBucketReplicationHandler(w http.ResponseWriter, r *http.Request, rd *S3R
 	logHandlerCall("getBucketReplicationHandler", rd)
 	http.Error(w, "Not Implemented", 500)
 }
-func getBucketTagging(w http.ResponseWriter, r *http.Request, rd *S3Request) {
-	logHandlerCall("getBucketTagging", rd)
-	http.Error(w, "Not Implemented", 500)
-}
 func getBucketRequestPaymentHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
 	logHandlerCall("getBucketRequestPaymentHandler", rd)
 	http.Error(w, "Not Implemented", 500)
@@ -241,46 +435,65 @@ func getBucketWebsiteHandler(w http.ResponseWriter, r *http.Request, rd *S3Reque
 	http.Error(w, "Not Implemented", 500)
 }
 
-func putBucketVersioningHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
-	logHandlerCall("putBucketVersioningHandler", rd)
-	http.Error(w, "Not Implemented", 500)
-}
+func mainHandler(w http.ResponseWriter, r *http.Request) {
+	rd, err := getS3RequestData(r)
+
+	if err != nil {
+		log.Print(err)
+		writeError(w, err)
 
-func deleteObjectVersionHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+		return
+	}
 
-	logHandlerCall("deleteObjectVersionHandler", rd)
-	http.Error(w, "Not Implemented", 500)
-}
+	rd.principal = localPrincipal
 
-func getObjectVersionHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
-	logHandlerCall("getObjectVersionHandler", rd)
-	http.Error(w, "Not Implemented", 500)
-}
+	if verifier != nil {
+		hostBucket := ""
 
-func headObjectVersionHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+		if rd.virtualHost {
+			hostBucket = rd.bucket
+		}
 
-	logHandlerCall("headObjectVersionHandler", rd)
-	http.Error(w, "Not Implemented", 500)
-}
+		account, awserr := verifier.Verify(r, hostBucket)
 
-func putObjectVersionHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
-	logHandlerCall("putObjectVersionHandler", rd)
-	http.Error(w, "Not Implemented", 500)
-}
+		if awserr != nil {
+			log.Printf("Authentication failed: %v", awserr)
+			writeError(w, awserr)
+			return
+		}
 
-func mainHandler(w http.ResponseWriter, r *http.Request) {
-	rd, err := getS3RequestData(r)
+		rd.principal = common.Anonymous
 
-	if err != nil {
-		log.Print(err)
-		http.Error(w, "Error parsing the request", 500)
+		if account != nil {
+			rd.principal = account.Principal()
+		}
+	}
+
+	if r.Header.Get("Origin") != "" && rd.s3method != OPTIONSOBJECT {
+		setCORSHeaders(w, r, rd)
+	}
+
+	// Browser uploads name the key and carry their authentication in the
+	// form, so postObjectHandler authorizes them once it is read.
+	if rd.s3method != POSTOBJECT {
+		if awserr := authorize(r, rd); awserr != nil {
+			writeError(w, awserr)
+			return
+		}
+	}
 
+	if awserr := verifyContentMD5(r, rd); awserr != nil {
+		writeError(w, awserr)
 		return
 	}
 
 	switch rd.s3method {
+	case GETSERVICE:
+		getServiceHandler(w, r, rd)
 	case GETBUCKET:
 		getBucketHandler(w, r, rd)
+	case GETBUCKET_V2:
+		getBucketV2Handler(w, r, rd)
 	case GETBUCKET_ACL:
 		getBucketACLHandler(w, r, rd)
 	case GETBUCKET_CORS:
@@ -298,7 +511,7 @@ func mainHandler(w http.ResponseWriter, r *http.Request) {
 	case GETBUCKET_REPLICATION:
 		getBucketReplicationHandler(w, r, rd)
 	case GETBUCKET_TAGGING:
-		getBucketTagging(w, r, rd)
+		getBucketTaggingHandler(w, r, rd)
 	case GETBUCKET_OBJECTVERSION:
 		getBucketObjectVersionHandler(w, r, rd)
 	case GETBUCKET_REQUESTPAYMENT:
@@ -314,20 +527,37 @@ func mainHandler(w http.ResponseWriter, r *http.Request) {
 	case DELETEBUCKET:
 		deleteBucketHandler(w, r, rd)
 	case PUTBUCKET_ACL:
+		putBucketACLHandler(w, r, rd)
 	case PUTBUCKET_CORS:
+		putBucketCORSHandler(w, r, rd)
+	case DELETEBUCKET_CORS:
+		deleteBucketCORSHandler(w, r, rd)
 	case PUTBUCKET_LIFECYCLE:
+		putBucketLifecycleHandler(w, r, rd)
+	case DELETEBUCKET_LIFTCYCLE:
+		deleteBucketLifecycleHandler(w, r, rd)
 	case PUTBUCKET_POLICY:
+		putBucketPolicyHandler(w, r, rd)
+	case DELETEBUCKET_POLICY:
+		deleteBucketPolicyHandler(w, r, rd)
 	case PUTBUCKET_LOGGING:
 	case PUTBUCKET_NOTIFICATION:
 	case PUTBUCKET_REPLICATION:
 	case PUTBUCKET_TAGGING:
+		putBucketTaggingHandler(w, r, rd)
+	case DELETEBUCKET_TAGGING:
+		deleteBucketTaggingHandler(w, r, rd)
 	case PUTBUCKET_REQUESTPAYMENT:
 	case PUTBUCKET_VERSIONING:
+		putBucketVersioningHandler(w, r, rd)
 	case DELETEOBJECT:
 		deleteObjectHandler(w, r, rd)
+	case POSTBUCKET_DELETE:
+		deleteObjectsHandler(w, r, rd)
 	case GETOBJECT:
 		getObjectHandler(w, r, rd)
 	case GETOBJECT_ACL:
+		getObjectACLHandler(w, r, rd)
 	case GETOBJECT_TORRENT:
 	case HEADOBJECT:
 		headObjectHandler(w, r, rd)
@@ -337,43 +567,125 @@ func mainHandler(w http.ResponseWriter, r *http.Request) {
 	case PUTOBJECT:
 		putObjectHandler(w, r, rd)
 	case PUTOBJECT_ACL:
+		putObjectACLHandler(w, r, rd)
+	case GETOBJECT_TAGGING:
+		getObjectTaggingHandler(w, r, rd)
+	case PUTOBJECT_TAGGING:
+		putObjectTaggingHandler(w, r, rd)
+	case DELETEOBJECT_TAGGING:
+		deleteObjectTaggingHandler(w, r, rd)
 	case PUTOBJECT_COPY:
+		copyObjectHandler(w, r, rd)
+	case POSTOBJECT_UPLOADS:
+		initiateMultipartUploadHandler(w, r, rd)
+	case PUTOBJECT_PART:
+		uploadPartHandler(w, r, rd)
+	case PUTOBJECT_PART_COPY:
+		uploadPartCopyHandler(w, r, rd)
+	case POSTOBJECT_COMPLETEUPLOAD:
+		completeMultipartUploadHandler(w, r, rd)
+	case DELETEOBJECT_UPLOAD:
+		abortMultipartUploadHandler(w, r, rd)
+	case GETOBJECT_PARTS:
+		listPartsHandler(w, r, rd)
+	case GETBUCKET_UPLOADS:
+		listMultipartUploadsHandler(w, r, rd)
+	case OPTIONSOBJECT:
+		optionsObjectHandler(w, r, rd)
 	default:
 		http.Error(w, "Unkown Error", 200)
 	}
 }
 
+// bucketFromHost extracts the bucket name from a virtual-hosted-style Host
+// header (<bucket>.<hostname>:<port>). It reports false for hosts which
+// address the service itself, i.e. the bare hostname, IP literals, localhost
+// and unknown hosts. Those requests are treated as path-style requests.
+func bucketFromHost(h string) (string, bool) {
+	if hst, _, err := net.SplitHostPort(h); err == nil {
+		h = hst
+	}
+
+	h = strings.ToLower(strings.Trim(h, "[]"))
+
+	if net.ParseIP(h) != nil {
+		return "", false
+	}
+
+	for _, suffix := range []string{"." + strings.ToLower(*hostname), ".localhost"} {
+		if strings.HasSuffix(h, suffix) && len(h) > len(suffix) {
+			return strings.TrimSuffix(h, suffix), true
+		}
+	}
+
+	return "", false
+}
+
+// requestPath returns the decoded request path. The escaped form is decoded
+// when present, so that the key is exactly what the client encoded, including
+// escaped slashes, pluses and repeated slashes.
+func requestPath(r *http.Request) (string, *common.Error) {
+	if r.URL.RawPath == "" {
+		return r.URL.Path, nil
+	}
+
+	p, err := url.PathUnescape(r.URL.RawPath)
+
+	if err != nil {
+		return "", &common.ErrInvalidURI
+	}
+
+	return p, nil
+}
+
 func getS3RequestData(r *http.Request) (*S3Request, *common.Error) {
 	s3r := S3Request{}
 
-	pathMethod := false
+	p, awserr := requestPath(r)
 
-	if r.Host == host {
-		pathMethod = true
+	if awserr != nil {
+		return nil, awserr
 	}
 
-	if pathMethod {
-		s := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-		if len(s) == 0 {
-			return nil, &common.ErrInvalidBucketName
-		} else if len(s) == 1 {
-			s3r.bucket = s[0]
-		} else if len(s) == 2 {
-			s3r.bucket = s[0]
-			s3r.object = s[1]
-		} else {
-			return nil, &common.ErrInvalidArgument
-		}
+	p = strings.TrimPrefix(p, "/")
+
+	if bucket, ok := bucketFromHost(r.Host); ok {
+		s3r.bucket = bucket
+		s3r.object = p
+		s3r.virtualHost = true
+	} else if i := strings.Index(p, "/"); i >= 0 {
+		s3r.bucket = p[:i]
+		s3r.object = p[i+1:]
 	} else {
-		//TODO
+		s3r.bucket = p
+	}
+
+	if s3r.bucket != "" {
+		if awserr := common.ValidateBucketName(s3r.bucket); awserr != nil {
+			return nil, awserr
+		}
+	}
+
+	if len(s3r.object) > maxKeyLength {
+		return nil, &common.ErrKeyTooLong
 	}
 
 	s3r.params = r.URL.Query()
+	auth.RemoveQueryParams(s3r.params)
 
-	if s3r.object == "" {
+	if s3r.bucket == "" {
+		if r.Method != "GET" {
+			return nil, &common.ErrMethodNotAllowed
+		}
+		s3r.s3method = GETSERVICE
+	} else if s3r.object == "" {
 		switch r.Method {
 		case "POST":
-			return nil, &common.ErrMethodNotAllowed
+			if s3r.HasParam("delete") {
+				s3r.s3method = POSTBUCKET_DELETE
+			} else {
+				s3r.s3method = POSTOBJECT
+			}
 		case "PUT":
 			if s3r.HasParam("cors") {
 				s3r.s3method = PUTBUCKET_CORS
@@ -389,10 +701,12 @@ func getS3RequestData(r *http.Request) (*S3Request, *common.Error) {
 				s3r.s3method = PUTBUCKET_REQUESTPAYMENT
 			} else if s3r.HasParam("replication") {
 				s3r.s3method = PUTBUCKET_REPLICATION
+			} else if s3r.HasParam("versioning") {
+				s3r.s3method = PUTBUCKET_VERSIONING
 			} else if s3r.HasParam("tagging") {
-				s3r.s3method = DELETEBUCKET_TAGGING
+				s3r.s3method = PUTBUCKET_TAGGING
 			} else if s3r.HasParam("website") {
-				s3r.s3method = DELETEBUCKET_WEBSITE
+				s3r.s3method = PUTBUCKET_WEBSITE
 			} else {
 				s3r.s3method = PUTBUCKET
 			}
@@ -414,8 +728,12 @@ func getS3RequestData(r *http.Request) (*S3Request, *common.Error) {
 			}
 		case "HEAD":
 			s3r.s3method = HEADBUCKET
+		case "OPTIONS":
+			s3r.s3method = OPTIONSOBJECT
 		case "GET":
-			if s3r.HasParam("location") {
+			if s3r.HasParam("uploads") {
+				s3r.s3method = GETBUCKET_UPLOADS
+			} else if s3r.HasParam("location") {
 				s3r.s3method = GETBUCKET_LOCATION
 			} else if s3r.HasParam("acl") {
 				s3r.s3method = GETBUCKET_ACL
@@ -434,13 +752,15 @@ func getS3RequestData(r *http.Request) (*S3Request, *common.Error) {
 			} else if s3r.HasParam("tagging") {
 				s3r.s3method = GETBUCKET_TAGGING
 			} else if s3r.HasParam("versions") {
-				s3r.s3method = GETBUCKET_VERSIONING
+				s3r.s3method = GETBUCKET_OBJECTVERSION
 			} else if s3r.HasParam("requestPayment") {
 				s3r.s3method = GETBUCKET_REQUESTPAYMENT
 			} else if s3r.HasParam("versioning") {
 				s3r.s3method = GETBUCKET_VERSIONING
 			} else if s3r.HasParam("website") {
 				s3r.s3method = GETBUCKET_WEBSITE
+			} else if s3r.Param("list-type") == "2" {
+				s3r.s3method = GETBUCKET_V2
 			} else {
 				s3r.s3method = GETBUCKET
 			}
@@ -450,23 +770,46 @@ func getS3RequestData(r *http.Request) (*S3Request, *common.Error) {
 		case "POST":
 			if s3r.HasParam("restore") {
 				s3r.s3method = POSTOBJECT_RESTORE
+			} else if s3r.HasParam("uploads") {
+				s3r.s3method = POSTOBJECT_UPLOADS
+			} else if s3r.HasParam("uploadId") {
+				s3r.s3method = POSTOBJECT_COMPLETEUPLOAD
 			} else {
 				s3r.s3method = POSTOBJECT
 			}
 		case "PUT":
 			if s3r.HasParam("acl") {
 				s3r.s3method = PUTOBJECT_ACL
+			} else if s3r.HasParam("tagging") {
+				s3r.s3method = PUTOBJECT_TAGGING
+			} else if s3r.HasParam("partNumber") && s3r.HasParam("uploadId") && r.Header.Get("x-amz-copy-source") != "" {
+				s3r.s3method = PUTOBJECT_PART_COPY
+			} else if s3r.HasParam("partNumber") && s3r.HasParam("uploadId") {
+				s3r.s3method = PUTOBJECT_PART
+			} else if r.Header.Get("x-amz-copy-source") != "" {
+				s3r.s3method = PUTOBJECT_COPY
 			} else {
-				// TODO distinguish Put Copy
 				s3r.s3method = PUTOBJECT
 			}
 		case "DELETE":
-			s3r.s3method = DELETEOBJECT
+			if s3r.HasParam("uploadId") {
+				s3r.s3method = DELETEOBJECT_UPLOAD
+			} else if s3r.HasParam("tagging") {
+				s3r.s3method = DELETEOBJECT_TAGGING
+			} else {
+				s3r.s3method = DELETEOBJECT
+			}
 		case "HEAD":
 			s3r.s3method = HEADOBJECT
+		case "OPTIONS":
+			s3r.s3method = OPTIONSOBJECT
 		case "GET":
 			if s3r.HasParam("acl") {
 				s3r.s3method = GETOBJECT_ACL
+			} else if s3r.HasParam("tagging") {
+				s3r.s3method = GETOBJECT_TAGGING
+			} else if s3r.HasParam("uploadId") {
+				s3r.s3method = GETOBJECT_PARTS
 			} else {
 				s3r.s3method = GETOBJECT
 			}
@@ -489,25 +832,90 @@ func getS3RequestData(r *http.Request) (*S3Request, *common.Error) {
 	return &s3r, nil
 }
 
+// verifyContentMD5 wraps the body of r to compare it with the Content-MD5
+// header, if there is one. Handlers report the mismatch as ErrBadDigest when
+// they read the end of the body, before anything is stored.
+func verifyContentMD5(r *http.Request, rd *S3Request) *common.Error {
+	if _, ok := r.Header["Content-Md5"]; !ok {
+		return nil
+	}
+
+	sum, err := base64.StdEncoding.DecodeString(rd.ContentMD5)
+
+	if err != nil || len(sum) != md5.Size {
+		return &common.ErrInvalidDigest
+	}
+
+	r.Body = common.NewDigestReader(r.Body, md5.New(), sum, &common.ErrBadDigest)
+
+	return nil
+}
+
 func resetHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("=====RESET=====")
 	backend.Reset()
 }
 
-func CreateMux() *http.ServeMux {
-	mux := http.NewServeMux()
-
-	mux.HandleFunc("/", mainHandler)
-	mux.HandleFunc("/_internal/reset", resetHandler)
+// CreateMux returns the handler of the server. Requests are not passed
+// through a http.ServeMux, as it cleans the path and redirects requests for
+// keys containing "//", "./" or "../".
+func CreateMux() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_internal/reset" {
+			resetHandler(w, r)
+			return
+		}
 
-	return mux
+		mainHandler(w, r)
+	})
 }
 
 func LaunchServer(_hostname string, _port string) error {
 	host = _hostname + ":" + _port
 	hostname = &_hostname
 	port = &_port
-	backend = inMemory.NewS3Backend()
+
+	switch *backendName {
+	case "memory":
+		backend = inMemory.NewS3Backend()
+	case "disk":
+		backend = s3disk.NewS3Backend(s3disk.Options{BasePath: *basePath})
+	default:
+		return fmt.Errorf("Unknown backend %q", *backendName)
+	}
+
+	var c auth.Credentials
+	var err error
+
+	switch {
+	case *credentials != "" && *credentialsFile != "":
+		return fmt.Errorf("Use either -credentials or -credentials-file")
+	case *credentials != "":
+		c, err = auth.ParseCredentials(*credentials)
+	case *credentialsFile != "":
+		c, err = auth.NewFileCredentials(*credentialsFile)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if c != nil {
+		accept, err := auth.ParseSignatureVersions(*signature)
+
+		if err != nil {
+			return err
+		}
+
+		verifier = &auth.Verifier{Credentials: c, Accept: accept, Region: *region}
+	}
+
+	if *lifecycleInterval <= 0 {
+		return fmt.Errorf("The lifecycle interval must be positive, got %v", *lifecycleInterval)
+	}
+
+	worker := &common.LifecycleWorker{Backend: backend, Interval: *lifecycleInterval}
+	go worker.Run(nil)
 
 	fmt.Printf("Launching S3Server on port %v\n", *port)
 
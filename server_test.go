@@ -0,0 +1,1262 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/base64"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0x434D53/s3server/auth"
+	"github.com/0x434D53/s3server/common"
+	"github.com/0x434D53/s3server/s3backend/inMemory"
+)
+
+func TestBucketFromHost(t *testing.T) {
+	*hostname = "test.dev"
+
+	tests := []struct {
+		host   string
+		bucket string
+		ok     bool
+	}{
+		{"test.dev:10001", "", false},
+		{"test.dev", "", false},
+		{"mybucket.test.dev:10001", "mybucket", true},
+		{"MyBucket.Test.Dev:10001", "mybucket", true},
+		{"my.dotted.bucket.test.dev:10001", "my.dotted.bucket", true},
+		{"127.0.0.1:10001", "", false},
+		{"[::1]:10001", "", false},
+		{"localhost:10001", "", false},
+		{"mybucket.localhost:10001", "mybucket", true},
+		{"example.com", "", false},
+	}
+
+	for _, test := range tests {
+		bucket, ok := bucketFromHost(test.host)
+
+		if bucket != test.bucket || ok != test.ok {
+			t.Errorf("%s: expected (%q, %v), got (%q, %v)", test.host, test.bucket, test.ok, bucket, ok)
+		}
+	}
+}
+
+func TestGetS3RequestData(t *testing.T) {
+	*hostname = "test.dev"
+
+	tests := []struct {
+		method string
+		url    string
+		bucket string
+		object string
+		s3m    S3METHOD
+	}{
+		{"GET", "http://test.dev:10001/", "", "", GETSERVICE},
+		{"GET", "http://test.dev:10001/bucket", "bucket", "", GETBUCKET},
+		{"GET", "http://test.dev:10001/bucket/key", "bucket", "key", GETOBJECT},
+		{"GET", "http://bucket.test.dev:10001/", "bucket", "", GETBUCKET},
+		{"PUT", "http://bucket.test.dev:10001/", "bucket", "", PUTBUCKET},
+		{"GET", "http://bucket.test.dev:10001/?acl", "bucket", "", GETBUCKET_ACL},
+		{"PUT", "http://bucket.test.dev:10001/key", "bucket", "key", PUTOBJECT},
+		{"DELETE", "http://bucket.test.dev:10001/key", "bucket", "key", DELETEOBJECT},
+		{"HEAD", "http://127.0.0.1:10001/bucket/key", "bucket", "key", HEADOBJECT},
+		{"GET", "http://localhost:10001/bucket", "bucket", "", GETBUCKET},
+		{"GET", "http://bucket.localhost:10001/key", "bucket", "key", GETOBJECT},
+		{"POST", "http://bucket.test.dev:10001/key?uploads", "bucket", "key", POSTOBJECT_UPLOADS},
+		{"PUT", "http://bucket.test.dev:10001/key?partNumber=1&uploadId=abc", "bucket", "key", PUTOBJECT_PART},
+		{"POST", "http://bucket.test.dev:10001/key?uploadId=abc", "bucket", "key", POSTOBJECT_COMPLETEUPLOAD},
+		{"DELETE", "http://bucket.test.dev:10001/key?uploadId=abc", "bucket", "key", DELETEOBJECT_UPLOAD},
+		{"GET", "http://bucket.test.dev:10001/key?uploadId=abc", "bucket", "key", GETOBJECT_PARTS},
+		{"GET", "http://bucket.test.dev:10001/?uploads", "bucket", "", GETBUCKET_UPLOADS},
+		{"GET", "http://bucket.test.dev:10001/?list-type=2", "bucket", "", GETBUCKET_V2},
+		{"GET", "http://bucket.test.dev:10001/?versions", "bucket", "", GETBUCKET_OBJECTVERSION},
+		{"GET", "http://bucket.test.dev:10001/?versioning", "bucket", "", GETBUCKET_VERSIONING},
+		{"PUT", "http://bucket.test.dev:10001/?versioning", "bucket", "", PUTBUCKET_VERSIONING},
+		{"GET", "http://bucket.test.dev:10001/key?versionId=abc", "bucket", "key", GETOBJECT},
+		{"PUT", "http://bucket.test.dev:10001/copy", "bucket", "copy", PUTOBJECT_COPY},
+		{"PUT", "http://bucket.test.dev:10001/copy?partNumber=1&uploadId=abc", "bucket", "copy", PUTOBJECT_PART_COPY},
+		{"POST", "http://bucket.test.dev:10001/?delete", "bucket", "", POSTBUCKET_DELETE},
+		{"POST", "http://bucket.test.dev:10001/", "bucket", "", POSTOBJECT},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.url, nil)
+
+		if test.s3m == PUTOBJECT_COPY || test.s3m == PUTOBJECT_PART_COPY {
+			r.Header.Set("x-amz-copy-source", "/bucket/key")
+		}
+
+		rd, err := getS3RequestData(r)
+
+		if err != nil {
+			t.Errorf("%s %s: unexpected error %v", test.method, test.url, err)
+			continue
+		}
+
+		if rd.bucket != test.bucket || rd.object != test.object || rd.s3method != test.s3m {
+			t.Errorf("%s %s: expected %s/%s (%v), got %s/%s (%v)", test.method, test.url, test.bucket, test.object, test.s3m, rd.bucket, rd.object, rd.s3method)
+		}
+	}
+}
+
+func TestGetS3RequestDataKeys(t *testing.T) {
+	*hostname = "test.dev"
+
+	tests := []struct {
+		url    string
+		bucket string
+		object string
+	}{
+		{"http://test.dev:10001/bucket/photos/2016/a.jpg", "bucket", "photos/2016/a.jpg"},
+		{"http://bucket.test.dev:10001/photos/2016/a.jpg", "bucket", "photos/2016/a.jpg"},
+		{"http://test.dev:10001/bucket/with%20space", "bucket", "with space"},
+		{"http://test.dev:10001/bucket/a+b", "bucket", "a+b"},
+		{"http://test.dev:10001/bucket/a%2Bb", "bucket", "a+b"},
+		{"http://test.dev:10001/bucket/a%2Fb", "bucket", "a/b"},
+		{"http://test.dev:10001/bucket/%C3%BCn%C3%AFc%C3%B6d%C3%A9", "bucket", "ünïcödé"},
+		{"http://test.dev:10001/bucket/folder/", "bucket", "folder/"},
+		{"http://test.dev:10001/bucket/a//b", "bucket", "a//b"},
+		{"http://bucket.test.dev:10001//leading", "bucket", "/leading"},
+		{"http://test.dev:10001/bucket/", "bucket", ""},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.url, nil)
+
+		rd, err := getS3RequestData(r)
+
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.url, err)
+			continue
+		}
+
+		if rd.bucket != test.bucket || rd.object != test.object {
+			t.Errorf("%s: expected %q/%q, got %q/%q", test.url, test.bucket, test.object, rd.bucket, rd.object)
+		}
+	}
+}
+
+func TestGetS3RequestDataKeyTooLong(t *testing.T) {
+	*hostname = "test.dev"
+
+	r := httptest.NewRequest("GET", "http://test.dev:10001/bucket/"+strings.Repeat("a", maxKeyLength+1), nil)
+
+	if _, err := getS3RequestData(r); err == nil || err.Code != "KeyTooLong" {
+		t.Errorf("Expected KeyTooLong, got %v", err)
+	}
+}
+
+func TestGetS3RequestDataServiceMethodNotAllowed(t *testing.T) {
+	*hostname = "test.dev"
+
+	r := httptest.NewRequest("PUT", "http://test.dev:10001/", nil)
+
+	if _, err := getS3RequestData(r); err == nil || err.Code != "MethodNotAllowed" {
+		t.Errorf("Expected MethodNotAllowed, got %v", err)
+	}
+}
+
+func TestGetS3RequestDataPresigned(t *testing.T) {
+	*hostname = "test.dev"
+
+	tests := []struct {
+		method string
+		url    string
+		s3m    S3METHOD
+	}{
+		{"GET", "http://bucket.test.dev:10001/key?AWSAccessKeyId=key&Expires=1175139620&Signature=abc", GETOBJECT},
+		{"PUT", "http://bucket.test.dev:10001/key?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=key&X-Amz-Date=20130524T000000Z&X-Amz-Expires=60&X-Amz-SignedHeaders=host&X-Amz-Signature=abc", PUTOBJECT},
+		{"GET", "http://bucket.test.dev:10001/?acl&X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Signature=abc", GETBUCKET_ACL},
+	}
+
+	for _, test := range tests {
+		rd, err := getS3RequestData(httptest.NewRequest(test.method, test.url, nil))
+
+		if err != nil {
+			t.Errorf("%s %s: unexpected error %v", test.method, test.url, err)
+			continue
+		}
+
+		if rd.s3method != test.s3m {
+			t.Errorf("%s %s: expected %s, got %s", test.method, test.url, test.s3m, rd.s3method)
+		}
+
+		for _, name := range []string{"AWSAccessKeyId", "Expires", "Signature", "X-Amz-Algorithm", "X-Amz-Signature"} {
+			if rd.HasParam(name) {
+				t.Errorf("%s %s: expected %s to be removed", test.method, test.url, name)
+			}
+		}
+	}
+}
+
+func TestMainHandlerAuthentication(t *testing.T) {
+	*hostname = "test.dev"
+	backend = inMemory.NewS3Backend()
+	verifier = &auth.Verifier{Credentials: auth.NewMemoryCredentials(&auth.Account{AccessKey: "key", SecretKey: "secret"})}
+	defer func() { verifier = nil }()
+
+	w := httptest.NewRecorder()
+	mainHandler(w, httptest.NewRequest("GET", "http://test.dev:10001/", nil))
+
+	if w.Code != 403 || !strings.Contains(w.Body.String(), "AccessDenied") {
+		t.Errorf("Expected AccessDenied for an anonymous request, got %d %s", w.Code, w.Body.String())
+	}
+
+	verifier = nil
+	w = httptest.NewRecorder()
+	mainHandler(w, httptest.NewRequest("GET", "http://test.dev:10001/", nil))
+
+	if w.Code != 200 || !strings.Contains(w.Body.String(), "<ID>"+localPrincipal.ID+"</ID>") {
+		t.Errorf("Expected requests to pass without authentication, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestMainHandlerInvalidBucketName(t *testing.T) {
+	*hostname = "test.dev"
+	backend = inMemory.NewS3Backend()
+	verifier = nil
+
+	for _, url := range []string{"/./key", "/../key", "/..", "/Bucket/key", "/127.0.0.1"} {
+		w := httptest.NewRecorder()
+		mainHandler(w, httptest.NewRequest("GET", "http://test.dev:10001"+url, nil))
+
+		if w.Code != 400 || !strings.Contains(w.Body.String(), "InvalidBucketName") {
+			t.Errorf("%s: expected InvalidBucketName, got %d %s", url, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestMainHandlerACL(t *testing.T) {
+	*hostname = "test.dev"
+	backend = inMemory.NewS3Backend()
+	verifier = &auth.Verifier{Credentials: auth.NewMemoryCredentials(&auth.Account{AccessKey: "key", SecretKey: "secret"})}
+	defer func() { verifier = nil }()
+
+	owner := localPrincipal.Owner()
+	public, _ := common.PublicRead.Grants(owner, owner)
+	backend.PutBucket("bucket", nil, localPrincipal)
+	backend.PutObject("bucket", "public", bytes.NewReader([]byte("public")), 6, nil, public, nil, nil, localPrincipal)
+	backend.PutObject("bucket", "private", bytes.NewReader([]byte("private")), 7, nil, nil, nil, nil, localPrincipal)
+
+	tests := []struct {
+		method string
+		url    string
+		code   int
+	}{
+		{"GET", "/bucket/public", 200},
+		{"GET", "/bucket/private", 403},
+		{"GET", "/bucket/missing", 403},
+		{"GET", "/bucket/public?acl", 403},
+		{"GET", "/bucket", 403},
+		{"PUT", "/bucket/key", 403},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		mainHandler(w, httptest.NewRequest(test.method, "http://test.dev:10001"+test.url, nil))
+
+		if w.Code != test.code {
+			t.Errorf("%s %s: expected %d, got %d %s", test.method, test.url, test.code, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRequestGrants(t *testing.T) {
+	owner := common.Owner{ID: "owner"}
+	r := httptest.NewRequest("PUT", "/bucket/key", nil)
+	r.Header.Set("x-amz-grant-read", `id="bob", uri="`+common.AllUsers+`"`)
+	grants, awserr := requestGrants(r, owner, owner)
+
+	if awserr != nil || len(grants) != 2 || grants[0].Grantee.ID != "bob" || grants[1].Grantee.Type != common.GranteeGroup {
+		t.Errorf("Unexpected grants %+v, %v", grants, awserr)
+	}
+
+	r.Header.Set("x-amz-acl", "public-read")
+
+	if _, awserr := requestGrants(r, owner, owner); awserr != &common.ErrCannedACLWithGrants {
+		t.Errorf("Expected ErrCannedACLWithGrants, got %v", awserr)
+	}
+
+	r = httptest.NewRequest("PUT", "/bucket/key", nil)
+
+	if grants, awserr := requestGrants(r, owner, owner); grants != nil || awserr != nil {
+		t.Errorf("Expected no grants, got %+v, %v", grants, awserr)
+	}
+}
+
+func TestMainHandlerPolicy(t *testing.T) {
+	*hostname = "test.dev"
+	backend = inMemory.NewS3Backend()
+	verifier = nil
+
+	owner := localPrincipal.Owner()
+	public, _ := common.PublicRead.Grants(owner, owner)
+	backend.PutBucket("bucket", nil, localPrincipal)
+	backend.PutObject("bucket", "public/a", bytes.NewReader([]byte("a")), 1, nil, nil, nil, nil, localPrincipal)
+	backend.PutObject("bucket", "secret/b", bytes.NewReader([]byte("b")), 1, nil, public, nil, nil, localPrincipal)
+
+	policy := `{"Version": "2012-10-17", "Statement": [
+		{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/public/*"},
+		{"Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::bucket/secret/*", "Condition": {"Bool": {"aws:SecureTransport": "false"}}}
+	]}`
+
+	tests := []struct {
+		method string
+		url    string
+		body   string
+		code   int
+	}{
+		{"GET", "/bucket?policy", "", 404},
+		{"PUT", "/bucket?policy", "{}", 400},
+		{"PUT", "/bucket?policy", `{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::other/*"}]}`, 400},
+		{"PUT", "/bucket?policy", policy, 204},
+		{"GET", "/bucket?policy", "", 200},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		mainHandler(w, httptest.NewRequest(test.method, "http://test.dev:10001"+test.url, strings.NewReader(test.body)))
+
+		if w.Code != test.code {
+			t.Errorf("%s %s: expected %d, got %d %s", test.method, test.url, test.code, w.Code, w.Body.String())
+		}
+	}
+
+	verifier = &auth.Verifier{Credentials: auth.NewMemoryCredentials(&auth.Account{AccessKey: "key", SecretKey: "secret"})}
+	defer func() { verifier = nil }()
+
+	tests = []struct {
+		method string
+		url    string
+		body   string
+		code   int
+	}{
+		{"GET", "/bucket/public/a", "", 200},
+		{"GET", "/bucket/public/missing", "", 403},
+		{"PUT", "/bucket/public/a", "", 403},
+		{"GET", "/bucket/secret/b", "", 403},
+		{"GET", "/bucket?policy", "", 403},
+		{"DELETE", "/bucket?policy", "", 403},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		mainHandler(w, httptest.NewRequest(test.method, "http://test.dev:10001"+test.url, nil))
+
+		if w.Code != test.code {
+			t.Errorf("anonymous %s %s: expected %d, got %d %s", test.method, test.url, test.code, w.Code, w.Body.String())
+		}
+	}
+
+	secure := httptest.NewRequest("GET", "https://test.dev:10001/bucket/secret/b", nil)
+	w := httptest.NewRecorder()
+	mainHandler(w, secure)
+
+	if w.Code != 200 {
+		t.Errorf("Expected the public object to be readable over TLS, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestMainHandlerCORS(t *testing.T) {
+	*hostname = "test.dev"
+	backend = inMemory.NewS3Backend()
+	verifier = nil
+	backend.PutBucket("bucket", nil, localPrincipal)
+
+	do := func(method string, url string, body string, headers map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "http://test.dev:10001"+url, strings.NewReader(body))
+
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		mainHandler(w, r)
+
+		return w
+	}
+
+	preflight := map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "content-type, x-amz-acl"}
+
+	if w := do("OPTIONS", "/bucket/key", "", preflight); w.Code != 403 || !strings.Contains(w.Body.String(), "CORS is not enabled") {
+		t.Errorf("Expected preflight requests to be denied without configuration, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("GET", "/bucket?cors", "", nil); w.Code != 404 || !strings.Contains(w.Body.String(), "NoSuchCORSConfiguration") {
+		t.Errorf("Expected NoSuchCORSConfiguration, got %d %s", w.Code, w.Body.String())
+	}
+
+	cors := `<CORSConfiguration>
+		<CORSRule><AllowedOrigin>https://*.example.com</AllowedOrigin><AllowedMethod>PUT</AllowedMethod><AllowedMethod>GET</AllowedMethod>
+			<AllowedHeader>*</AllowedHeader><ExposeHeader>ETag</ExposeHeader><MaxAgeSeconds>3000</MaxAgeSeconds></CORSRule>
+	</CORSConfiguration>`
+
+	if w := do("PUT", "/bucket?cors", "<CORSConfiguration><CORSRule><AllowedOrigin>*</AllowedOrigin><AllowedMethod>PATCH</AllowedMethod></CORSRule></CORSConfiguration>", nil); w.Code != 400 {
+		t.Errorf("Expected an unsupported method to be rejected, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("PUT", "/bucket?cors", cors, nil); w.Code != 200 {
+		t.Fatalf("Expected the configuration to be stored, got %d %s", w.Code, w.Body.String())
+	}
+
+	w := do("OPTIONS", "/bucket/key", "", preflight)
+	h := w.Header()
+
+	if w.Code != 200 || h.Get("Access-Control-Allow-Origin") != "https://app.example.com" || h.Get("Access-Control-Allow-Methods") != "PUT, GET" ||
+		h.Get("Access-Control-Allow-Headers") != "content-type, x-amz-acl" || h.Get("Access-Control-Max-Age") != "3000" || h.Get("Access-Control-Expose-Headers") != "ETag" {
+		t.Errorf("Unexpected preflight response %d %v", w.Code, h)
+	}
+
+	if w := do("OPTIONS", "/bucket/key", "", map[string]string{"Origin": "https://evil.com", "Access-Control-Request-Method": "PUT"}); w.Code != 403 {
+		t.Errorf("Expected an unknown origin to be denied, got %d", w.Code)
+	}
+
+	if w := do("OPTIONS", "/bucket/key", "", map[string]string{"Access-Control-Request-Method": "PUT"}); w.Code != 400 {
+		t.Errorf("Expected a preflight request without origin to be rejected, got %d", w.Code)
+	}
+
+	if w := do("PUT", "/bucket/key", "data", map[string]string{"Origin": "https://app.example.com"}); w.Code != 200 || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("Expected CORS headers on an allowed request, got %d %v", w.Code, w.Header())
+	}
+
+	if w := do("DELETE", "/bucket/key", "", map[string]string{"Origin": "https://app.example.com"}); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS headers for a method which is not allowed, got %v", w.Header())
+	}
+
+	if w := do("DELETE", "/bucket?cors", "", nil); w.Code != 204 {
+		t.Errorf("Expected the configuration to be deleted, got %d", w.Code)
+	}
+}
+
+func TestMainHandlerLifecycle(t *testing.T) {
+	*hostname = "test.dev"
+	backend = inMemory.NewS3Backend()
+	verifier = nil
+	backend.PutBucket("bucket", nil, localPrincipal)
+	backend.PutObject("bucket", "logs/a", bytes.NewReader([]byte("a")), 1, nil, nil, nil, nil, localPrincipal)
+
+	do := func(method string, url string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mainHandler(w, httptest.NewRequest(method, "http://test.dev:10001"+url, strings.NewReader(body)))
+
+		return w
+	}
+
+	if w := do("GET", "/bucket?lifecycle", ""); w.Code != 404 || !strings.Contains(w.Body.String(), "NoSuchLifecycleConfiguration") {
+		t.Errorf("Expected NoSuchLifecycleConfiguration, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("PUT", "/bucket?lifecycle", "<LifecycleConfiguration><Rule><Status>Enabled</Status></Rule></LifecycleConfiguration>"); w.Code != 400 {
+		t.Errorf("Expected a rule without action to be rejected, got %d", w.Code)
+	}
+
+	lifecycle := `<LifecycleConfiguration><Rule><ID>logs</ID><Filter><Prefix>logs/</Prefix></Filter><Status>Enabled</Status>
+		<Expiration><Days>7</Days></Expiration></Rule></LifecycleConfiguration>`
+
+	if w := do("PUT", "/bucket?lifecycle", lifecycle); w.Code != 200 {
+		t.Fatalf("Expected the configuration to be stored, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("GET", "/bucket?lifecycle", ""); w.Code != 200 || !strings.Contains(w.Body.String(), "<Days>7</Days>") {
+		t.Errorf("Expected the configuration, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("HEAD", "/bucket/logs/a", ""); !strings.HasSuffix(w.Header().Get("x-amz-expiration"), `rule-id="logs"`) {
+		t.Errorf("Expected x-amz-expiration, got %v", w.Header())
+	}
+
+	if w := do("DELETE", "/bucket?lifecycle", ""); w.Code != 204 {
+		t.Errorf("Expected the configuration to be deleted, got %d", w.Code)
+	}
+
+	if w := do("GET", "/bucket/logs/a", ""); w.Header().Get("x-amz-expiration") != "" {
+		t.Errorf("Expected no x-amz-expiration without configuration, got %v", w.Header())
+	}
+}
+
+func TestMainHandlerTagging(t *testing.T) {
+	*hostname = "test.dev"
+	backend = inMemory.NewS3Backend()
+	verifier = nil
+	backend.PutBucket("bucket", nil, localPrincipal)
+
+	do := func(method string, url string, body string, header string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, "http://test.dev:10001"+url, strings.NewReader(body))
+
+		if header != "" {
+			r.Header.Set("x-amz-tagging", header)
+		}
+
+		mainHandler(w, r)
+
+		return w
+	}
+
+	tagging := "<Tagging><TagSet><Tag><Key>project</Key><Value>s3server</Value></Tag></TagSet></Tagging>"
+
+	if w := do("PUT", "/bucket?tagging", tagging, ""); w.Code != 204 {
+		t.Fatalf("Expected the bucket tags to be stored, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("GET", "/bucket?tagging", "", ""); w.Code != 200 || !strings.Contains(w.Body.String(), "<Key>project</Key><Value>s3server</Value>") {
+		t.Errorf("Expected the bucket tags, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("DELETE", "/bucket?tagging", "", ""); w.Code != 204 {
+		t.Errorf("Expected the bucket tags to be deleted, got %d", w.Code)
+	}
+
+	if w := do("GET", "/bucket?tagging", "", ""); w.Code != 404 || !strings.Contains(w.Body.String(), "NoSuchTagSet") {
+		t.Errorf("Expected NoSuchTagSet, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("PUT", "/bucket/key", "contents", "a=1&a=2"); w.Code != 400 || !strings.Contains(w.Body.String(), "InvalidTag") {
+		t.Errorf("Expected duplicate keys to be rejected, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("PUT", "/bucket/key", "contents", "state=new&owner=team%20a"); w.Code != 200 {
+		t.Fatalf("Expected the object to be stored, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("GET", "/bucket/key", "", ""); w.Header().Get("x-amz-tagging-count") != "2" {
+		t.Errorf("Expected x-amz-tagging-count 2, got %v", w.Header())
+	}
+
+	if w := do("GET", "/bucket/key?tagging", "", ""); w.Code != 200 || !strings.Contains(w.Body.String(), "<Key>owner</Key><Value>team a</Value>") {
+		t.Errorf("Expected the object tags, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("PUT", "/bucket/key?tagging", tagging, ""); w.Code != 200 || w.Header()["X-Amz-Version-Id"] != nil {
+		t.Errorf("Expected the object tags to be replaced, got %d %v", w.Code, w.Header())
+	}
+
+	if w := do("GET", "/bucket/key", "", ""); w.Body.String() != "contents" || w.Header().Get("x-amz-tagging-count") != "1" {
+		t.Errorf("Expected the object with one tag, got %v %s", w.Header(), w.Body.String())
+	}
+
+	if w := do("DELETE", "/bucket/key?tagging", "", ""); w.Code != 204 {
+		t.Errorf("Expected the object tags to be deleted, got %d", w.Code)
+	}
+
+	if w := do("GET", "/bucket/key?tagging", "", ""); w.Code != 200 || !strings.Contains(w.Body.String(), "<TagSet></TagSet>") {
+		t.Errorf("Expected an empty tag set, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("GET", "/bucket/key", "", ""); w.Header().Get("x-amz-tagging-count") != "" {
+		t.Errorf("Expected no x-amz-tagging-count, got %v", w.Header())
+	}
+
+	backend.PutBucketVersioning("bucket", common.VersioningSuspended, localPrincipal)
+
+	if w := do("PUT", "/bucket/key?tagging", tagging, ""); w.Code != 200 || w.Header().Get("x-amz-version-id") != common.NullVersionID {
+		t.Errorf("Expected the null version ID once versioning was enabled, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestMainHandlerMetadata(t *testing.T) {
+	*hostname = "test.dev"
+	backend = inMemory.NewS3Backend()
+	verifier = nil
+	backend.PutBucket("bucket", nil, localPrincipal)
+
+	do := func(method string, url string, header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, "http://test.dev:10001"+url, strings.NewReader("contents"))
+
+		for k, v := range header {
+			r.Header[k] = v
+		}
+
+		mainHandler(w, r)
+
+		return w
+	}
+
+	header := http.Header{
+		"Cache-Control":       {"no-cache"},
+		"Content-Disposition": {"attachment"},
+		"X-Amz-Meta-Color":    {"blue"},
+		"X-Amz-Storage-Class": {"STANDARD_IA"},
+	}
+
+	if w := do("PUT", "/bucket/key", header); w.Code != 200 {
+		t.Fatalf("Expected the object to be stored, got %d %s", w.Code, w.Body.String())
+	}
+
+	for _, method := range []string{"GET", "HEAD"} {
+		h := do(method, "/bucket/key", nil).Header()
+
+		if h.Get("Cache-Control") != "no-cache" || h.Get("Content-Disposition") != "attachment" || h.Get("x-amz-meta-color") != "blue" ||
+			h.Get("x-amz-storage-class") != "STANDARD_IA" || h.Get("Content-Type") != defaultContentType || h.Get("Last-Modified") == "" {
+			t.Errorf("%s: expected the metadata headers, got %v", method, h)
+		}
+
+		for _, name := range []string{"Date", "x-amz-delete-marker", "x-amz-id-2", "x-amz-request-id", "x-amz-version-id"} {
+			if _, ok := h[http.CanonicalHeaderKey(name)]; ok {
+				t.Errorf("%s: expected no %s header, got %v", method, name, h)
+			}
+		}
+	}
+
+	if w := do("GET", "/bucket", nil); !strings.Contains(w.Body.String(), "<StorageClass>STANDARD_IA</StorageClass>") {
+		t.Errorf("Expected the storage class in the listing, got %s", w.Body.String())
+	}
+
+	if w := do("PUT", "/bucket/key", http.Header{"X-Amz-Storage-Class": {"COLD"}}); w.Code != 400 || !strings.Contains(w.Body.String(), "InvalidStorageClass") {
+		t.Errorf("Expected InvalidStorageClass, got %d %s", w.Code, w.Body.String())
+	}
+
+	large := http.Header{"X-Amz-Meta-Large": {strings.Repeat("v", common.MaxMetadataSize)}}
+
+	if w := do("PUT", "/bucket/key", large); w.Code != 400 || !strings.Contains(w.Body.String(), "MetadataTooLarge") {
+		t.Errorf("Expected MetadataTooLarge, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestMainHandlerContentMD5(t *testing.T) {
+	*hostname = "test.dev"
+	backend = inMemory.NewS3Backend()
+	verifier = nil
+	backend.PutBucket("bucket", nil, localPrincipal)
+
+	do := func(method string, url string, body string, md5 string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, "http://test.dev:10001"+url, strings.NewReader(body))
+
+		if md5 != "" {
+			r.Header.Set("Content-MD5", md5)
+		}
+
+		mainHandler(w, r)
+
+		return w
+	}
+
+	// MD5 of "contents"
+	sum := "mL99jBV4Two9YyBEQeHiqg=="
+	etag := `"98bf7d8c15784f0a3d63204441e1e2aa"`
+
+	if w := do("PUT", "/bucket/key", "contents", sum); w.Code != 200 || w.Header().Get("ETag") != etag {
+		t.Fatalf("Expected the object to be stored with ETag %s, got %d %v", etag, w.Code, w.Header())
+	}
+
+	for _, method := range []string{"GET", "HEAD"} {
+		if w := do(method, "/bucket/key", "", ""); w.Header().Get("ETag") != etag {
+			t.Errorf("%s: expected ETag %s, got %v", method, etag, w.Header())
+		}
+	}
+
+	if w := do("GET", "/bucket", "", ""); !strings.Contains(w.Body.String(), "<ETag>&#34;98bf7d8c15784f0a3d63204441e1e2aa&#34;</ETag>") {
+		t.Errorf("Expected the ETag in the listing, got %s", w.Body.String())
+	}
+
+	if w := do("PUT", "/bucket/key", "changed", sum); w.Code != 400 || !strings.Contains(w.Body.String(), "BadDigest") {
+		t.Errorf("Expected BadDigest, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("GET", "/bucket/key", "", ""); w.Body.String() != "contents" {
+		t.Errorf("A body with a bad digest must not be stored, got %s", w.Body.String())
+	}
+
+	if w := do("PUT", "/bucket/key", "contents", "not base64"); w.Code != 400 || !strings.Contains(w.Body.String(), "InvalidDigest") {
+		t.Errorf("Expected InvalidDigest, got %d %s", w.Code, w.Body.String())
+	}
+
+	tagging := "<Tagging><TagSet></TagSet></Tagging>"
+
+	if w := do("PUT", "/bucket?tagging", tagging, sum); w.Code != 400 || !strings.Contains(w.Body.String(), "BadDigest") {
+		t.Errorf("Expected BadDigest for the configuration, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestMainHandlerRange(t *testing.T) {
+	*hostname = "test.dev"
+	backend = inMemory.NewS3Backend()
+	verifier = nil
+	backend.PutBucket("bucket", nil, localPrincipal)
+	backend.PutObject("bucket", "key", strings.NewReader("0123456789"), 10, nil, nil, nil, nil, localPrincipal)
+
+	do := func(method string, url string, rangeHeader string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, "http://test.dev:10001"+url, nil)
+
+		if rangeHeader != "" {
+			r.Header.Set("Range", rangeHeader)
+		}
+
+		mainHandler(w, r)
+
+		return w
+	}
+
+	tests := []struct {
+		url          string
+		rangeHeader  string
+		code         int
+		body         string
+		contentRange string
+	}{
+		{"/bucket/key", "", 200, "0123456789", ""},
+		{"/bucket/key", "bytes=2-4", 206, "234", "bytes 2-4/10"},
+		{"/bucket/key", "bytes=7-", 206, "789", "bytes 7-9/10"},
+		{"/bucket/key", "bytes=-2", 206, "89", "bytes 8-9/10"},
+		{"/bucket/key", "bytes=5-4", 200, "0123456789", ""},
+		{"/bucket/key", "bytes=10-", 416, "", "bytes */10"},
+		{"/bucket/key?partNumber=1", "", 206, "0123456789", "bytes 0-9/10"},
+		{"/bucket/key?partNumber=2", "", 416, "", "bytes */10"},
+		{"/bucket/key?partNumber=1", "bytes=0-1", 400, "", ""},
+	}
+
+	for _, test := range tests {
+		w := do("GET", test.url, test.rangeHeader)
+
+		if w.Code != test.code || w.Header().Get("Content-Range") != test.contentRange || w.Code < 300 && w.Body.String() != test.body {
+			t.Errorf("%s %s: expected %d %q %q, got %d %q %s", test.url, test.rangeHeader, test.code, test.contentRange, test.body, w.Code, w.Header().Get("Content-Range"), w.Body.String())
+		}
+	}
+
+	if w := do("HEAD", "/bucket/key", "bytes=0-3"); w.Code != 206 || w.Header().Get("Content-Length") != "4" || w.Header().Get("Accept-Ranges") != "bytes" {
+		t.Errorf("Expected the length of the range, got %d %v", w.Code, w.Header())
+	}
+
+	if w := do("GET", "/bucket/key", "bytes=0-1"); w.Header().Get("Content-Length") != "2" {
+		t.Errorf("Expected the length of the range, got %v", w.Header())
+	}
+}
+
+func TestMainHandlerConditionalRead(t *testing.T) {
+	*hostname = "test.dev"
+	backend = inMemory.NewS3Backend()
+	verifier = nil
+	backend.PutBucket("bucket", nil, localPrincipal)
+	info, _ := backend.PutObject("bucket", "key", strings.NewReader("contents"), 8, nil, nil, nil, nil, localPrincipal)
+
+	do := func(method string, header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, "http://test.dev:10001/bucket/key", nil)
+		r.Header = header
+		mainHandler(w, r)
+
+		return w
+	}
+
+	modified := info.LastModified.UTC().Format(http.TimeFormat)
+	earlier := info.LastModified.Add(-time.Hour).UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		header http.Header
+		code   int
+	}{
+		{http.Header{"If-Match": {info.ETag}}, 200},
+		{http.Header{"If-Match": {`"other"`}}, 412},
+		{http.Header{"If-None-Match": {info.ETag}}, 304},
+		{http.Header{"If-None-Match": {`"other"`}}, 200},
+		{http.Header{"If-Modified-Since": {modified}}, 304},
+		{http.Header{"If-Modified-Since": {earlier}}, 200},
+		{http.Header{"If-Modified-Since": {"yesterday"}}, 200},
+		{http.Header{"If-Unmodified-Since": {earlier}}, 412},
+		{http.Header{"If-Match": {info.ETag}, "If-Unmodified-Since": {earlier}}, 200},
+		{http.Header{"If-None-Match": {`"other"`}, "If-Modified-Since": {modified}}, 200},
+	}
+
+	for _, method := range []string{"GET", "HEAD"} {
+		for _, test := range tests {
+			w := do(method, test.header)
+
+			if w.Code != test.code {
+				t.Errorf("%s %v: expected %d, got %d %s", method, test.header, test.code, w.Code, w.Body.String())
+			}
+
+			if w.Code == 304 && (w.Header().Get("ETag") != info.ETag || w.Body.Len() != 0) {
+				t.Errorf("%s %v: expected the ETag and no body, got %v %s", method, test.header, w.Header(), w.Body.String())
+			}
+
+			if w.Code == 412 && method == "GET" && !strings.Contains(w.Body.String(), "PreconditionFailed") {
+				t.Errorf("%s %v: expected PreconditionFailed, got %s", method, test.header, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestMainHandlerConditionalWrite(t *testing.T) {
+	*hostname = "test.dev"
+	backend = inMemory.NewS3Backend()
+	verifier = nil
+	backend.PutBucket("bucket", nil, localPrincipal)
+
+	do := func(name string, value string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("PUT", "http://test.dev:10001/bucket/key", strings.NewReader("contents"))
+		r.Header.Set(name, value)
+		mainHandler(w, r)
+
+		return w
+	}
+
+	w := do("If-None-Match", "*")
+
+	if w.Code != 200 {
+		t.Fatalf("Expected the object to be created, got %d %s", w.Code, w.Body.String())
+	}
+
+	etag := w.Header().Get("ETag")
+
+	if w := do("If-None-Match", "*"); w.Code != 412 || !strings.Contains(w.Body.String(), "PreconditionFailed") {
+		t.Errorf("Expected PreconditionFailed, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("If-Match", `"other"`); w.Code != 412 {
+		t.Errorf("Expected PreconditionFailed for another ETag, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("If-Match", etag); w.Code != 200 {
+		t.Errorf("Expected the object to be replaced, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("If-None-Match", etag); w.Code != 501 {
+		t.Errorf("Expected If-None-Match with an ETag to be rejected, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestMainHandlerCopy(t *testing.T) {
+	*hostname = "test.dev"
+	backend = inMemory.NewS3Backend()
+	verifier = nil
+	backend.PutBucket("bucket", nil, localPrincipal)
+	backend.PutBucket("other", nil, localPrincipal)
+	backend.PutBucketVersioning("bucket", common.VersioningEnabled, localPrincipal)
+
+	meta := &common.ObjectInfo{ContentType: "text/plain", Meta: map[string][]string{"color": {"blue"}}}
+	v1, _ := backend.PutObject("bucket", "key", strings.NewReader("v1"), 2, meta, nil, nil, nil, localPrincipal)
+	v2, _ := backend.PutObject("bucket", "key", strings.NewReader("v2"), 2, meta, nil, nil, nil, localPrincipal)
+
+	do := func(method string, url string, header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, "http://test.dev:10001"+url, nil)
+
+		for k, v := range header {
+			r.Header[k] = v
+		}
+
+		mainHandler(w, r)
+
+		return w
+	}
+
+	w := do("PUT", "/other/copy", http.Header{"X-Amz-Copy-Source": {"/bucket/key"}})
+
+	if w.Code != 200 || !strings.Contains(w.Body.String(), "<CopyObjectResult><ETag>&#34;") || w.Header().Get("x-amz-copy-source-version-id") != v2.VersionId {
+		t.Fatalf("Expected the copy of the current version, got %d %v %s", w.Code, w.Header(), w.Body.String())
+	}
+
+	if w := do("GET", "/other/copy", nil); w.Body.String() != "v2" || w.Header().Get("x-amz-meta-color") != "blue" || w.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("Expected the copy to keep the metadata, got %v %s", w.Header(), w.Body.String())
+	}
+
+	header := http.Header{
+		"X-Amz-Copy-Source":        {"bucket/key?versionId=" + v1.VersionId},
+		"X-Amz-Metadata-Directive": {"REPLACE"},
+		"Content-Type":             {"application/json"},
+	}
+
+	if w := do("PUT", "/other/copy", header); w.Code != 200 || w.Header().Get("x-amz-copy-source-version-id") != v1.VersionId {
+		t.Fatalf("Expected the copy of v1, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("GET", "/other/copy", nil); w.Body.String() != "v1" || w.Header().Get("x-amz-meta-color") != "" || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the copy to replace the metadata, got %v %s", w.Header(), w.Body.String())
+	}
+
+	tests := []struct {
+		header http.Header
+		code   int
+		error  string
+	}{
+		{http.Header{"X-Amz-Copy-Source": {"/bucket/key"}, "X-Amz-Copy-Source-If-Match": {`"other"`}}, 412, "PreconditionFailed"},
+		{http.Header{"X-Amz-Copy-Source": {"/bucket/key"}, "X-Amz-Copy-Source-If-None-Match": {v2.ETag}}, 412, "PreconditionFailed"},
+		{http.Header{"X-Amz-Copy-Source": {"/bucket/key"}, "X-Amz-Copy-Source-If-Match": {v2.ETag}}, 200, ""},
+		{http.Header{"X-Amz-Copy-Source": {"/bucket/missing"}}, 404, "NoSuchKey"},
+		{http.Header{"X-Amz-Copy-Source": {"/bucket"}}, 400, "InvalidArgument"},
+		{http.Header{"X-Amz-Copy-Source": {"/bucket/key"}, "X-Amz-Metadata-Directive": {"MERGE"}}, 400, "InvalidArgument"},
+	}
+
+	for _, test := range tests {
+		w := do("PUT", "/other/copy", test.header)
+
+		if w.Code != test.code || !strings.Contains(w.Body.String(), test.error) {
+			t.Errorf("%v: expected %d %s, got %d %s", test.header, test.code, test.error, w.Code, w.Body.String())
+		}
+	}
+
+	if w := do("PUT", "/bucket/key", http.Header{"X-Amz-Copy-Source": {"/bucket/key"}}); w.Code != 400 || !strings.Contains(w.Body.String(), "InvalidRequest") {
+		t.Errorf("Expected a copy onto itself to be rejected, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("PUT", "/bucket/key", http.Header{"X-Amz-Copy-Source": {"/bucket/key"}, "X-Amz-Metadata-Directive": {"REPLACE"}}); w.Code != 200 {
+		t.Errorf("Expected a copy onto itself to replace the metadata, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestMainHandlerUploadPartCopy(t *testing.T) {
+	*hostname = "test.dev"
+	backend = inMemory.NewS3Backend()
+	verifier = nil
+	backend.PutBucket("bucket", nil, localPrincipal)
+	backend.PutBucketVersioning("bucket", common.VersioningEnabled, localPrincipal)
+
+	v1, _ := backend.PutObject("bucket", "source", strings.NewReader("hello world"), 11, nil, nil, nil, nil, localPrincipal)
+	backend.PutObject("bucket", "source", strings.NewReader("v2"), 2, nil, nil, nil, nil, localPrincipal)
+	id, _ := backend.CreateMultipartUpload("bucket", "key", nil, nil, nil, localPrincipal)
+
+	do := func(method string, url string, header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, "http://test.dev:10001"+url, nil)
+
+		for k, v := range header {
+			r.Header[k] = v
+		}
+
+		mainHandler(w, r)
+
+		return w
+	}
+
+	header := http.Header{
+		"X-Amz-Copy-Source":       {"/bucket/source?versionId=" + v1.VersionId},
+		"X-Amz-Copy-Source-Range": {"bytes=0-4"},
+	}
+	w := do("PUT", "/bucket/key?partNumber=1&uploadId="+id, header)
+
+	if w.Code != 200 || !strings.Contains(w.Body.String(), "<CopyPartResult><ETag>&#34;") || w.Header().Get("x-amz-copy-source-version-id") != v1.VersionId {
+		t.Fatalf("Expected the copy of a range of v1, got %d %v %s", w.Code, w.Header(), w.Body.String())
+	}
+
+	parts, _ := backend.ListParts("bucket", "key", id, 0, common.MaxListParts, localPrincipal)
+
+	if len(parts.Parts) != 1 || parts.Parts[0].Size != 5 {
+		t.Errorf("Expected part 1 of 5 bytes, got %+v", parts.Parts)
+	}
+
+	tests := []struct {
+		url    string
+		header http.Header
+		code   int
+		error  string
+	}{
+		{"/bucket/key?partNumber=2&uploadId=" + id, http.Header{"X-Amz-Copy-Source": {"/bucket/source"}}, 200, ""},
+		{"/bucket/key?partNumber=2&uploadId=" + id, http.Header{"X-Amz-Copy-Source": {"/bucket/source"}, "X-Amz-Copy-Source-Range": {"bytes=0-2"}}, 416, "InvalidRange"},
+		{"/bucket/key?partNumber=2&uploadId=" + id, http.Header{"X-Amz-Copy-Source": {"/bucket/source"}, "X-Amz-Copy-Source-Range": {"bytes=0-"}}, 400, "InvalidArgument"},
+		{"/bucket/key?partNumber=2&uploadId=" + id, http.Header{"X-Amz-Copy-Source": {"/bucket/source"}, "X-Amz-Copy-Source-If-Match": {`"other"`}}, 412, "PreconditionFailed"},
+		{"/bucket/key?partNumber=0&uploadId=" + id, http.Header{"X-Amz-Copy-Source": {"/bucket/source"}}, 400, "InvalidArgument"},
+		{"/bucket/key?partNumber=2&uploadId=missing", http.Header{"X-Amz-Copy-Source": {"/bucket/source"}}, 404, "NoSuchUpload"},
+		{"/bucket/key?partNumber=2&uploadId=" + id, http.Header{"X-Amz-Copy-Source": {"/bucket/missing"}}, 404, "NoSuchKey"},
+	}
+
+	for _, test := range tests {
+		w := do("PUT", test.url, test.header)
+
+		if w.Code != test.code || !strings.Contains(w.Body.String(), test.error) {
+			t.Errorf("%s %v: expected %d %s, got %d %s", test.url, test.header, test.code, test.error, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestMainHandlerDeleteMissingObject(t *testing.T) {
+	*hostname = "test.dev"
+	backend = inMemory.NewS3Backend()
+	verifier = nil
+	backend.PutBucket("bucket", nil, localPrincipal)
+
+	w := httptest.NewRecorder()
+	mainHandler(w, httptest.NewRequest("DELETE", "http://test.dev:10001/bucket/missing", nil))
+
+	if w.Code != 204 {
+		t.Errorf("Expected deleting a missing key to succeed, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestMainHandlerDeleteObjects(t *testing.T) {
+	*hostname = "test.dev"
+	backend = inMemory.NewS3Backend()
+	verifier = nil
+	backend.PutBucket("bucket", nil, localPrincipal)
+	backend.PutBucketVersioning("bucket", common.VersioningEnabled, localPrincipal)
+
+	v1, _ := backend.PutObject("bucket", "a", strings.NewReader("v1"), 2, nil, nil, nil, nil, localPrincipal)
+	backend.PutObject("bucket", "a", strings.NewReader("v2"), 2, nil, nil, nil, nil, localPrincipal)
+	backend.PutObject("bucket", "b", strings.NewReader("b"), 1, nil, nil, nil, nil, localPrincipal)
+	backend.PutObject("bucket", "protected/c", strings.NewReader("c"), 1, nil, nil, nil, nil, localPrincipal)
+	backend.PutBucketPolicy("bucket", []byte(`{"Version": "2012-10-17", "Statement": [
+		{"Effect": "Deny", "Principal": "*", "Action": "s3:DeleteObject", "Resource": "arn:aws:s3:::bucket/protected/*"}
+	]}`), localPrincipal)
+
+	do := func(body string, md5sum bool) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "http://test.dev:10001/bucket?delete", strings.NewReader(body))
+
+		if md5sum {
+			sum := md5.Sum([]byte(body))
+			r.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+		}
+
+		mainHandler(w, r)
+
+		return w
+	}
+
+	body := "<Delete><Object><Key>a</Key><VersionId>" + v1.VersionId + "</VersionId></Object><Object><Key>b</Key></Object><Object><Key>protected/c</Key></Object></Delete>"
+	w := do(body, true)
+
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d %s", w.Code, w.Body.String())
+	}
+
+	for _, s := range []string{
+		"<Deleted><Key>a</Key><VersionId>" + v1.VersionId + "</VersionId></Deleted>",
+		"<Deleted><Key>b</Key><DeleteMarker>true</DeleteMarker><DeleteMarkerVersionId>",
+		"<Error><Key>protected/c</Key><Code>AccessDenied</Code>",
+	} {
+		if !strings.Contains(w.Body.String(), s) {
+			t.Errorf("Expected %s in %s", s, w.Body.String())
+		}
+	}
+
+	if _, _, err := backend.GetObject("bucket", "protected/c", "", localPrincipal); err != nil {
+		t.Errorf("Expected the protected object to be kept, got %v", err)
+	}
+
+	quiet := "<Delete><Quiet>true</Quiet><Object><Key>a</Key></Object><Object><Key>protected/c</Key></Object></Delete>"
+
+	if w := do(quiet, true); w.Code != 200 || strings.Contains(w.Body.String(), "<Deleted>") || !strings.Contains(w.Body.String(), "<Error><Key>protected/c</Key>") {
+		t.Errorf("Expected only the errors in quiet mode, got %d %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		body   string
+		md5sum bool
+		code   int
+		error  string
+	}{
+		{body, false, 400, "InvalidRequest"},
+		{"<Delete></Delete>", true, 400, "MalformedXML"},
+		{"<Delete><Object><Key></Key></Object></Delete>", true, 400, "MalformedXML"},
+		{"<Delete><Object>", true, 400, "MalformedXML"},
+		{"<Delete>" + strings.Repeat("<Object><Key>k</Key></Object>", common.MaxDeleteObjects+1) + "</Delete>", true, 400, "MalformedXML"},
+	}
+
+	for _, test := range tests {
+		if w := do(test.body, test.md5sum); w.Code != test.code || !strings.Contains(w.Body.String(), test.error) {
+			t.Errorf("%.40s: expected %d %s, got %d %s", test.body, test.code, test.error, w.Code, w.Body.String())
+		}
+	}
+}
+
+// postForm returns a multipart/form-data request with the fields, followed by
+// a file named a.txt unless contents is empty.
+func postForm(url string, fields [][2]string, contents string) *http.Request {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+
+	for _, f := range fields {
+		mw.WriteField(f[0], f[1])
+	}
+
+	if contents != "" {
+		fw, _ := mw.CreateFormFile("file", "a.txt")
+		fw.Write([]byte(contents))
+	}
+
+	mw.Close()
+
+	r := httptest.NewRequest("POST", url, body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	return r
+}
+
+func TestMainHandlerPostObject(t *testing.T) {
+	*hostname = "test.dev"
+	backend = inMemory.NewS3Backend()
+	verifier = nil
+	backend.PutBucket("bucket", nil, localPrincipal)
+
+	do := func(fields [][2]string, contents string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mainHandler(w, postForm("http://test.dev:10001/bucket", fields, contents))
+
+		return w
+	}
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mainHandler(w, httptest.NewRequest("GET", "http://test.dev:10001"+url, nil))
+
+		return w
+	}
+
+	fields := [][2]string{
+		{"key", "uploads/${filename}"},
+		{"Content-Type", "text/plain"},
+		{"x-amz-meta-color", "blue"},
+		{"success_action_status", "201"},
+	}
+	w := do(fields, "contents")
+
+	if w.Code != 201 || !strings.Contains(w.Body.String(), "<PostResponse><Location>http://test.dev:10001/bucket/uploads/a.txt</Location><Bucket>bucket</Bucket><Key>uploads/a.txt</Key>") {
+		t.Fatalf("Expected 201 with a PostResponse, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := get("/bucket/uploads/a.txt"); w.Body.String() != "contents" || w.Header().Get("Content-Type") != "text/plain" || w.Header().Get("x-amz-meta-color") != "blue" {
+		t.Errorf("Expected the uploaded object with its metadata, got %v %s", w.Header(), w.Body.String())
+	}
+
+	redirect := [][2]string{{"key", "b"}, {"success_action_redirect", "http://example.com/done?x=1"}}
+
+	if w := do(redirect, "b"); w.Code != 303 || !strings.HasPrefix(w.Header().Get("Location"), "http://example.com/done?bucket=bucket&etag=") || !strings.HasSuffix(w.Header().Get("Location"), "&key=b&x=1") {
+		t.Errorf("Expected a redirect, got %d %v", w.Code, w.Header())
+	}
+
+	policy := base64.StdEncoding.EncodeToString([]byte(`{"expiration": "` + time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `", "conditions": [
+		{"bucket": "bucket"},
+		["starts-with", "$key", "uploads/"],
+		["content-length-range", 1, 5]
+	]}`))
+
+	tests := []struct {
+		fields   [][2]string
+		contents string
+		code     int
+		error    string
+	}{
+		{[][2]string{{"key", "c"}}, "c", 204, ""},
+		{[][2]string{{"key", "uploads/c"}, {"policy", policy}}, "c", 204, ""},
+		{[][2]string{{"key", "c"}, {"policy", policy}}, "c", 403, "AccessDenied"},
+		{[][2]string{{"key", "uploads/c"}, {"policy", policy}, {"acl", "public-read"}}, "c", 403, "AccessDenied"},
+		{[][2]string{{"key", "uploads/large"}, {"policy", policy}}, "too large", 400, "EntityTooLarge"},
+		{[][2]string{{"key", "uploads/c"}, {"policy", "e30="}}, "c", 400, "InvalidPolicyDocument"},
+		{[][2]string{{"key", "md5"}, {"Content-MD5", "1B2M2Y8AsgTpgAmY7PhCfg=="}}, "c", 400, "BadDigest"},
+		{[][2]string{{"key", "md5"}, {"Content-MD5", "not md5"}}, "c", 400, "InvalidDigest"},
+		{[][2]string{{"key", "c"}, {"Content-MD5", "SooI8J03tzeVZJA4QItfMw=="}}, "c", 204, ""},
+		{[][2]string{{"key", "c"}}, "", 400, "IncorrectNumberOfFilesInPostRequest"},
+		{[][2]string{{"Content-Type", "text/plain"}}, "c", 400, "InvalidArgument"},
+		{[][2]string{{"key", "c"}, {"x-amz-meta-big", strings.Repeat("x", 21*1024)}}, "c", 400, "MaxPostPreDataLengthExceededError"},
+	}
+
+	for _, test := range tests {
+		if w := do(test.fields, test.contents); w.Code != test.code || !strings.Contains(w.Body.String(), test.error) {
+			t.Errorf("%.80v: expected %d %s, got %d %s", test.fields, test.code, test.error, w.Code, w.Body.String())
+		}
+	}
+
+	if w := get("/bucket/uploads/large"); w.Code != 404 {
+		t.Errorf("Expected the large upload not to be stored, got %d", w.Code)
+	}
+
+	if w := get("/bucket/md5"); w.Code != 404 {
+		t.Errorf("Expected the upload with a mismatching Content-MD5 not to be stored, got %d", w.Code)
+	}
+
+	backend.PutBucketPolicy("bucket", []byte(`{"Version": "2012-10-17", "Statement": [
+		{"Effect": "Deny", "Principal": "*", "Action": "s3:PutObject", "Resource": "arn:aws:s3:::bucket/*",
+		 "Condition": {"StringEquals": {"s3:x-amz-acl": "public-read"}}}
+	]}`), localPrincipal)
+
+	if w := do([][2]string{{"key", "public"}, {"acl", "public-read"}}, "p"); w.Code != 403 || !strings.Contains(w.Body.String(), "AccessDenied") {
+		t.Errorf("Expected the policy to deny the public-read upload, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do([][2]string{{"key", "private"}, {"acl", "private"}}, "p"); w.Code != 204 {
+		t.Errorf("Expected the private upload to be allowed, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	mainHandler(w, httptest.NewRequest("POST", "http://test.dev:10001/bucket", strings.NewReader("key=c")))
+
+	if w.Code != 400 || !strings.Contains(w.Body.String(), "RequestIsNotMultiPartContent") {
+		t.Errorf("Expected RequestIsNotMultiPartContent, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestMainHandlerPostObjectSigned(t *testing.T) {
+	*hostname = "test.dev"
+	backend = inMemory.NewS3Backend()
+	creds := auth.NewMemoryCredentials(&auth.Account{AccessKey: "key", SecretKey: "secret"})
+	verifier = &auth.Verifier{Credentials: creds}
+	defer func() { verifier = nil }()
+
+	account, _ := creds.Account("key")
+	backend.PutBucket("bucket", nil, account.Principal())
+
+	policy := base64.StdEncoding.EncodeToString([]byte(`{"expiration": "` + time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `", "conditions": [
+		{"bucket": "bucket"},
+		{"key": "signed"}
+	]}`))
+	mac := hmac.New(sha1.New, []byte("secret"))
+	mac.Write([]byte(policy))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		fields [][2]string
+		code   int
+		error  string
+	}{
+		{[][2]string{{"key", "signed"}, {"AWSAccessKeyId", "key"}, {"policy", policy}, {"signature", signature}}, 204, ""},
+		{[][2]string{{"key", "signed"}, {"AWSAccessKeyId", "key"}, {"policy", policy}, {"signature", "x" + signature}}, 403, "SignatureDoesNotMatch"},
+		{[][2]string{{"key", "signed"}, {"AWSAccessKeyId", "other"}, {"policy", policy}, {"signature", signature}}, 403, "InvalidAccessKeyId"},
+		{[][2]string{{"key", "signed"}, {"policy", policy}}, 403, "AccessDenied"},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		mainHandler(w, postForm("http://bucket.test.dev:10001/", test.fields, "signed"))
+
+		if w.Code != test.code || !strings.Contains(w.Body.String(), test.error) {
+			t.Errorf("%.80v: expected %d %s, got %d %s", test.fields, test.code, test.error, w.Code, w.Body.String())
+		}
+	}
+
+	if info, err := backend.HeadObject("bucket", "signed", "", account.Principal()); err != nil || info.Owner != account.Principal().Owner() {
+		t.Errorf("Expected the object to be owned by the signer, got %+v, %v", info, err)
+	}
+}
+
+func TestMainHandlerCopyAccess(t *testing.T) {
+	*hostname = "test.dev"
+	backend = inMemory.NewS3Backend()
+	verifier = &auth.Verifier{Credentials: auth.NewMemoryCredentials(&auth.Account{AccessKey: "key", SecretKey: "secret"})}
+	defer func() { verifier = nil }()
+
+	owner := localPrincipal.Owner()
+	open, _ := common.PublicReadWrite.Grants(owner, owner)
+	public, _ := common.PublicRead.Grants(owner, owner)
+	backend.PutBucket("bucket", nil, localPrincipal)
+	backend.PutBucket("open", open, localPrincipal)
+	backend.PutObject("bucket", "public", strings.NewReader("public"), 6, nil, public, nil, nil, localPrincipal)
+	backend.PutObject("bucket", "private", strings.NewReader("private"), 7, nil, nil, nil, nil, localPrincipal)
+
+	tests := []struct {
+		source string
+		code   int
+	}{
+		{"/bucket/public", 200},
+		{"/bucket/private", 403},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("PUT", "http://test.dev:10001/open/copy", nil)
+		r.Header.Set("x-amz-copy-source", test.source)
+		mainHandler(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: expected %d, got %d %s", test.source, test.code, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+// requestTags returns the tags set by the x-amz-tagging header of a write, or
+// nil if there is none.
+func requestTags(r *http.Request) ([]common.Tag, *common.Error) {
+	if _, ok := r.Header["X-Amz-Tagging"]; !ok {
+		return nil, nil
+	}
+
+	return common.ParseTaggingHeader(r.Header.Get("x-amz-tagging"))
+}
+
+// setTaggingCountHeader sets x-amz-tagging-count if the object read by rd has
+// tags.
+func setTaggingCountHeader(w http.ResponseWriter, rd *S3Request, versionID string) {
+	tags, _, awserr := backend.GetObjectTagging(rd.bucket, rd.object, versionID, rd.principal)
+
+	if awserr == nil && len(tags) > 0 {
+		w.Header().Set("x-amz-tagging-count", strconv.Itoa(len(tags)))
+	}
+}
+
+// taggingBody decodes the Tagging document of PUT ?tagging.
+func taggingBody(r *http.Request) ([]common.Tag, *common.Error) {
+	tagging := &common.Tagging{}
+
+	if awserr := readXML(r, tagging, &common.ErrMalformedXML); awserr != nil {
+		return nil, awserr
+	}
+
+	return tagging.TagSet.Tags, nil
+}
+
+func getBucketTaggingHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("getBucketTaggingHandler", rd)
+	tags, awserr := backend.GetBucketTagging(rd.bucket, rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	writeXML(w, common.NewTagging(tags))
+}
+
+func putBucketTaggingHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("putBucketTaggingHandler", rd)
+	tags, awserr := taggingBody(r)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	if awserr := backend.PutBucketTagging(rd.bucket, tags, rd.principal); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func deleteBucketTaggingHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("deleteBucketTaggingHandler", rd)
+
+	if awserr := backend.DeleteBucketTagging(rd.bucket, rd.principal); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getObjectTaggingHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("getObjectTaggingHandler", rd)
+	tags, versionID, awserr := backend.GetObjectTagging(rd.bucket, rd.object, rd.Param("versionId"), rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	setVersionHeader(w, "x-amz-version-id", rd, versionID)
+	writeXML(w, common.NewTagging(tags))
+}
+
+func putObjectTaggingHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("putObjectTaggingHandler", rd)
+	tags, awserr := taggingBody(r)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	versionID, awserr := backend.PutObjectTagging(rd.bucket, rd.object, rd.Param("versionId"), tags, rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	setVersionHeader(w, "x-amz-version-id", rd, versionID)
+	w.WriteHeader(http.StatusOK)
+}
+
+func deleteObjectTaggingHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("deleteObjectTaggingHandler", rd)
+	versionID, awserr := backend.DeleteObjectTagging(rd.bucket, rd.object, rd.Param("versionId"), rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	setVersionHeader(w, "x-amz-version-id", rd, versionID)
+	w.WriteHeader(http.StatusNoContent)
+}
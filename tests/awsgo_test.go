@@ -10,12 +10,13 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
 var url string = "http://test.dev:10001"
 
 func ResetAWS(t *testing.T) {
-	resetURL := url + "_internal/reset"
+	resetURL := url + "/_internal/reset"
 
 	_, err := http.Get(resetURL)
 
@@ -67,21 +68,51 @@ func TestAWSPutGet(t *testing.T) {
 
 func TestAWSObjectCyclePathMethod(t *testing.T) {
 	bucket := "TestBucket"
-	awsObjectCycle(bucket, t)
+	svc := initTest(t)
+	svc.Config.S3ForcePathStyle = aws.Bool(true)
+	awsObjectCycle(svc, bucket, t)
 }
 
-func testAWSObjectCycleSubdomainMethod(t *testing.T) {
+// The SDK addresses DNS compatible buckets as <bucket>.test.dev unless
+// S3ForcePathStyle is set.
+func TestAWSObjectCycleSubdomainMethod(t *testing.T) {
 	bucket := "testbucket"
-	awsObjectCycle(bucket, t)
+	awsObjectCycle(initTest(t), bucket, t)
+}
+
+func TestAWSListBucketsSubdomainMethod(t *testing.T) {
+	svc := initTest(t)
+
+	for _, bucket := range []string{"bucket1", "bucket2"} {
+		_, err := svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String(bucket)})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := svc.HeadBucket(&s3.HeadBucketInput{Bucket: aws.String("bucket1")})
+
+	if err != nil {
+		t.Fatalf("Could not head bucket via subdomain: %v", err)
+	}
+
+	lbo, err := svc.ListBuckets(&s3.ListBucketsInput{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lbo.Buckets) != 2 {
+		t.Fatalf("Expected 2 buckets, got %d", len(lbo.Buckets))
+	}
 }
 
-func awsObjectCycle(bucket string, t *testing.T) {
+func awsObjectCycle(svc *s3.S3, bucket string, t *testing.T) {
 	objectPath := "test1"
 	objectContents := []byte("test1")
 	updatedObjectContents := []byte("Updatedtest")
 
-	svc := initTest(t)
-
 	_, err := svc.CreateBucket(&s3.CreateBucketInput{Bucket: &bucket})
 
 	if err != nil {
@@ -454,3 +485,330 @@ func TestGetBucketWebsite(t *testing.T) {
 		t.Fatalf("Could not get Bucket location: %s", err)
 	}
 }
+
+func TestAWSHierarchicalKeys(t *testing.T) {
+	bucket := "testbucket"
+	keys := []string{"photos/2016/a.jpg", "with space", "a+b", "ünïcödé", "a%2Fb", "folder/", "a//b"}
+
+	svc := initTest(t)
+
+	_, err := svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String(bucket)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range keys {
+		_, err = svc.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   bytes.NewReader([]byte(key)),
+		})
+
+		if err != nil {
+			t.Fatalf("Could not put %q: %v", key, err)
+		}
+	}
+
+	for _, key := range keys {
+		resp, err := svc.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+
+		if err != nil {
+			t.Fatalf("Could not get %q: %v", key, err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != key {
+			t.Errorf("Expected content %q for key %q, got %q", key, key, string(data))
+		}
+	}
+
+	loo, err := svc.ListObjects(&s3.ListObjectsInput{Bucket: aws.String(bucket)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := make(map[string]bool)
+
+	for _, o := range loo.Contents {
+		found[*o.Key] = true
+	}
+
+	for _, key := range keys {
+		if !found[key] {
+			t.Errorf("Key %q missing from listing", key)
+		}
+	}
+}
+
+func TestAWSMultipartUpload(t *testing.T) {
+	bucket := "testbucket"
+	contents := bytes.Repeat([]byte("0123456789"), 1200000)
+
+	svc := initTest(t)
+
+	_, err := svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String(bucket)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploader := s3manager.NewUploaderWithClient(svc, func(u *s3manager.Uploader) {
+		u.PartSize = s3manager.MinUploadPartSize
+	})
+
+	_, err = uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String("large"),
+		Body:   bytes.NewReader(contents),
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String("large"),
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, contents) {
+		t.Errorf("Contents of multipart upload differ")
+	}
+
+	lmu, err := svc.ListMultipartUploads(&s3.ListMultipartUploadsInput{Bucket: aws.String(bucket)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lmu.Uploads) != 0 {
+		t.Errorf("Expected no uploads in progress, got %d", len(lmu.Uploads))
+	}
+}
+
+func TestAWSListObjectsDelimiterPaging(t *testing.T) {
+	bucket := "testbucket"
+	keys := []string{"a.txt", "photos/2016/a.jpg", "photos/2016/b.jpg", "photos/index.html", "z.txt"}
+
+	svc := initTest(t)
+
+	_, err := svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String(bucket)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range keys {
+		_, err = svc.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   bytes.NewReader([]byte(key)),
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var listed []string
+	pages := 0
+
+	err = svc.ListObjectsPages(&s3.ListObjectsInput{
+		Bucket:    aws.String(bucket),
+		Delimiter: aws.String("/"),
+		MaxKeys:   aws.Int64(1),
+	}, func(p *s3.ListObjectsOutput, last bool) bool {
+		pages++
+
+		for _, o := range p.Contents {
+			listed = append(listed, *o.Key)
+		}
+
+		for _, cp := range p.CommonPrefixes {
+			listed = append(listed, *cp.Prefix)
+		}
+
+		return true
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"a.txt", "photos/", "z.txt"}
+
+	if pages != 3 || len(listed) != len(expected) {
+		t.Fatalf("Expected %v in 3 pages, got %v in %d pages", expected, listed, pages)
+	}
+
+	for i := range expected {
+		if listed[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, listed)
+		}
+	}
+}
+
+func TestAWSListObjectsV2Paging(t *testing.T) {
+	bucket := "testbucket"
+	keys := []string{"a.txt", "photos/2016/a.jpg", "photos/index.html", "z.txt"}
+
+	svc := initTest(t)
+
+	_, err := svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String(bucket)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range keys {
+		_, err = svc.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   bytes.NewReader([]byte(key)),
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var listed []string
+
+	err = svc.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		MaxKeys: aws.Int64(3),
+	}, func(p *s3.ListObjectsV2Output, last bool) bool {
+		if *p.KeyCount != int64(len(p.Contents)) {
+			t.Errorf("Expected KeyCount %d, got %d", len(p.Contents), *p.KeyCount)
+		}
+
+		for _, o := range p.Contents {
+			listed = append(listed, *o.Key)
+		}
+
+		return true
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(listed) != len(keys) {
+		t.Fatalf("Expected %v, got %v", keys, listed)
+	}
+
+	for i := range keys {
+		if listed[i] != keys[i] {
+			t.Errorf("Expected %v, got %v", keys, listed)
+		}
+	}
+
+	_, err = svc.ListObjectsV2(&s3.ListObjectsV2Input{
+		Bucket:            aws.String(bucket),
+		ContinuationToken: aws.String("invalid"),
+	})
+
+	if err == nil {
+		t.Error("Expected an error for an invalid continuation token")
+	}
+}
+
+func TestAWSVersioning(t *testing.T) {
+	bucket := "testbucket"
+
+	svc := initTest(t)
+
+	_, err := svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String(bucket)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.PutBucketVersioning(&s3.PutBucketVersioningInput{
+		Bucket:                  aws.String(bucket),
+		VersioningConfiguration: &s3.VersioningConfiguration{Status: aws.String("Enabled")},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vc, err := svc.GetBucketVersioning(&s3.GetBucketVersioningInput{Bucket: aws.String(bucket)})
+
+	if err != nil || vc.Status == nil || *vc.Status != "Enabled" {
+		t.Fatalf("Expected versioning to be enabled, got %v, %v", vc, err)
+	}
+
+	var versions []string
+
+	for _, contents := range []string{"v1", "v2"} {
+		po, err := svc.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String("key"),
+			Body:   bytes.NewReader([]byte(contents)),
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		versions = append(versions, *po.VersionId)
+	}
+
+	do, err := svc.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String("key")})
+
+	if err != nil || do.DeleteMarker == nil || !*do.DeleteMarker {
+		t.Fatalf("Expected a delete marker, got %v, %v", do, err)
+	}
+
+	_, err = svc.GetObject(&s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String("key")})
+
+	if err == nil {
+		t.Error("Expected an error for a deleted object")
+	}
+
+	gor, err := svc.GetObject(&s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String("key"), VersionId: aws.String(versions[0])})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := ioutil.ReadAll(gor.Body)
+	gor.Body.Close()
+
+	if string(b) != "v1" || *gor.VersionId != versions[0] {
+		t.Errorf("Expected v1 at version %s, got %s at %s", versions[0], b, *gor.VersionId)
+	}
+
+	lov, err := svc.ListObjectVersions(&s3.ListObjectVersionsInput{Bucket: aws.String(bucket)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lov.Versions) != 2 || len(lov.DeleteMarkers) != 1 || !*lov.DeleteMarkers[0].IsLatest {
+		t.Errorf("Expected 2 versions and a delete marker, got %v", lov)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/0x434D53/s3server/common"
+)
+
+// setVersionHeader sets the header name to the version ID of an object of the
+// bucket of rd. Like S3, no version ID is sent for buckets which never had
+// versioning enabled, whose objects all have the null version ID.
+func setVersionHeader(w http.ResponseWriter, name string, rd *S3Request, versionID string) {
+	if versionID == "" {
+		return
+	}
+
+	if versionID == common.NullVersionID {
+		if status, awserr := backend.GetBucketVersioning(rd.bucket, rd.principal); awserr != nil || status == "" {
+			return
+		}
+	}
+
+	w.Header().Set(name, versionID)
+}
+
+func getBucketVersioningHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("getBucketVersioningHandler", rd)
+	status, awserr := backend.GetBucketVersioning(rd.bucket, rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	writeXML(w, &common.VersioningConfiguration{Status: status})
+}
+
+func putBucketVersioningHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("putBucketVersioningHandler", rd)
+	vc := common.VersioningConfiguration{}
+
+	if awserr := readXML(r, &vc, &common.ErrMalformedXML); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	if vc.Status != common.VersioningEnabled && vc.Status != common.VersioningSuspended {
+		writeError(w, &common.ErrIllegalVersioningConfigurationException)
+		return
+	}
+
+	if awserr := backend.PutBucketVersioning(rd.bucket, vc.Status, rd.principal); awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func getBucketObjectVersionHandler(w http.ResponseWriter, r *http.Request, rd *S3Request) {
+	logHandlerCall("getBucketObjectVersionHandler", rd)
+	maxKeys, awserr := rd.IntParam("max-keys", common.MaxKeys)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	encodingType, awserr := rd.EncodingType()
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	params := common.VersionsParams{
+		Prefix:          rd.Param("prefix"),
+		Delimiter:       rd.Param("delimiter"),
+		KeyMarker:       rd.Param("key-marker"),
+		VersionIdMarker: rd.Param("version-id-marker"),
+		MaxKeys:         maxKeys,
+	}
+
+	res, awserr := backend.ListObjectVersions(rd.bucket, params, rd.principal)
+
+	if awserr != nil {
+		writeError(w, awserr)
+		return
+	}
+
+	if encodingType == "url" {
+		res.URLEncode()
+	}
+
+	writeXML(w, res)
+}